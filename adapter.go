@@ -2,6 +2,7 @@ package store
 
 import (
 	"math"
+	"strings"
 	"sync"
 
 	"github.com/moleculer-go/moleculer/payload"
@@ -13,6 +14,7 @@ import (
 var pageSize = 10
 var maxPageSize = 100
 var maxLimit = -1
+var maxPopulateDepth = 3
 
 var defaultSettings = map[string]interface{}{
 	//idField : Name of ID field.
@@ -24,6 +26,9 @@ var defaultSettings = map[string]interface{}{
 	//populates : Schema for population. [Read more](#populating).
 	"populates": map[string]interface{}{},
 
+	//maxPopulateDepth : Maximum depth of the populate of populated records (author.company is depth 2). Deeper populates are ignored.
+	"maxPopulateDepth": maxPopulateDepth,
+
 	//pageSize : Default page size in `list` action.
 	"pageSize": pageSize,
 
@@ -33,9 +38,27 @@ var defaultSettings = map[string]interface{}{
 	//*maxLimit : Maximum value of limit in `find` action. Default: `-1` (no limit)
 	"maxLimit": maxLimit,
 
-	//entityValidator : Validator schema or a function to validate the incoming entity in `create` & 'insert' actions.
+	//entityValidator : Validator schema or a function to validate the incoming entity in `create`, `insertMany` and update actions.
 	"entityValidator": nil,
 
+	//encodeID : func(moleculer.Payload) moleculer.Payload to encode the ids returned by the actions. Example: HashIDs{}.EncodeID
+	"encodeID": nil,
+
+	//decodeID : func(moleculer.Payload) moleculer.Payload to decode the ids received by the actions. Example: HashIDs{}.DecodeID
+	"decodeID": nil,
+
+	//timestamps : set createdAt and updatedAt in create, insertMany, update, findAndUpdate and updateMany actions.
+	"timestamps": false,
+
+	//versioning : set version on create and increment it on update. update and findAndUpdate fail with a version conflict when params.version is not the record version.
+	"versioning": false,
+
+	//softDelete : remove sets the deletedAt field instead of deleting the record. Deleted records are not returned by find, list, count and get.
+	"softDelete": false,
+
+	//entityEvents : Events config: { mode: broadcast (default) or emit, <action>: false to disable the event of the action }. false disables all events.
+	"entityEvents": nil,
+
 	//db-adapter : database specific adaptor. Example mongodb-adaptor.
 	"db-adapter": NotDefinedAdapter{},
 }
@@ -55,6 +78,12 @@ type Adapter interface {
 	UpdateById(id, update moleculer.Payload) moleculer.Payload
 	RemoveById(id moleculer.Payload) moleculer.Payload
 	RemoveAll() moleculer.Payload
+	// InsertMany inserts a list of records and returns the inserted records.
+	InsertMany(params moleculer.Payload) moleculer.Payload
+	// UpdateMany applies params.update to all records matching the params and returns the modifiedCount.
+	UpdateMany(params moleculer.Payload) moleculer.Payload
+	// RemoveMany removes the records with params.ids or matching the params and returns the deletedCount.
+	RemoveMany(params moleculer.Payload) moleculer.Payload
 }
 
 // settingsDefaults extract defauylt settings values for fields and populates
@@ -70,25 +99,205 @@ func settingsDefaults(settings map[string]interface{}) (fields []string, populat
 	return fields, populates
 }
 
+// idFieldName return the idField setting. Default: id
+func idFieldName(settings map[string]interface{}) string {
+	if field, ok := settings["idField"].(string); ok && field != "" {
+		return field
+	}
+	return "id"
+}
+
+// paramsId return the id param of the get, update and remove actions.
+// The id can be sent either in the idField or in the id param.
+func paramsId(settings map[string]interface{}, params moleculer.Payload) moleculer.Payload {
+	if !params.IsMap() {
+		return payload.New(nil)
+	}
+	if id := params.Get(idFieldName(settings)); id.Exists() {
+		return id
+	}
+	return params.Get("id")
+}
+
+// idCodec return the encodeID and decodeID functions from the settings. By default ids are not changed.
+func idCodec(settings map[string]interface{}) (encode, decode func(moleculer.Payload) moleculer.Payload) {
+	identity := func(id moleculer.Payload) moleculer.Payload {
+		return id
+	}
+	encode, decode = identity, identity
+	if fn, ok := settings["encodeID"].(func(moleculer.Payload) moleculer.Payload); ok {
+		encode = fn
+	}
+	if fn, ok := settings["decodeID"].(func(moleculer.Payload) moleculer.Payload); ok {
+		decode = fn
+	}
+	return encode, decode
+}
+
+// encodeIds apply the encodeID setting to the id of the records in the result.
+func encodeIds(settings map[string]interface{}, result moleculer.Payload) moleculer.Payload {
+	if _, ok := settings["encodeID"].(func(moleculer.Payload) moleculer.Payload); !ok {
+		return result
+	}
+	if result == nil || result.IsError() || !result.Exists() {
+		return result
+	}
+	if result.IsArray() {
+		return result.MapOver(func(item moleculer.Payload) moleculer.Payload {
+			return encodeIds(settings, item)
+		})
+	}
+	idField := idFieldName(settings)
+	id := result.Get(idField)
+	if !result.IsMap() || !id.Exists() {
+		return result
+	}
+	encode, _ := idCodec(settings)
+	//Remove() copies the record, so the adapter data is not changed
+	return result.Remove(idField).Add(idField, encode(id).Value())
+}
+
+// decodeParams apply the decodeID setting to the id, idField, ids and query idField params.
+// returns the error of the first id that decodeID could not decode.
+func decodeParams(settings map[string]interface{}, params moleculer.Payload) moleculer.Payload {
+	if _, ok := settings["decodeID"].(func(moleculer.Payload) moleculer.Payload); !ok {
+		return params
+	}
+	if params == nil || !params.IsMap() {
+		return params
+	}
+	_, decodeID := idCodec(settings)
+	var invalid moleculer.Payload
+	decode := func(id moleculer.Payload) moleculer.Payload {
+		decoded := decodeID(id)
+		if decoded.IsError() && invalid == nil {
+			invalid = decoded
+		}
+		return decoded
+	}
+	idField := idFieldName(settings)
+	fields := []string{"id"}
+	if idField != "id" {
+		fields = append(fields, idField)
+	}
+	for _, field := range fields {
+		if params.Get(field).Exists() {
+			params = params.Remove(field).Add(field, decode(params.Get(field)).Value())
+		}
+	}
+	if params.Get("ids").IsArray() {
+		params = params.Remove("ids").Add("ids", params.Get("ids").MapOver(decode))
+	}
+	query := params.Get("query")
+	if query.IsMap() && query.Get(idField).Exists() && !query.Get(idField).IsMap() {
+		if query.Get(idField).IsArray() {
+			query = query.Remove(idField).Add(idField, query.Get(idField).MapOver(decode))
+		} else {
+			query = query.Remove(idField).Add(idField, decode(query.Get(idField)).Value())
+		}
+		params = params.Remove("query").Add("query", query)
+	}
+	if invalid != nil {
+		return invalid
+	}
+	return params
+}
+
+// decodeStoredIds decode the ids sent by the populate of another service, that are the references stored in its
+// records: the raw database ids or the encoded ids. An id that decodeID can not decode is used as is.
+// returns the params with the decoded ids and the received id of each decoded id, used as the keys of the mapping.
+func decodeStoredIds(settings map[string]interface{}, params moleculer.Payload) (moleculer.Payload, map[string]string) {
+	_, decodeID := idCodec(settings)
+	received := map[string]string{}
+	ids := []interface{}{}
+	for _, id := range params.Get("ids").Array() {
+		decoded := decodeID(id)
+		if decoded.IsError() {
+			decoded = id
+		}
+		received[decoded.String()] = id.String()
+		ids = append(ids, decoded.Value())
+	}
+	return params.Remove("ids").Add("ids", ids), received
+}
+
 func transformResult(ctx moleculer.Context, params, result moleculer.Payload, getInstance func() *moleculer.ServiceSchema) moleculer.Payload {
 	instance := getInstance()
 	fields, populates := settingsDefaults(instance.Settings)
 	return populateFields(ctx, constrainFields(
-		result, params, fields,
-	), params, populates)
+		encodeIds(instance.Settings, result), params, fields,
+	), params, populates, populateDepthLimit(instance.Settings))
+}
+
+// mapByID return a map of the transformed records by the encoded id of the records, for the mapping param of the get action.
+// The records of the received ids are mapped by the id received instead.
+func mapByID(settings map[string]interface{}, records, transformed moleculer.Payload, received map[string]string) moleculer.Payload {
+	if transformed.IsError() || transformed.Len() != records.Len() {
+		return transformed
+	}
+	encode, _ := idCodec(settings)
+	idField := idFieldName(settings)
+	mapping := map[string]interface{}{}
+	for i, record := range records.Array() {
+		if id := record.Get(idField); id.Exists() {
+			key, found := received[id.String()]
+			if !found {
+				key = encode(id).String()
+			}
+			mapping[key] = transformed.At(i).Value()
+		}
+	}
+	return payload.New(mapping)
 }
 
 // findAction
 func findAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
 	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
-		return transformResult(ctx, params, adapter.Find(params), getInstance)
+		settings := getInstance().Settings
+		query := callHook(settings, beforeQuery, ctx, adapter, excludeDeleted(settings, decodeParams(settings, params)))
+		if query.IsError() {
+			return query
+		}
+		result := callHook(settings, afterQuery, ctx, adapter, adapter.Find(query))
+		return transformResult(ctx, params, result, getInstance)
 	}
 }
 
 // findAndUpdateAction
 func findAndUpdateAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
 	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
-		return transformResult(ctx, params, adapter.FindAndUpdate(params), getInstance)
+		settings := getInstance().Settings
+		hooks := []string{beforeEntityUpdate, afterEntityUpdate, afterEntityChange}
+		r := runWithHooks(adapter, settings, hooks, func(tx Adapter) moleculer.Payload {
+			query := callHook(settings, beforeEntityUpdate, ctx, tx, excludeDeleted(settings, decodeParams(settings, params)))
+			if query.IsError() {
+				return query
+			}
+			if invalid := validateEntity(settings, query.Get("update"), true); invalid != nil {
+				return invalid
+			}
+			update := stampUpdate(settings, query.Get("update"))
+			var r moleculer.Payload
+			if versioningEnabled(settings) {
+				r = findAndUpdateVersioned(settings, tx, query, update)
+			} else {
+				r = tx.FindAndUpdate(query.Remove("update").Add("update", update.Value()))
+			}
+			return callAfterHooks(settings, afterEntityUpdate, ctx, tx, r)
+		})
+		return transformResult(ctx, params, r, getInstance)
+	}
+}
+
+// countAction
+func countAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		settings := getInstance().Settings
+		query := callHook(settings, beforeQuery, ctx, adapter, excludeDeleted(settings, decodeParams(settings, params)))
+		if query.IsError() {
+			return query
+		}
+		return adapter.Count(query)
 	}
 }
 
@@ -98,10 +307,24 @@ func createAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema)
 		if params == nil || !params.Exists() {
 			return payload.Error("params cannot be empty!")
 		}
-		r := adapter.Insert(params)
+		settings := getInstance().Settings
+		hooks := []string{beforeEntityCreate, afterEntityCreate, afterEntityChange}
+		r := runWithHooks(adapter, settings, hooks, func(tx Adapter) moleculer.Payload {
+			entity := callHook(settings, beforeEntityCreate, ctx, tx, params)
+			if entity.IsError() {
+				return entity
+			}
+			if invalid := validateEntity(settings, entity, false); invalid != nil {
+				return invalid
+			}
+			return callAfterHooks(settings, afterEntityCreate, ctx, tx, tx.Insert(stampCreate(settings, entity)))
+		})
+		r = encodeIds(settings, r)
 		if !r.IsError() {
-			event := getInstance().Name + ".created"
-			ctx.Broadcast(event, r.Get("id").String())
+			publishEvent(ctx, getInstance, "create", "created", map[string]interface{}{
+				"id":     r.Get(idFieldName(settings)).Value(),
+				"entity": r.Value(),
+			})
 		}
 		return r
 	}
@@ -113,13 +336,55 @@ func updateAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema)
 		if params == nil || !params.Exists() {
 			return payload.Error("params cannot be empty!")
 		}
-		if !params.Get("id").Exists() {
-			return payload.Error("id field required!") //TODO remove this after validator is added
+		settings := getInstance().Settings
+		idField := idFieldName(settings)
+		if !paramsId(settings, params).Exists() {
+			return payload.Error(idField, " field required!")
 		}
-		r := adapter.UpdateById(params.Get("id"), params.Remove("id"))
+		withEvent := eventEnabled(settings, "update")
+		var previous, entity moleculer.Payload
+		hooks := []string{beforeEntityUpdate, afterEntityUpdate, afterEntityChange}
+		r := runWithHooks(adapter, settings, hooks, func(tx Adapter) moleculer.Payload {
+			params := callHook(settings, beforeEntityUpdate, ctx, tx, decodeParams(settings, params))
+			if params.IsError() {
+				return params
+			}
+			id := paramsId(settings, params)
+			update := params.Remove("id")
+			if update.Get(idField).Exists() {
+				update = update.Remove(idField)
+			}
+			if invalid := validateEntity(settings, update, true); invalid != nil {
+				return invalid
+			}
+			if withEvent {
+				previous = tx.FindById(id)
+			}
+			var r moleculer.Payload
+			if versioningEnabled(settings) {
+				r = updateVersioned(tx, id, params.Get(versionField), stampUpdate(settings, update))
+			} else {
+				r = tx.UpdateById(id, stampUpdate(settings, update))
+			}
+			r = callAfterHooks(settings, afterEntityUpdate, ctx, tx, r)
+			if withEvent && !r.IsError() {
+				//some adapters return only the counts of the update
+				entity = r
+				if !r.Get(idField).Exists() {
+					entity = tx.FindById(id)
+				}
+			}
+			return r
+		})
+		r = encodeIds(settings, r)
 		if !r.IsError() {
-			event := getInstance().Name + ".updated"
-			ctx.Broadcast(event, r.Get("id").String())
+			previous, entity = encodeIds(settings, previous), encodeIds(settings, entity)
+			publishEvent(ctx, getInstance, "update", "updated", map[string]interface{}{
+				"id":       paramsId(settings, params).Value(),
+				"entity":   payloadValue(entity),
+				"previous": payloadValue(previous),
+				"changes":  entityChanges(previous, entity),
+			})
 		}
 		return r
 	}
@@ -131,16 +396,189 @@ func removeAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema)
 		if params == nil || !params.Exists() {
 			return payload.Error("params cannot be empty!")
 		}
-		if !params.Get("id").Exists() {
-			return payload.Error("id field required!") //TODO remove this after validator is added
+		settings := getInstance().Settings
+		if !paramsId(settings, params).Exists() {
+			return payload.Error(idFieldName(settings), " field required!")
+		}
+		withEvent := eventEnabled(settings, "remove")
+		var previous moleculer.Payload
+		hooks := []string{beforeEntityRemove, afterEntityRemove, afterEntityChange}
+		r := runWithHooks(adapter, settings, hooks, func(tx Adapter) moleculer.Payload {
+			decoded := callHook(settings, beforeEntityRemove, ctx, tx, decodeParams(settings, params))
+			if decoded.IsError() {
+				return decoded
+			}
+			id := paramsId(settings, decoded)
+			if withEvent {
+				previous = tx.FindById(id)
+			}
+			var r moleculer.Payload
+			if softDeleteEnabled(settings) {
+				r = softRemoveById(tx, id)
+			} else {
+				r = tx.RemoveById(id)
+			}
+			if r.IsError() {
+				return payload.Error("Could not remove record. Error: ", r.Error().Error())
+			}
+			return callAfterHooks(settings, afterEntityRemove, ctx, tx, params.Add("deletedCount", r.Get("deletedCount")))
+		})
+		if r.IsError() {
+			return r
+		}
+		publishEvent(ctx, getInstance, "remove", "removed", map[string]interface{}{
+			"id":       paramsId(settings, params).Value(),
+			"previous": payloadValue(encodeIds(settings, previous)),
+		})
+		return r
+	}
+}
+
+//insertManyAction
+func insertManyAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		entities := params
+		if params != nil && params.IsMap() {
+			entities = params.Get("entities")
+		}
+		if entities == nil || !entities.IsArray() {
+			return payload.Error("entities field required!")
+		}
+		settings := getInstance().Settings
+		hooks := []string{beforeEntityCreate, afterEntityCreate, afterEntityChange}
+		r := runWithHooks(adapter, settings, hooks, func(tx Adapter) moleculer.Payload {
+			if hasHooks(settings, beforeEntityCreate) {
+				list := []moleculer.Payload{}
+				for _, entity := range entities.Array() {
+					entity = callHook(settings, beforeEntityCreate, ctx, tx, entity)
+					if entity.IsError() {
+						return entity
+					}
+					list = append(list, entity)
+				}
+				entities = payload.New(list)
+			}
+			if invalid := validateEntities(settings, entities); invalid != nil {
+				return invalid
+			}
+			inserted := tx.InsertMany(stampCreateMany(settings, entities))
+			if inserted.IsError() || !hasHooks(settings, afterEntityCreate, afterEntityChange) {
+				return inserted
+			}
+			list := []moleculer.Payload{}
+			for _, entity := range inserted.Array() {
+				entity = callAfterHooks(settings, afterEntityCreate, ctx, tx, entity)
+				if entity.IsError() {
+					return entity
+				}
+				list = append(list, entity)
+			}
+			return payload.New(list)
+		})
+		r = encodeIds(settings, r)
+		if !r.IsError() {
+			ids := []interface{}{}
+			idField := idFieldName(settings)
+			r.ForEach(func(idx interface{}, item moleculer.Payload) bool {
+				ids = append(ids, item.Get(idField).Value())
+				return true
+			})
+			publishEvent(ctx, getInstance, "insertMany", "insertedMany", map[string]interface{}{
+				"ids":      ids,
+				"entities": r.Value(),
+			})
+		}
+		return r
+	}
+}
+
+// hasFilter return true when the params have a non empty query or search, or ids when withIds is true.
+// updateMany and removeMany require a filter, so a request with an empty one can not change all the records.
+func hasFilter(params moleculer.Payload, withIds bool) bool {
+	query := params.Get("query")
+	if query.Exists() && (!query.IsMap() || len(query.RawMap()) > 0) {
+		return true
+	}
+	if search := params.Get("search"); search.Exists() && search.String() != "" {
+		return true
+	}
+	return withIds && params.Get("ids").IsArray() && params.Get("ids").Len() > 0
+}
+
+//updateManyAction
+func updateManyAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		if params == nil || !params.Exists() {
+			return payload.Error("params cannot be empty!")
+		}
+		if !params.Get("update").Exists() {
+			return payload.Error("update field required!")
+		}
+		if !hasFilter(params, false) {
+			return payload.Error("query or search field required!")
+		}
+		settings := getInstance().Settings
+		hooks := []string{beforeEntityUpdate, afterEntityUpdate, afterEntityChange}
+		r := runWithHooks(adapter, settings, hooks, func(tx Adapter) moleculer.Payload {
+			query := callHook(settings, beforeEntityUpdate, ctx, tx, excludeDeleted(settings, decodeParams(settings, params)))
+			if query.IsError() {
+				return query
+			}
+			if invalid := validateEntity(settings, query.Get("update"), true); invalid != nil {
+				return invalid
+			}
+			r := tx.UpdateMany(query.Remove("update").Add("update", stampUpdate(settings, query.Get("update")).Value()))
+			return callAfterHooks(settings, afterEntityUpdate, ctx, tx, r)
+		})
+		if !r.IsError() {
+			publishEvent(ctx, getInstance, "updateMany", "updatedMany", map[string]interface{}{
+				"query":         payloadValue(params.Get("query")),
+				"search":        payloadValue(params.Get("search")),
+				"update":        params.Get("update").Value(),
+				"modifiedCount": r.Get("modifiedCount").Value(),
+			})
+		}
+		return r
+	}
+}
+
+//removeManyAction
+func removeManyAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		if params == nil || !params.Exists() {
+			return payload.Error("params cannot be empty!")
+		}
+		if !hasFilter(params, true) {
+			return payload.Error("query, search or ids field required!")
 		}
-		r := adapter.RemoveById(params.Get("id"))
+		settings := getInstance().Settings
+		hooks := []string{beforeEntityRemove, afterEntityRemove, afterEntityChange}
+		r := runWithHooks(adapter, settings, hooks, func(tx Adapter) moleculer.Payload {
+			decoded := callHook(settings, beforeEntityRemove, ctx, tx, decodeParams(settings, params))
+			if decoded.IsError() {
+				return decoded
+			}
+			var r moleculer.Payload
+			if softDeleteEnabled(settings) {
+				r = softRemoveMany(settings, tx, decoded)
+			} else {
+				r = tx.RemoveMany(decoded)
+			}
+			if r.IsError() {
+				return payload.Error("Could not remove records. Error: ", r.Error().Error())
+			}
+			return callAfterHooks(settings, afterEntityRemove, ctx, tx, r)
+		})
 		if r.IsError() {
-			return payload.Error("Could not remove record. Error: ", r.Error().Error())
+			return r
 		}
-		event := getInstance().Name + ".removed"
-		ctx.Broadcast(event, params.Get("id").String())
-		return params.Add("deletedCount", r.Get("deletedCount"))
+		publishEvent(ctx, getInstance, "removeMany", "removedMany", map[string]interface{}{
+			"query":        payloadValue(params.Get("query")),
+			"search":       payloadValue(params.Get("search")),
+			"ids":          payloadValue(params.Get("ids")),
+			"deletedCount": r.Get("deletedCount").Value(),
+		})
+		return r
 	}
 }
 
@@ -148,6 +586,11 @@ func removeAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema)
 func listAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
 	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
 		var rows moleculer.Payload
+		settings := getInstance().Settings
+		params = callHook(settings, beforeQuery, ctx, adapter, excludeDeleted(settings, decodeParams(settings, params)))
+		if params.IsError() {
+			return params
+		}
 		pageSize := getInstance().Settings["pageSize"].(int)
 		if params.Get("pageSize").Exists() {
 			pageSize = params.Get("pageSize").Int()
@@ -164,12 +607,12 @@ func listAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) mo
 		wg := sync.WaitGroup{}
 		wg.Add(1)
 		go func() {
-			limit := page * pageSize
 			offset := (page - 1) * pageSize
-			rows = adapter.Find(params.AddMany(map[string]interface{}{
-				"limit":  limit,
+			rows = adapter.Find(payload.Empty().AddMany(params.RawMap()).AddMany(map[string]interface{}{
+				"limit":  pageSize,
 				"offset": offset,
 			}))
+			rows = callHook(settings, afterQuery, ctx, adapter, rows)
 			wg.Done()
 		}()
 		if !total.Exists() {
@@ -180,7 +623,7 @@ func listAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) mo
 			(total.Float() + float64(pageSize) - 1.0) / float64(pageSize))
 
 		return map[string]interface{}{
-			"rows":       rows,
+			"rows":       encodeIds(getInstance().Settings, rows),
 			"total":      total,
 			"page":       page,
 			"pageSize":   pageSize,
@@ -193,18 +636,39 @@ func listAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) mo
 func getAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
 	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
 		var result moleculer.Payload
-		if params.Get("id").Exists() {
-			result = adapter.FindById(params.Get("id"))
-		} else if params.Get("ids").Exists() && params.Get("ids").IsArray() {
-			result = adapter.FindByIds(params.Get("ids"))
+		settings := getInstance().Settings
+		_, decode := idCodec(settings)
+		decoded, received := decodeParams(settings, params), map[string]string(nil)
+		if params.Get("storedIds").Bool() && params.Get("ids").IsArray() {
+			decoded, received = decodeStoredIds(settings, params)
+		}
+		if decoded.IsError() {
+			return decoded
+		}
+		if id := paramsId(settings, decoded); id.Exists() {
+			result = adapter.FindById(id)
+		} else if decoded.Get("ids").Exists() && decoded.Get("ids").IsArray() {
+			result = adapter.FindByIds(decoded.Get("ids"))
 		} else if params.Exists() && params.String() != "" {
-			result = adapter.FindById(params)
+			id := decode(params)
+			if id.IsError() {
+				return id
+			}
+			result = adapter.FindById(id)
 		} else {
 			return payload.Error("Invalid parameter. Action get requires the parameter id or ids!")
 		}
 		if result.IsError() {
 			return payload.Error("Could not get record. Error: ", result.Error().Error())
 		}
+		result = withoutDeleted(settings, result)
+		result = callHook(settings, afterQuery, ctx, adapter, result)
+		if result.IsError() {
+			return result
+		}
+		if params.Get("mapping").Bool() && result.IsArray() {
+			return mapByID(settings, result, transformResult(ctx, params, result, getInstance), received)
+		}
 		return transformResult(ctx, params, result, getInstance)
 	}
 }
@@ -281,9 +745,7 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 						query        map[string]interface{} `optional:"true"`
 					}{},
 				},
-				Handler: func(ctx moleculer.Context, params moleculer.Payload) interface{} {
-					return adapter.Count(params)
-				},
+				Handler: countAction(adapter, getInstance),
 			},
 			//list action
 			{
@@ -307,20 +769,81 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 				},
 				Handler: listAction(adapter, getInstance),
 			},
+			//scroll action
+			{
+				Name: "scroll",
+				Settings: map[string]interface{}{
+					"cache": map[string]interface{}{
+						"keys": []string{"populate", "fields", "cursor", "pageSize", "sort", "search", "searchFields", "query"},
+					},
+				},
+				Schema: moleculer.ObjectSchema{
+					struct {
+						populate     []string               `optional:"true"`
+						fields       []string               `optional:"true"`
+						cursor       string                 `optional:"true"`
+						pageSize     int                    `optional:"true" min:"0"`
+						sort         string                 `optional:"true"`
+						search       string                 `optional:"true"`
+						searchFields []string               `optional:"true"`
+						query        map[string]interface{} `optional:"true"`
+					}{},
+				},
+				Handler: scrollAction(adapter, getInstance),
+			},
+			//aggregate action
+			{
+				Name: "aggregate",
+				Settings: map[string]interface{}{
+					"cache": map[string]interface{}{
+						"keys": []string{"groupBy", "metrics", "search", "searchFields", "query"},
+					},
+				},
+				Schema: moleculer.ObjectSchema{
+					struct {
+						groupBy      []string               `optional:"true"`
+						metrics      map[string]interface{} `optional:"true"`
+						search       string                 `optional:"true"`
+						searchFields []string               `optional:"true"`
+						query        map[string]interface{} `optional:"true"`
+					}{},
+				},
+				Handler: aggregateAction(adapter, getInstance),
+			},
+			//distinct action
+			{
+				Name: "distinct",
+				Settings: map[string]interface{}{
+					"cache": map[string]interface{}{
+						"keys": []string{"field", "search", "searchFields", "query"},
+					},
+				},
+				Schema: moleculer.ObjectSchema{
+					struct {
+						field        string
+						search       string                 `optional:"true"`
+						searchFields []string               `optional:"true"`
+						query        map[string]interface{} `optional:"true"`
+					}{},
+				},
+				Handler: distinctAction(adapter, getInstance),
+			},
 			//get action
 			{
 				Name: "get",
 				Settings: map[string]interface{}{
 					"cache": map[string]interface{}{
-						"keys": []string{"populate", "fields", "id", "mapping"},
+						"keys": []string{"populate", "populateDepth", "fields", "id", "ids", "mapping", "storedIds"},
 					},
 				},
 				Schema: moleculer.ObjectSchema{
 					struct {
-						populate []string `optional:"true"`
-						fields   []string `optional:"true"`
-						ids      []string
-						mapping  bool `optional:"true"`
+						populate      []string `optional:"true"`
+						populateDepth int      `optional:"true"`
+						fields        []string `optional:"true"`
+						ids           []string
+						mapping       bool `optional:"true"`
+						storedIds     bool `optional:"true"`
 					}{},
 				},
 				Handler: getAction(adapter, getInstance),
@@ -350,6 +873,58 @@ func Mixin(adapter Adapter) moleculer.Mixin {
 				},
 				Handler: removeAction(adapter, getInstance),
 			},
+			//restore action
+			{
+				Name: "restore",
+				Schema: moleculer.ObjectSchema{
+					struct {
+						id string
+					}{},
+				},
+				Handler: restoreAction(adapter, getInstance),
+			},
+			//purge action
+			{
+				Name: "purge",
+				Schema: moleculer.ObjectSchema{
+					struct {
+						id  string   `optional:"true"`
+						ids []string `optional:"true"`
+					}{},
+				},
+				Handler: purgeAction(adapter, getInstance),
+			},
+			//insertMany action
+			{
+				Name:    "insertMany",
+				Handler: insertManyAction(adapter, getInstance),
+			},
+			//updateMany action
+			{
+				Name: "updateMany",
+				Schema: moleculer.ObjectSchema{
+					struct {
+						search       string                 `optional:"true"`
+						searchFields []string               `optional:"true"`
+						update       map[string]interface{} `optional:"false"`
+						query        map[string]interface{} `optional:"true"`
+					}{},
+				},
+				Handler: updateManyAction(adapter, getInstance),
+			},
+			//removeMany action
+			{
+				Name: "removeMany",
+				Schema: moleculer.ObjectSchema{
+					struct {
+						ids          []string               `optional:"true"`
+						search       string                 `optional:"true"`
+						searchFields []string               `optional:"true"`
+						query        map[string]interface{} `optional:"true"`
+					}{},
+				},
+				Handler: removeManyAction(adapter, getInstance),
+			},
 			//findAndUpdate Action
 			{
 				Name: "findAndUpdate",
@@ -432,111 +1007,190 @@ func actionFromPopulate(config interface{}) string {
 	return pconfig.String()
 }
 
-// addIds add params to from the parent record, to filter the child records.
-func addIds(params, item moleculer.Payload, field string) moleculer.Payload {
-	fvalue := item.Get(field)
-	if fvalue.IsArray() {
-		return params.Add("ids", fvalue.StringArray())
-	}
-	return params.Add("id", fvalue.String())
+// populateRule is a field of the populate param resolved with the populates setting.
+type populateRule struct {
+	// field path of the ids in the records, a key of the populates setting.
+	field  string
+	action string
+	params moleculer.Payload
+	// nested fields to populate in the populated records.
+	nested []string
 }
 
-// createPopulateCall add populates call to mcalls params for a given item.
-func createPopulateCall(calls map[string]map[string]interface{}, item moleculer.Payload, populates map[string]interface{}, fields []string) {
-	id := item.Get("id").String()
+// populateRules resolves the fields of the populate param. A field is a key of the populates setting,
+// that can be the dot path of a nested field (meta.reviewer), followed by the fields to populate in the
+// populated records (author.company populates the author and the company of the author).
+func populateRules(fields []string, populates map[string]interface{}) []populateRule {
+	rules := []populateRule{}
+	index := map[string]int{}
 	for _, field := range fields {
-		if !item.Get(field).Exists() {
-			continue
+		parts := strings.Split(field, ".")
+		for i := len(parts); i > 0; i-- {
+			key := strings.Join(parts[:i], ".")
+			config, hasConfig := populates[key]
+			if !hasConfig || actionFromPopulate(config) == "" {
+				continue
+			}
+			position, exists := index[key]
+			if !exists {
+				position = len(rules)
+				index[key] = position
+				rules = append(rules, populateRule{
+					field:  key,
+					action: actionFromPopulate(config),
+					params: actionParamsFromPopulate(config),
+				})
+			}
+			if i < len(parts) {
+				rules[position].nested = append(rules[position].nested, strings.Join(parts[i:], "."))
+			}
+			break
 		}
-		config, hasConfig := populates[field]
-		if !hasConfig {
-			continue
+	}
+	return rules
+}
+
+// populateIds return the distinct ids of the field in the records, in order.
+func populateIds(records []moleculer.Payload, field string) []string {
+	ids := []string{}
+	found := map[string]bool{}
+	add := func(id moleculer.Payload) {
+		if !id.Exists() || id.IsMap() || id.IsArray() || id.Value() == nil {
+			return
 		}
-		action := actionFromPopulate(config)
-		if action == "" {
-			continue
+		if key := id.String(); key != "" && !found[key] {
+			found[key] = true
+			ids = append(ids, key)
 		}
-		mcallName := id + "_" + field + "_" + action
-		actionParams := actionParamsFromPopulate(config)
-		actionParams = addIds(actionParams, item, field)
-		calls[mcallName] = map[string]interface{}{
-			"action": action,
-			"params": actionParams,
+	}
+	for _, record := range records {
+		value := record.Get(field)
+		if value.IsArray() {
+			for _, id := range value.Array() {
+				add(id)
+			}
+		} else {
+			add(value)
 		}
 	}
+	return ids
 }
 
-//scenarios
-//list of ids
-// user.friends = ["id_1", "id_2", "id_3"]
-//by the id of the owner
-// user.id is the filter
-// user.comments -> loaded from the comments service. comments.byUserId
-// if is a list of users.. then collect all ids and make a single call.
-func createPopulateMCalls(result, params moleculer.Payload, populates map[string]interface{}, fields []string) map[string]map[string]interface{} {
+// createPopulateMCalls return the MCall params to populate the records: a single call to the action of each rule
+// with the ids of all the records, mapping: true and storedIds: true, so the target service accepts the raw ids
+// stored in the records when it has a decodeID setting. The populate of the populated records is sent with
+// populateDepth, so the target service stops at its maxPopulateDepth.
+func createPopulateMCalls(records []moleculer.Payload, rules []populateRule, depth int) map[string]map[string]interface{} {
 	calls := map[string]map[string]interface{}{}
-	if result.IsArray() {
-		result.ForEach(func(_ interface{}, item moleculer.Payload) bool {
-			createPopulateCall(calls, item, populates, fields)
-			return true
-		})
-	} else {
-		createPopulateCall(calls, result, populates, fields)
+	for _, rule := range rules {
+		ids := populateIds(records, rule.field)
+		if len(ids) == 0 {
+			continue
+		}
+		params := payload.Empty()
+		if rule.params.IsMap() {
+			params = params.AddMany(rule.params.RawMap())
+		}
+		populate := sortEntries(params.Get("populate"))
+		for _, field := range rule.nested {
+			if !contains(populate, field) {
+				populate = append(populate, field)
+			}
+		}
+		params = params.Remove("populate", "id", "ids", "mapping", "storedIds", "populateDepth")
+		if len(populate) > 0 {
+			params = params.Add("populate", populate).Add("populateDepth", depth+1)
+		}
+		calls[rule.field] = map[string]interface{}{
+			"action": rule.action,
+			"params": params.Add("ids", ids).Add("mapping", true).Add("storedIds", true),
+		}
 	}
 	return calls
 }
 
-// populateSingleRecordWithResults populate a single record with the populate values from the Mcall result.
-func populateSingleRecordWithResults(populates map[string]interface{}, item moleculer.Payload, mcalls map[string]moleculer.Payload, fields []string) moleculer.Payload {
-	id := item.Get("id").String()
-	for _, field := range fields {
-		config, hasConfig := populates[field]
-		if !hasConfig {
-			continue
+// setPath return a copy of the record with the value in the field, a dot path of a nested field.
+func setPath(record moleculer.Payload, field string, value interface{}) moleculer.Payload {
+	parts := strings.SplitN(field, ".", 2)
+	if len(parts) == 2 {
+		parent := record.Get(parts[0])
+		if !parent.IsMap() {
+			return record
 		}
-		action := actionFromPopulate(config)
-		if action == "" {
+		value = setPath(parent, parts[1], value).RawMap()
+	}
+	return record.Remove(parts[0]).Add(parts[0], value)
+}
+
+// populateRecord replaces the ids in the fields of the rules by the records of the mapping results.
+// A missing record is null in a single field and is left out of a list. When the call of a rule failed
+// the ids are kept.
+func populateRecord(record moleculer.Payload, rules []populateRule, results map[string]moleculer.Payload) moleculer.Payload {
+	for _, rule := range rules {
+		mapping, hasResult := results[rule.field]
+		value := record.Get(rule.field)
+		if !hasResult || mapping == nil || mapping.IsError() || !value.Exists() {
 			continue
 		}
-		mcallName := id + "_" + field + "_" + action
-		populateResult := mcalls[mcallName]
-		if item.Get(field).Exists() {
-			item = item.Remove(field)
+		mapped := mapping.RawMap()
+		if value.IsArray() {
+			list := []interface{}{}
+			for _, id := range value.Array() {
+				if target, found := mapped[id.String()]; found && target != nil {
+					list = append(list, target)
+				}
+			}
+			record = setPath(record, rule.field, list)
+		} else {
+			record = setPath(record, rule.field, mapped[value.String()])
 		}
-		item = item.Add(field, populateResult.Value())
 	}
-	return item
+	return record
 }
 
-// populateRecordsWithResults populate one record or multiple with the populatye values from the Mcall result.
-func populateRecordsWithResults(populates map[string]interface{}, result moleculer.Payload, mcalls map[string]moleculer.Payload, fields []string) moleculer.Payload {
-	if result.IsArray() {
-		list := []moleculer.Payload{}
-		result.ForEach(func(index interface{}, item moleculer.Payload) bool {
-			list = append(list, populateSingleRecordWithResults(populates, item, mcalls, fields))
-			return true
-		})
-		return payload.New(list)
-	} else {
-		return populateSingleRecordWithResults(populates, result, mcalls, fields)
+// populateDepthLimit return the maxPopulateDepth setting.
+func populateDepthLimit(settings map[string]interface{}) int {
+	if depth, ok := settings["maxPopulateDepth"].(int); ok {
+		return depth
 	}
+	return maxPopulateDepth
 }
 
 // populateFields populate fields on the results.
-func populateFields(ctx moleculer.Context, result, params moleculer.Payload, populates map[string]interface{}) moleculer.Payload {
-	if !params.Get("populate").Exists() {
+func populateFields(ctx moleculer.Context, result, params moleculer.Payload, populates map[string]interface{}, maxDepth int) moleculer.Payload {
+	if !params.Get("populate").Exists() || result == nil || result.IsError() || !result.Exists() {
 		return result
 	}
-	var fields []string
-	if params.Get("populate").IsArray() {
-		fields = params.Get("populate").StringArray()
-	} else {
-		fields = []string{params.Get("populate").String()}
+	depth := params.Get("populateDepth").Int()
+	if depth < 0 {
+		//a negative depth would bypass the maxPopulateDepth
+		depth = 0
+	}
+	if depth >= maxDepth {
+		ctx.Logger().Warn("populate ignored - populateDepth: ", depth, " reached the maxPopulateDepth: ", maxDepth)
+		return result
 	}
-	mparams := createPopulateMCalls(result, params, populates, fields)
-	if len(mparams) > 0 {
-		mcalls := <-ctx.MCall(mparams)
-		result = populateRecordsWithResults(populates, result, mcalls, fields)
+	rules := populateRules(sortEntries(params.Get("populate")), populates)
+	records := []moleculer.Payload{result}
+	if result.IsArray() {
+		records = result.Array()
+	}
+	mparams := createPopulateMCalls(records, rules, depth)
+	if len(mparams) == 0 {
+		return result
+	}
+	mcalls := <-ctx.MCall(mparams)
+	for field, mcall := range mcalls {
+		if mcall.IsError() {
+			ctx.Logger().Warn("could not populate the field: ", field, " - error: ", mcall.Error())
+		}
+	}
+	list := []moleculer.Payload{}
+	for _, record := range records {
+		list = append(list, populateRecord(record, rules, mcalls))
+	}
+	if result.IsArray() {
+		return payload.New(list)
 	}
-	return result
+	return list[0]
 }
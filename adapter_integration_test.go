@@ -7,6 +7,7 @@ import (
 	"github.com/moleculer-go/cupaloy/v2"
 	"github.com/moleculer-go/moleculer"
 	"github.com/moleculer-go/moleculer/broker"
+	"github.com/moleculer-go/moleculer/payload"
 	store "github.com/moleculer-go/store"
 	"github.com/moleculer-go/store/mocks"
 	"github.com/moleculer-go/store/mongo"
@@ -124,6 +125,50 @@ var _ = Describe("Moleculer DB Integration Tests", func() {
 				Expect(user.Get("friends").Array()[1].Get("name").String()).Should(Equal(maria.Get("name").String()))
 			})
 
+			It("find should populate the nested fields of the populated records", func() {
+				users := <-bkr.Call("user.find", map[string]interface{}{
+					"query":    map[string]interface{}{"lastname": "Travolta"},
+					"populate": []string{"friends.master"},
+				})
+				Expect(users.Error()).Should(BeNil())
+				Expect(users.Len()).Should(Equal(1))
+				friends := users.First().Get("friends")
+				Expect(friends.Len()).Should(Equal(2))
+				Expect(friends.Array()[0].Get("name").String()).Should(Equal(johnSnow.Get("name").String()))
+				Expect(friends.Array()[0].Get("master").Exists()).Should(BeFalse())
+				Expect(friends.Array()[1].Get("name").String()).Should(Equal(maria.Get("name").String()))
+				Expect(friends.Array()[1].Get("master").Get("lastname").String()).Should(Equal(johnSnow.Get("lastname").String()))
+			})
+
+			It("get should stop the populate of populated records at the maxPopulateDepth", func() {
+				updated := <-bkr.Call("user.update", map[string]interface{}{
+					"id":     johnSnow.Get("id").String(),
+					"master": johnT.Get("id").String(),
+				})
+				Expect(updated.Error()).Should(BeNil())
+				user := <-bkr.Call("user.get", map[string]interface{}{
+					"id":       johnT.Get("id").String(),
+					"populate": []string{"master.master.master.master"},
+				})
+				Expect(user.Error()).Should(BeNil())
+				Expect(user.Get("master").Get("lastname").String()).Should(Equal("Snow"))
+				Expect(user.Get("master").Get("master").Get("lastname").String()).Should(Equal("Travolta"))
+				Expect(user.Get("master").Get("master").Get("master").Get("lastname").String()).Should(Equal("Snow"))
+				Expect(user.Get("master").Get("master").Get("master").Get("master").String()).Should(Equal(johnT.Get("id").String()))
+			})
+
+			It("get should leave out the populated records that do not exist", func() {
+				removed := <-bkr.Call("user.remove", map[string]interface{}{"id": maria.Get("id").String()})
+				Expect(removed.Error()).Should(BeNil())
+				user := <-bkr.Call("user.get", map[string]interface{}{
+					"id":       johnT.Get("id").String(),
+					"populate": []string{"friends"},
+				})
+				Expect(user.Error()).Should(BeNil())
+				Expect(user.Get("friends").Len()).Should(Equal(1))
+				Expect(user.Get("friends").First().Get("id").String()).Should(Equal(johnSnow.Get("id").String()))
+			})
+
 		})
 	}
 
@@ -169,6 +214,24 @@ var _ = Describe("Moleculer DB Integration Tests", func() {
 				Expect(snap.SnapshotMulti(label+"-list-rows", cleanResult(rs.Get("rows").Remove("id")))).Should(Succeed())
 			})
 
+			It("scroll records", func() {
+				ages := []int{}
+				params := map[string]interface{}{"sort": "-age", "pageSize": 4}
+				for page := 0; page < 3; page++ {
+					rs := <-bkr.Call("user.scroll", params)
+					Expect(rs.Error()).Should(BeNil())
+					rs.Get("rows").ForEach(func(_ interface{}, row moleculer.Payload) bool {
+						ages = append(ages, row.Get("age").Int())
+						return true
+					})
+					if !rs.Get("cursor").Exists() {
+						break
+					}
+					params = map[string]interface{}{"cursor": rs.Get("cursor").String(), "pageSize": 4}
+				}
+				Expect(ages).Should(Equal([]int{75, 65, 46, 25, 13, 13}))
+			})
+
 			It("create a record", func() {
 				rs := <-bkr.Call("user.create", map[string]interface{}{"name": "Ze", "lastname": "DoCaixao"})
 				Expect(rs.Error()).Should(BeNil())
@@ -268,4 +331,96 @@ var _ = Describe("Moleculer DB Integration Tests", func() {
 		}
 	})
 
+	Context("SQLite-Adapter populates with HashIDs", func() {
+		hashIDs := store.HashIDs{Salt: "populates"}
+		var johnSnow, maria, johnT, post moleculer.Payload
+		var bkr *broker.ServiceBroker
+		BeforeEach(func() {
+			ready := make(chan bool, 2)
+			bkr = broker.New(&moleculer.Config{
+				DiscoverNodeID: func() string { return "node_hashids_populates" },
+				LogLevel:       logLevel,
+			})
+			users := &sqlite.Adapter{
+				URI:     "file:memory:?mode=memory",
+				Table:   "users_hashids",
+				Columns: cols,
+			}
+			posts := &sqlite.Adapter{
+				URI:     "file:memory:?mode=memory",
+				Table:   "posts_hashids",
+				Columns: []sqlite.Column{{Name: "title", Type: "string"}, {Name: "author", Type: "string"}},
+			}
+			bkr.Publish(moleculer.ServiceSchema{
+				Name: "user",
+				Settings: map[string]interface{}{
+					"encodeID":  hashIDs.EncodeID,
+					"decodeID":  hashIDs.DecodeID,
+					"populates": map[string]interface{}{"friends": "user.get", "master": "user.get"},
+				},
+				Mixins: []moleculer.Mixin{store.Mixin(users)},
+				Started: func(c moleculer.BrokerContext, svc moleculer.ServiceSchema) {
+					johnSnow, maria, johnT = mocks.LoadUsers(users)
+					ready <- true
+				},
+			}, moleculer.ServiceSchema{
+				Name: "post",
+				Settings: map[string]interface{}{
+					"encodeID":  hashIDs.EncodeID,
+					"decodeID":  hashIDs.DecodeID,
+					"populates": map[string]interface{}{"author": "user.get"},
+				},
+				Mixins: []moleculer.Mixin{store.Mixin(posts)},
+				Started: func(c moleculer.BrokerContext, svc moleculer.ServiceSchema) {
+					posts.RemoveAll()
+					ready <- true
+				},
+			})
+			bkr.Start()
+			<-ready
+			<-ready
+			//the reference is stored with the raw id of the user
+			post = posts.Insert(payload.New(map[string]interface{}{"title": "Winter", "author": johnT.Get("id").String()}))
+		})
+
+		AfterEach(func() {
+			bkr.Stop()
+		})
+
+		It("get should populate the raw ids stored in the records", func() {
+			r := <-bkr.Call("post.get", map[string]interface{}{
+				"id":       hashIDs.EncodeID(post.Get("id")).String(),
+				"populate": []string{"author"},
+			})
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("author").Get("lastname").String()).Should(Equal("Travolta"))
+			Expect(r.Get("author").Get("id").String()).Should(Equal(hashIDs.EncodeID(johnT.Get("id")).String()))
+
+			r = <-bkr.Call("user.get", map[string]interface{}{
+				"id":       hashIDs.EncodeID(johnT.Get("id")).String(),
+				"populate": []string{"master", "friends"},
+			})
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("master").Get("lastname").String()).Should(Equal("Snow"))
+			Expect(r.Get("friends").Len()).Should(Equal(2))
+			Expect(r.Get("friends").Array()[0].Get("id").String()).Should(Equal(hashIDs.EncodeID(johnSnow.Get("id")).String()))
+			Expect(r.Get("friends").Array()[1].Get("id").String()).Should(Equal(hashIDs.EncodeID(maria.Get("id")).String()))
+		})
+
+		It("find should populate the encoded ids and the nested raw ids", func() {
+			updated := <-bkr.Call("post.update", map[string]interface{}{
+				"id":     hashIDs.EncodeID(post.Get("id")).String(),
+				"author": hashIDs.EncodeID(johnT.Get("id")).String(),
+			})
+			Expect(updated.Error()).Should(BeNil())
+			r := <-bkr.Call("post.find", map[string]interface{}{
+				"populate": []string{"author.master"},
+			})
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("author").Get("lastname").String()).Should(Equal("Travolta"))
+			Expect(r.First().Get("author").Get("master").Get("lastname").String()).Should(Equal("Snow"))
+		})
+	})
+
 })
@@ -1,6 +1,9 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/moleculer-go/moleculer"
@@ -20,6 +23,15 @@ func contextAndDelegated(nodeID string, config moleculer.Config) (moleculer.Brok
 
 type M map[string]interface{}
 
+// noTransactionAdapter is a MemoryAdapter that cannot begin transactions, like a standalone Mongo server.
+type noTransactionAdapter struct {
+	*MemoryAdapter
+}
+
+func (adapter noTransactionAdapter) Begin() (Transaction, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
 var _ = Describe("Moleculer DB Mixin", func() {
 
 	Describe("list action", func() {
@@ -53,6 +65,151 @@ var _ = Describe("Moleculer DB Mixin", func() {
 			Expect(pl.Get("total").Int()).Should(Equal(2))
 			Expect(pl.Get("totalPages").Int()).Should(Equal(1))
 		})
+
+		It("should return pageSize rows of the page", func() {
+			list := listAction(adapter, func() *moleculer.ServiceSchema { return svc })
+			pl := payload.New(list(ctx.(moleculer.Context), payload.New(M{"sort": "age", "page": 2, "pageSize": 2})))
+			Expect(pl.Get("rows").Len()).Should(Equal(2))
+			Expect(pl.Get("rows").First().Get("lastname").String()).Should(Equal("Snow"))
+			Expect(pl.Get("total").Int()).Should(Equal(6))
+			Expect(pl.Get("totalPages").Int()).Should(Equal(3))
+		})
+	})
+
+	Describe("scroll action", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+
+		BeforeEach(func() {
+			mocks.ConnectAndLoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+		svc := &moleculer.ServiceSchema{
+			Settings: Mixin(adapter).Settings,
+		}
+		ctx, _ := contextAndDelegated("scroll-test", moleculer.Config{})
+		scroll := scrollAction(adapter, func() *moleculer.ServiceSchema { return svc })
+
+		scrollAll := func(params M) []string {
+			lastnames := []string{}
+			for page := 0; page < 10; page++ {
+				pl := payload.New(scroll(ctx.(moleculer.Context), payload.New(params)))
+				Expect(pl.IsError()).Should(BeFalse())
+				pl.Get("rows").ForEach(func(_ interface{}, row moleculer.Payload) bool {
+					lastnames = append(lastnames, row.Get("lastname").String())
+					return true
+				})
+				if !pl.Get("cursor").Exists() {
+					break
+				}
+				params = M{"cursor": pl.Get("cursor").String(), "pageSize": params["pageSize"], "query": params["query"]}
+			}
+			return lastnames
+		}
+
+		It("should return all records, page by page, in the sort order", func() {
+			lastnames := scrollAll(M{"sort": "-age", "pageSize": 2})
+			Expect(lastnames[:4]).Should(Equal([]string{"Claire", "Travolta", "Assange", "Snow"}))
+			Expect(lastnames[4:]).Should(ConsistOf("Pan", "Man"))
+		})
+
+		It("should page over records with the same and with missing sort values", func() {
+			lastnames := scrollAll(M{"sort": "master age", "pageSize": 2})
+			Expect(len(lastnames)).Should(Equal(6))
+			Expect(lastnames[4:]).Should(Equal([]string{"Travolta", "Claire"}))
+
+			lastnames = scrollAll(M{"sort": "-master", "pageSize": 4, "query": M{"age": M{"$gt": 20}}})
+			Expect(len(lastnames)).Should(Equal(4))
+		})
+
+		It("should return an error for an invalid cursor", func() {
+			pl := payload.New(scroll(ctx.(moleculer.Context), payload.New(M{"cursor": "invalid"})))
+			Expect(pl.IsError()).Should(BeTrue())
+		})
+
+		It("should return an error for a tampered cursor", func() {
+			for _, token := range []string{`{"s":["id"],"a":[{}]}`, `{"s":["id"],"a":[{"$t":1}]}`, `{"s":["id"],"a":[]}`} {
+				pl := payload.New(scroll(ctx.(moleculer.Context), payload.New(M{"cursor": base64.RawURLEncoding.EncodeToString([]byte(token))})))
+				Expect(pl.IsError()).Should(BeTrue())
+				Expect(pl.Error().Error()).Should(HavePrefix("Invalid cursor"))
+			}
+		})
+	})
+
+	Describe("aggregate action", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+
+		BeforeEach(func() {
+			mocks.ConnectAndLoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+		svc := &moleculer.ServiceSchema{
+			Settings: Mixin(adapter).Settings,
+		}
+		ctx, _ := contextAndDelegated("aggregate-test", moleculer.Config{})
+		aggregate := aggregateAction(adapter, func() *moleculer.ServiceSchema { return svc })
+
+		It("should return the metrics of each group", func() {
+			r := payload.New(aggregate(ctx.(moleculer.Context), payload.New(M{
+				"groupBy": "name",
+				"metrics": M{"users": M{"$count": "*"}, "age": M{"$avg": "age"}},
+				"query":   M{"name": M{"$in": []string{"John", "Marie"}}},
+			})))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(2))
+			Expect(r.First().Get("name").String()).Should(Equal("John"))
+			Expect(r.First().Get("users").Int()).Should(Equal(2))
+			Expect(r.First().Get("age").Float()).Should(Equal(45.0))
+		})
+
+		It("should return an error for invalid metrics", func() {
+			r := payload.New(aggregate(ctx.(moleculer.Context), payload.New(M{"metrics": M{"users": M{"median": "age"}}})))
+			Expect(r.IsError()).Should(BeTrue())
+			r = payload.New(aggregate(ctx.(moleculer.Context), payload.New(M{"metrics": M{"drop table": M{"count": "*"}}})))
+			Expect(r.IsError()).Should(BeTrue())
+		})
+	})
+
+	Describe("distinct action", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+
+		BeforeEach(func() {
+			mocks.ConnectAndLoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+		svc := &moleculer.ServiceSchema{
+			Settings: Mixin(adapter).Settings,
+		}
+		ctx, _ := contextAndDelegated("distinct-test", moleculer.Config{})
+		distinct := distinctAction(adapter, func() *moleculer.ServiceSchema { return svc })
+
+		It("should return the distinct values of the records matching the query", func() {
+			r := payload.New(distinct(ctx.(moleculer.Context), payload.New(M{
+				"field": "name",
+				"query": M{"age": M{"$lt": 50}},
+			})))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.StringArray()).Should(Equal([]string{"John", "Julian", "Peter", "Stone"}))
+		})
+
+		It("should return an error for an invalid field", func() {
+			r := payload.New(distinct(ctx.(moleculer.Context), payload.New(M{"field": "name; drop table"})))
+			Expect(r.IsError()).Should(BeTrue())
+		})
 	})
 
 	Describe("find action", func() {
@@ -174,187 +331,116 @@ var _ = Describe("Moleculer DB Mixin", func() {
 			Expect(params.Get("fields").StringArray()).Should(Equal([]string{"name", "email"}))
 		})
 
-		It("addIds should extract params for the parent records that are required to filter the child records.", func() {
-			params := payload.Empty()
-			item := payload.New(M{"friends": []string{"123", "213", "321"}})
-			field := "friends"
-			r := addIds(params, item, field)
-			Expect(r.Exists()).Should(BeTrue())
-			Expect(r.Get("ids").Exists()).Should(BeTrue())
-			Expect(r.Get("ids").StringArray()).Should(Equal([]string{"123", "213", "321"}))
-
-			item = payload.New(M{"author": "123"})
-			field = "author"
-			r = addIds(params, item, field)
-			Expect(r.Exists()).Should(BeTrue())
-			Expect(r.Get("id").Exists()).Should(BeTrue())
-			Expect(r.Get("id").String()).Should(Equal("123"))
+		It("populateRules should resolve the dot paths with the populates setting", func() {
+			populates := M{
+				"author":        "users.get",
+				"meta.reviewer": M{"action": "users.get", "params": M{"fields": []string{"name"}}},
+				"voters":        "",
+			}
+			rules := populateRules([]string{"author.company", "meta.reviewer", "author.friends.company", "voters", "unknown"}, populates)
+			Expect(len(rules)).Should(Equal(2))
+			Expect(rules[0].field).Should(Equal("author"))
+			Expect(rules[0].action).Should(Equal("users.get"))
+			Expect(rules[0].nested).Should(Equal([]string{"company", "friends.company"}))
+			Expect(rules[1].field).Should(Equal("meta.reviewer"))
+			Expect(rules[1].params.Get("fields").StringArray()).Should(Equal([]string{"name"}))
+			Expect(rules[1].nested).Should(BeNil())
 		})
 
-		It("createPopulateMCalls should deal with a single result, simple config from settings", func() {
-
-			userID := "12345"
-			result := payload.New(M{
-				"id":     userID,
-				"master": "222",
-			})
-			params := payload.New(M{"": ""})
-			settingsPopulates := M{"master": "users.get"}
-			mcalls := createPopulateMCalls(result, params, settingsPopulates, []string{"master"})
-
-			r := payload.New(mcalls)
-			Expect(r.Get(userID + "_master_users.get").Exists()).Should(BeTrue())
-			Expect(r.Get(userID + "_master_users.get").Get("action").Exists()).Should(BeTrue())
-			Expect(r.Get(userID + "_master_users.get").Get("action").String()).Should(Equal("users.get"))
-			Expect(r.Get(userID + "_master_users.get").Get("params").Exists()).Should(BeTrue())
-			Expect(r.Get(userID + "_master_users.get").Get("params").Get("id").Exists()).Should(BeTrue())
-			Expect(r.Get(userID + "_master_users.get").Get("params").Get("id").String()).Should(Equal("222"))
-		})
-
-		It("createPopulateMCalls should deal with a single result, simple config from settings, multiple ids to fetch", func() {
-			userID := "12345"
-			result := payload.New(M{
-				"id":      userID,
-				"friends": []string{"222", "333"},
-			})
-			params := payload.New(M{"": ""})
-			settingsPopulates := M{"friends": "users.get"}
-			mcalls := createPopulateMCalls(result, params, settingsPopulates, []string{"friends"})
-
-			r := payload.New(mcalls)
-			Expect(r.Get(userID + "_friends_users.get").Exists()).Should(BeTrue())
-			Expect(r.Get(userID + "_friends_users.get").Get("action").Exists()).Should(BeTrue())
-			Expect(r.Get(userID + "_friends_users.get").Get("action").String()).Should(Equal("users.get"))
-			Expect(r.Get(userID + "_friends_users.get").Get("params").Exists()).Should(BeTrue())
-			Expect(r.Get(userID + "_friends_users.get").Get("params").Get("ids").Exists()).Should(BeTrue())
-			Expect(r.Get(userID + "_friends_users.get").Get("params").Get("ids").IsArray()).Should(BeTrue())
-			Expect(r.Get(userID + "_friends_users.get").Get("params").Get("ids").StringArray()).Should(Equal([]string{"222", "333"}))
-		})
-
-		It("createPopulateMCalls should deal with a multiple results, simple config from settings, multiple ids to fetch", func() {
-			userID1 := "666"
-			user1 := payload.New(M{
-				"id":      userID1,
-				"friends": []string{"222", "333"},
-			})
-
-			userID2 := "222"
-			user2 := payload.New(M{
-				"id":      userID2,
-				"friends": []string{"666", "888"},
-			})
-
-			params := payload.New(M{"": ""})
-			settingsPopulates := M{"friends": "users.get"}
-
-			users := payload.New([]moleculer.Payload{user1, user2})
-			mcalls := createPopulateMCalls(users, params, settingsPopulates, []string{"friends"})
+		It("populateIds should dedupe the ids of all the records", func() {
+			records := []moleculer.Payload{
+				payload.New(M{"id": "1", "friends": []string{"222", "333"}, "master": "222"}),
+				payload.New(M{"id": "2", "friends": []string{"333", "444"}}),
+				payload.New(M{"id": "3", "master": nil}),
+			}
+			Expect(populateIds(records, "friends")).Should(Equal([]string{"222", "333", "444"}))
+			Expect(populateIds(records, "master")).Should(Equal([]string{"222"}))
+			Expect(populateIds(records, "other")).Should(BeEmpty())
+		})
 
-			r := payload.New(mcalls)
-			Expect(r.Get(userID1 + "_friends_users.get").Exists()).Should(BeTrue())
-			Expect(r.Get(userID1 + "_friends_users.get").Get("action").Exists()).Should(BeTrue())
-			Expect(r.Get(userID1 + "_friends_users.get").Get("action").String()).Should(Equal("users.get"))
-			Expect(r.Get(userID1 + "_friends_users.get").Get("params").Exists()).Should(BeTrue())
-			Expect(r.Get(userID1 + "_friends_users.get").Get("params").Get("ids").Exists()).Should(BeTrue())
-			Expect(r.Get(userID1 + "_friends_users.get").Get("params").Get("ids").IsArray()).Should(BeTrue())
-			Expect(r.Get(userID1 + "_friends_users.get").Get("params").Get("ids").StringArray()).Should(Equal([]string{"222", "333"}))
+		It("createPopulateMCalls should create a single get call per field for all the records", func() {
+			records := []moleculer.Payload{
+				payload.New(M{"id": "666", "friends": []string{"222", "333"}, "master": "222"}),
+				payload.New(M{"id": "222", "friends": []string{"666", "333"}, "master": "222"}),
+			}
+			rules := populateRules([]string{"friends", "master"}, M{"friends": "users.get", "master": "users.get"})
+			mcalls := createPopulateMCalls(records, rules, 0)
+
+			Expect(len(mcalls)).Should(Equal(2))
+			Expect(mcalls["friends"]["action"]).Should(Equal("users.get"))
+			friends := mcalls["friends"]["params"].(moleculer.Payload)
+			Expect(friends.Get("ids").StringArray()).Should(Equal([]string{"222", "333", "666"}))
+			Expect(friends.Get("mapping").Bool()).Should(BeTrue())
+			Expect(friends.Get("storedIds").Bool()).Should(BeTrue())
+			Expect(friends.Get("populate").Exists()).Should(BeFalse())
+			Expect(friends.Get("populateDepth").Exists()).Should(BeFalse())
+			master := mcalls["master"]["params"].(moleculer.Payload)
+			Expect(master.Get("ids").StringArray()).Should(Equal([]string{"222"}))
+		})
 
-			Expect(r.Get(userID2 + "_friends_users.get").Exists()).Should(BeTrue())
-			Expect(r.Get(userID2 + "_friends_users.get").Get("action").Exists()).Should(BeTrue())
-			Expect(r.Get(userID2 + "_friends_users.get").Get("action").String()).Should(Equal("users.get"))
-			Expect(r.Get(userID2 + "_friends_users.get").Get("params").Exists()).Should(BeTrue())
-			Expect(r.Get(userID2 + "_friends_users.get").Get("params").Get("ids").Exists()).Should(BeTrue())
-			Expect(r.Get(userID2 + "_friends_users.get").Get("params").Get("ids").IsArray()).Should(BeTrue())
-			Expect(r.Get(userID2 + "_friends_users.get").Get("params").Get("ids").StringArray()).Should(Equal([]string{"666", "888"}))
+		It("createPopulateMCalls should send the nested populate with the populate depth", func() {
+			records := []moleculer.Payload{payload.New(M{"id": "1", "author": "10"})}
+			populates := M{"author": M{"action": "users.get", "params": M{"populate": "avatar", "fields": []string{"name"}}}}
+			mcalls := createPopulateMCalls(records, populateRules([]string{"author.company"}, populates), 1)
+			params := mcalls["author"]["params"].(moleculer.Payload)
+			Expect(params.Get("populate").StringArray()).Should(Equal([]string{"avatar", "company"}))
+			Expect(params.Get("populateDepth").Int()).Should(Equal(2))
+			Expect(params.Get("fields").StringArray()).Should(Equal([]string{"name"}))
+			Expect(params.Get("ids").StringArray()).Should(Equal([]string{"10"}))
+			Expect(populates["author"].(M)["params"].(M)["populate"]).Should(Equal("avatar"))
 		})
 
-		It("populateSingleRecordWithResults should populate the array fields with results of MCall", func() {
+		It("populateFields should not accept a negative populateDepth", func() {
+			ctx, _ := contextAndDelegated("populate-depth-test", moleculer.Config{})
+			result := payload.New(M{"id": "1", "master": "2"})
+			params := payload.New(M{"populate": "master", "populateDepth": -5})
+			r := populateFields(ctx.(moleculer.Context), result, params, M{"master": "users.get"}, 0)
+			Expect(r.Get("master").String()).Should(Equal("2"))
+		})
 
-			populates := M{"friends": "users.get"}
-			result := payload.New(M{
+		It("populateRecord should populate the fields with the mapping results", func() {
+			rules := populateRules([]string{"friends", "master", "meta.reviewer"}, M{
+				"friends":       "users.get",
+				"master":        "users.get",
+				"meta.reviewer": "users.get",
+			})
+			record := payload.New(M{
 				"id":      "12345",
-				"friends": []string{"444", "555"},
+				"friends": []string{"444", "999", "555"},
+				"master":  "444",
+				"meta":    M{"reviewer": "555", "score": 3},
 			})
-			calls := map[string]moleculer.Payload{
-				"12345_friends_users.get": payload.New([]moleculer.Payload{
-					payload.New(M{
-						"id":   "444",
-						"name": "Yoda",
-					}),
-					payload.New(M{
-						"id":   "555",
-						"name": "Musk",
-					}),
-				}),
-			}
-			r := populateSingleRecordWithResults(populates, result, calls, []string{"friends"})
-			Expect(r.Exists()).Should(BeTrue())
-			Expect(r.Error()).Should(BeNil())
+			mapping := payload.New(M{
+				"444": M{"id": "444", "name": "Yoda"},
+				"555": M{"id": "555", "name": "Musk"},
+			})
+			results := map[string]moleculer.Payload{"friends": mapping, "master": mapping, "meta.reviewer": mapping}
+			r := populateRecord(record, rules, results)
 			Expect(r.Get("id").String()).Should(Equal("12345"))
-			Expect(r.Get("friends").IsArray()).Should(BeTrue())
 			Expect(r.Get("friends").Len()).Should(Equal(2))
-			Expect(r.Get("friends").Array()[0].Get("id").String()).Should(Equal("444"))
 			Expect(r.Get("friends").Array()[0].Get("name").String()).Should(Equal("Yoda"))
-			Expect(r.Get("friends").Array()[1].Get("id").String()).Should(Equal("555"))
 			Expect(r.Get("friends").Array()[1].Get("name").String()).Should(Equal("Musk"))
-		})
-
-		It("populateSingleRecordWithResults should populate the single fields with results of MCall", func() {
-
-			populates := M{"master": "users.get"}
-			result := payload.New(M{
-				"id":     "12345",
-				"master": "444",
-			})
-			calls := map[string]moleculer.Payload{
-				"12345_master_users.get": payload.New(M{
-					"id":   "444",
-					"name": "Yoda",
-				}),
-			}
-			r := populateSingleRecordWithResults(populates, result, calls, []string{"master"})
-			Expect(r.Exists()).Should(BeTrue())
-			Expect(r.Error()).Should(BeNil())
-			Expect(r.Get("id").String()).Should(Equal("12345"))
-			Expect(r.Get("master").IsMap()).Should(BeTrue())
-			Expect(r.Get("master").Get("id").String()).Should(Equal("444"))
 			Expect(r.Get("master").Get("name").String()).Should(Equal("Yoda"))
+			Expect(r.Get("meta.reviewer.name").String()).Should(Equal("Musk"))
+			Expect(r.Get("meta.score").Int()).Should(Equal(3))
+			Expect(record.Get("master").String()).Should(Equal("444"))
 		})
 
-		It("populateRecordsWithResults should populate multiple record with the results of the populate MCall", func() {
-
-			populates := M{"master": "users.get"}
-			result := payload.New([]M{M{
-				"id":     "12345",
-				"master": "444",
-			},
-				M{
-					"id":     "6789",
-					"master": "555",
-				}})
-			calls := map[string]moleculer.Payload{
-				"12345_master_users.get": payload.New(M{
-					"id":   "444",
-					"name": "Yoda",
-				}),
-				"6789_master_users.get": payload.New(M{
-					"id":   "555",
-					"name": "Gandalf",
-				}),
-			}
-			r := populateRecordsWithResults(populates, result, calls, []string{"master"})
-			Expect(r.Exists()).Should(BeTrue())
-			Expect(r.IsArray()).Should(BeTrue())
-			Expect(r.Error()).Should(BeNil())
-			Expect(r.First().Get("id").String()).Should(Equal("12345"))
-			Expect(r.First().Get("master").IsMap()).Should(BeTrue())
-			Expect(r.First().Get("master").Get("id").String()).Should(Equal("444"))
-			Expect(r.First().Get("master").Get("name").String()).Should(Equal("Yoda"))
+		It("populateRecord should handle missing records and failed calls", func() {
+			rules := populateRules([]string{"friends", "master"}, M{"friends": "users.get", "master": "users.get"})
+			record := payload.New(M{"id": "12345", "friends": []string{"444"}, "master": "444"})
+			r := populateRecord(record, rules, map[string]moleculer.Payload{
+				"friends": payload.New(M{}),
+				"master":  payload.New(M{}),
+			})
+			Expect(r.Get("friends").Len()).Should(Equal(0))
+			Expect(r.RawMap()).Should(HaveKeyWithValue("master", BeNil()))
 
-			Expect(r.Array()[1].Get("id").String()).Should(Equal("6789"))
-			Expect(r.Array()[1].Get("master").IsMap()).Should(BeTrue())
-			Expect(r.Array()[1].Get("master").Get("id").String()).Should(Equal("555"))
-			Expect(r.Array()[1].Get("master").Get("name").String()).Should(Equal("Gandalf"))
+			r = populateRecord(record, rules, map[string]moleculer.Payload{
+				"friends": payload.Error("Service not found: users"),
+			})
+			Expect(r.Get("friends").StringArray()).Should(Equal([]string{"444"}))
+			Expect(r.Get("master").String()).Should(Equal("444"))
 		})
 	})
 
@@ -456,7 +542,7 @@ var _ = Describe("Moleculer DB Mixin", func() {
 
 			time.Sleep(time.Millisecond * 100)
 			Expect(broadCastReceived).ShouldNot(BeNil())
-			Expect(broadCastReceived.Payload().String()).Should(Equal(r.Get("id").String()))
+			Expect(broadCastReceived.Payload().Get("id").String()).Should(Equal(r.Get("id").String()))
 
 			fr := adapter.FindById(r.Get("id"))
 			Expect(fr.Get("name").String()).Should(Equal("Michael"))
@@ -483,7 +569,7 @@ var _ = Describe("Moleculer DB Mixin", func() {
 		AfterEach(func() {
 			adapter.Disconnect()
 		})
-		update := updateAction(adapter, func() *moleculer.ServiceSchema { return &moleculer.ServiceSchema{} })
+		update := updateAction(adapter, func() *moleculer.ServiceSchema { return &moleculer.ServiceSchema{Name: "user"} })
 
 		It("should fail when missing id param", func() {
 			r := update(ctx.(moleculer.Context), payload.New(map[string]interface{}{"name": "Santa"})).(moleculer.Payload)
@@ -514,7 +600,14 @@ var _ = Describe("Moleculer DB Mixin", func() {
 
 			time.Sleep(time.Millisecond * 100)
 			Expect(broadCastReceived).ShouldNot(BeNil())
-			Expect(broadCastReceived.Payload().String()).Should(Equal(r.Get("id").String()))
+			Expect(broadCastReceived.EventName()).Should(Equal("user.updated"))
+			event := broadCastReceived.Payload()
+			Expect(event.Get("id").String()).Should(Equal(r.Get("id").String()))
+			Expect(event.Get("entity").Get("lastname").String()).Should(Equal("Stark"))
+			Expect(event.Get("previous").Get("lastname").String()).Should(Equal("Snow"))
+			Expect(event.Get("changes").Len()).Should(Equal(1))
+			Expect(event.Get("changes").Get("lastname").Get("from").String()).Should(Equal("Snow"))
+			Expect(event.Get("changes").Get("lastname").Get("to").String()).Should(Equal("Stark"))
 
 			fr := adapter.FindById(johnSnow.Get("id"))
 			Expect(fr.Get("name").String()).Should(Equal("John"))
@@ -574,7 +667,7 @@ var _ = Describe("Moleculer DB Mixin", func() {
 
 			time.Sleep(time.Millisecond * 100)
 			Expect(broadCastReceived).ShouldNot(BeNil())
-			Expect(broadCastReceived.Payload().String()).Should(Equal(r.Get("id").String()))
+			Expect(broadCastReceived.Payload().Get("id").String()).Should(Equal(r.Get("id").String()))
 
 			ct := adapter.Count(payload.Empty()).Int()
 			Expect(ct).Should(Equal(total - 1))
@@ -593,7 +686,7 @@ var _ = Describe("Moleculer DB Mixin", func() {
 
 			time.Sleep(time.Millisecond * 100)
 			Expect(broadCastReceived).ShouldNot(BeNil())
-			Expect(broadCastReceived.Payload().String()).Should(Equal(r.Get("id").String()))
+			Expect(broadCastReceived.Payload().Get("id").String()).Should(Equal(r.Get("id").String()))
 
 			ct = adapter.Count(payload.Empty()).Int()
 			Expect(ct).Should(Equal(total - 2))
@@ -612,7 +705,7 @@ var _ = Describe("Moleculer DB Mixin", func() {
 
 			time.Sleep(time.Millisecond * 100)
 			Expect(broadCastReceived).ShouldNot(BeNil())
-			Expect(broadCastReceived.Payload().String()).Should(Equal(r.Get("id").String()))
+			Expect(broadCastReceived.Payload().Get("id").String()).Should(Equal(r.Get("id").String()))
 
 			ct = adapter.Count(payload.Empty()).Int()
 			Expect(ct).Should(Equal(total - 3))
@@ -623,4 +716,803 @@ var _ = Describe("Moleculer DB Mixin", func() {
 
 	})
 
+	Describe("bulk actions", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+		ctx, delegates := contextAndDelegated("bulk-test", moleculer.Config{})
+		broadcasts := make(chan moleculer.BrokerContext, 10)
+		delegates.BroadcastEvent = func(context moleculer.BrokerContext) {
+			broadcasts <- context
+		}
+		BeforeEach(func() {
+			mocks.ConnectAndLoadUsers(adapter)
+		})
+
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+		svc := func() *moleculer.ServiceSchema { return &moleculer.ServiceSchema{Name: "user"} }
+
+		It("insertMany should insert all entities and broadcast one event with the ids", func() {
+			insertMany := insertManyAction(adapter, svc)
+			r := insertMany(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"entities": []map[string]interface{}{
+					{"name": "Michael", "lastname": "Jackson"},
+					{"name": "Janet", "lastname": "Jackson"},
+				},
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(2))
+
+			event := <-broadcasts
+			Expect(event.EventName()).Should(Equal("user.insertedMany"))
+			Expect(event.Payload().Get("ids").StringArray()).Should(Equal([]string{
+				r.Array()[0].Get("id").String(),
+				r.Array()[1].Get("id").String(),
+			}))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(8))
+
+			r = insertMany(ctx.(moleculer.Context), payload.New(map[string]interface{}{})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(r.Error().Error()).Should(Equal("entities field required!"))
+		})
+
+		It("updateMany should update the matching records and broadcast one event", func() {
+			updateMany := updateManyAction(adapter, svc)
+			r := updateMany(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query":  map[string]interface{}{"name": "John"},
+				"update": map[string]interface{}{"lastname": "Doe"},
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("modifiedCount").Int()).Should(Equal(2))
+
+			event := <-broadcasts
+			Expect(event.EventName()).Should(Equal("user.updatedMany"))
+			Expect(event.Payload().Get("modifiedCount").Int()).Should(Equal(2))
+
+			r = updateMany(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"update": map[string]interface{}{"lastname": "Doe"},
+			})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(r.Error().Error()).Should(Equal("query or search field required!"))
+		})
+
+		It("removeMany should remove the matching records and broadcast one event", func() {
+			removeMany := removeManyAction(adapter, svc)
+			r := removeMany(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"search":       "John",
+				"searchFields": []string{"name"},
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("deletedCount").Int()).Should(Equal(2))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(4))
+
+			event := <-broadcasts
+			Expect(event.EventName()).Should(Equal("user.removedMany"))
+			Expect(event.Payload().Get("deletedCount").Int()).Should(Equal(2))
+
+			r = removeMany(ctx.(moleculer.Context), payload.New(map[string]interface{}{"name": "John"})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(r.Error().Error()).Should(Equal("query, search or ids field required!"))
+
+			r = removeMany(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query": map[string]interface{}{},
+			})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(r.Error().Error()).Should(Equal("query, search or ids field required!"))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(4))
+		})
+	})
+
+	Describe("encodeID and decodeID", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+		ctx, delegates := contextAndDelegated("encode-test", moleculer.Config{})
+		delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+		svc := &moleculer.ServiceSchema{
+			Name: "user",
+			Settings: map[string]interface{}{
+				"encodeID": func(id moleculer.Payload) moleculer.Payload {
+					return payload.New("user-" + id.String())
+				},
+				"decodeID": func(id moleculer.Payload) moleculer.Payload {
+					return payload.New(strings.TrimPrefix(id.String(), "user-"))
+				},
+			},
+		}
+		getInstance := func() *moleculer.ServiceSchema { return svc }
+		var johnSnow moleculer.Payload
+		BeforeEach(func() {
+			johnSnow, _, _ = mocks.ConnectAndLoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should encode the ids returned and decode the ids received", func() {
+			encodedID := "user-" + johnSnow.Get("id").String()
+
+			rs := findAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query": map[string]interface{}{"id": encodedID},
+			})).(moleculer.Payload)
+			Expect(rs.Len()).Should(Equal(1))
+			Expect(rs.First().Get("id").String()).Should(Equal(encodedID))
+
+			r := getAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": encodedID,
+			})).(moleculer.Payload)
+			Expect(r.Get("name").String()).Should(Equal("John"))
+			Expect(r.Get("id").String()).Should(Equal(encodedID))
+
+			r = updateAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id":       encodedID,
+				"lastname": "Stark",
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("id").String()).Should(Equal(encodedID))
+
+			//the adapter keeps the raw id
+			Expect(adapter.FindById(johnSnow.Get("id")).Get("lastname").String()).Should(Equal("Stark"))
+
+			r = removeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": encodedID,
+			})).(moleculer.Payload)
+			Expect(r.Get("deletedCount").Int()).Should(Equal(1))
+		})
+
+		It("create should return the encoded id", func() {
+			r := createAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "Michael",
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(strings.HasPrefix(r.Get("id").String(), "user-")).Should(BeTrue())
+			rawID := strings.TrimPrefix(r.Get("id").String(), "user-")
+			Expect(adapter.FindById(payload.New(rawID)).Get("name").String()).Should(Equal("Michael"))
+		})
+	})
+
+	Describe("HashIDs decodeID", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+		ctx, delegates := contextAndDelegated("hashids-test", moleculer.Config{})
+		delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+		hashIDs := HashIDs{Salt: "test salt"}
+		var removing moleculer.Payload
+		svc := &moleculer.ServiceSchema{
+			Name: "user",
+			Settings: map[string]interface{}{
+				"encodeID": hashIDs.EncodeID,
+				"decodeID": hashIDs.DecodeID,
+				"beforeEntityRemove": func(ctx moleculer.Context, tx Adapter, params moleculer.Payload) moleculer.Payload {
+					removing = tx.FindById(params.Get("id"))
+					return nil
+				},
+			},
+		}
+		getInstance := func() *moleculer.ServiceSchema { return svc }
+		var rawID moleculer.Payload
+		BeforeEach(func() {
+			adapter.Connect()
+			adapter.RemoveAll()
+			rawID = adapter.Insert(payload.New(map[string]interface{}{"id": 5, "name": "John"})).Get("id")
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should reject the raw ids", func() {
+			encodedID := hashIDs.EncodeID(rawID).String()
+			r := getAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": encodedID,
+			})).(moleculer.Payload)
+			Expect(r.Get("name").String()).Should(Equal("John"))
+
+			for _, id := range []interface{}{5, "5"} {
+				r = getAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+					"id": id,
+				})).(moleculer.Payload)
+				Expect(r.IsError()).Should(BeTrue())
+				Expect(r.Error().Error()).Should(Equal("Invalid id: 5"))
+			}
+			r = getAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"ids": []interface{}{encodedID, 5},
+			})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+
+			r = removeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": 5,
+			})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+			rs := findAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query": map[string]interface{}{"id": 5},
+			})).(moleculer.Payload)
+			Expect(rs.IsError()).Should(BeTrue())
+			Expect(adapter.FindById(rawID).Get("name").String()).Should(Equal("John"))
+		})
+
+		It("should decode the id param once", func() {
+			hashIDs := HashIDs{Salt: "test salt"}
+			settings := map[string]interface{}{"decodeID": hashIDs.DecodeID}
+			params := decodeParams(settings, payload.New(map[string]interface{}{"id": hashIDs.EncodeID(payload.New(5)).String()}))
+			Expect(params.Error()).Should(BeNil())
+			Expect(params.Get("id").Int()).Should(Equal(5))
+		})
+
+		It("beforeEntityRemove should receive the decoded id", func() {
+			r := removeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": hashIDs.EncodeID(rawID).String(),
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("deletedCount").Int()).Should(Equal(1))
+			Expect(removing.Get("name").String()).Should(Equal("John"))
+		})
+	})
+
+	Describe("idField setting", func() {
+		settings := map[string]interface{}{"idField": "uuid"}
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+		adapter.Init(nil, settings)
+		ctx, delegates := contextAndDelegated("id-field-test", moleculer.Config{})
+		events := []string{}
+		delegates.BroadcastEvent = func(context moleculer.BrokerContext) {
+			events = append(events, context.Payload().Get("id").String())
+		}
+		svc := &moleculer.ServiceSchema{
+			Name:     "user",
+			Settings: settings,
+		}
+		getInstance := func() *moleculer.ServiceSchema { return svc }
+		BeforeEach(func() {
+			events = []string{}
+			adapter.Connect()
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should use the idField in all actions and events", func() {
+			r := createAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "John",
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("id").Exists()).Should(BeFalse())
+			uuid := r.Get("uuid").String()
+			Expect(uuid).ShouldNot(BeEmpty())
+			Expect(events).Should(Equal([]string{uuid}))
+
+			r = getAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"uuid": uuid,
+			})).(moleculer.Payload)
+			Expect(r.Get("name").String()).Should(Equal("John"))
+
+			r = updateAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"uuid":     uuid,
+				"lastname": "Snow",
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("uuid").String()).Should(Equal(uuid))
+			Expect(adapter.FindById(payload.New(uuid)).Get("lastname").String()).Should(Equal("Snow"))
+
+			rs := findAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query": map[string]interface{}{"uuid": uuid},
+			})).(moleculer.Payload)
+			Expect(rs.Len()).Should(Equal(1))
+
+			r = removeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"uuid": uuid,
+			})).(moleculer.Payload)
+			Expect(r.Get("deletedCount").Int()).Should(Equal(1))
+			Expect(events[len(events)-1]).Should(Equal(uuid))
+		})
+
+		It("should map the records of the get action by the idField", func() {
+			uuids := []string{}
+			for _, name := range []string{"John", "Marie"} {
+				r := createAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+					"name": name,
+				})).(moleculer.Payload)
+				uuids = append(uuids, r.Get("uuid").String())
+			}
+			r := getAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"ids":     append(uuids, "missing"),
+				"mapping": true,
+			})).(moleculer.Payload)
+			Expect(r.IsMap()).Should(BeTrue())
+			Expect(r.Len()).Should(Equal(2))
+			Expect(r.Get(uuids[0]).Get("name").String()).Should(Equal("John"))
+			Expect(r.Get(uuids[1]).Get("name").String()).Should(Equal("Marie"))
+		})
+	})
+
+	Describe("hooks", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+		ctx, delegates := contextAndDelegated("hooks-test", moleculer.Config{})
+		delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+		changes := []string{}
+		svc := &moleculer.ServiceSchema{
+			Name: "user",
+			Settings: map[string]interface{}{
+				"beforeEntityCreate": func(ctx moleculer.Context, tx Adapter, entity moleculer.Payload) moleculer.Payload {
+					return entity.Add("slug", strings.ToLower(entity.Get("name").String()))
+				},
+				"afterEntityCreate": Hook(func(ctx moleculer.Context, tx Adapter, entity moleculer.Payload) moleculer.Payload {
+					if entity.Get("name").String() == "Abort" {
+						return payload.Error("create aborted!")
+					}
+					return nil
+				}),
+				"afterEntityChange": func(ctx moleculer.Context, tx Adapter, result moleculer.Payload) moleculer.Payload {
+					changes = append(changes, result.Get("name").String())
+					return nil
+				},
+				"beforeEntityUpdate": func(ctx moleculer.Context, tx Adapter, params moleculer.Payload) moleculer.Payload {
+					return params.Add("updatedBy", "hook")
+				},
+				"beforeEntityRemove": func(ctx moleculer.Context, tx Adapter, params moleculer.Payload) moleculer.Payload {
+					if tx.FindById(params.Get("id")).Get("name").String() == "John" {
+						return payload.Error("John cannot be removed!")
+					}
+					return nil
+				},
+				"beforeQuery": func(ctx moleculer.Context, tx Adapter, params moleculer.Payload) moleculer.Payload {
+					return params.Add("query", map[string]interface{}{"lastname": "Snow"})
+				},
+			},
+		}
+		getInstance := func() *moleculer.ServiceSchema { return svc }
+		var johnSnow moleculer.Payload
+		BeforeEach(func() {
+			changes = []string{}
+			johnSnow, _, _ = mocks.ConnectAndLoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("before hooks should change the entity and after hooks should abort the action", func() {
+			r := createAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "Michael",
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("slug").String()).Should(Equal("michael"))
+			Expect(changes).Should(Equal([]string{"Michael"}))
+
+			total := adapter.Count(payload.Empty()).Int()
+			r = createAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "Abort",
+			})).(moleculer.Payload)
+			Expect(r.Error().Error()).Should(Equal("create aborted!"))
+			//the insert was rolled back
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(total))
+		})
+
+		It("update and remove hooks should change the params and abort the action", func() {
+			r := updateAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id":  johnSnow.Get("id").String(),
+				"age": 30,
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(adapter.FindById(johnSnow.Get("id")).Get("updatedBy").String()).Should(Equal("hook"))
+
+			r = removeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": johnSnow.Get("id").String(),
+			})).(moleculer.Payload)
+			Expect(r.Error().Error()).Should(Equal("John cannot be removed!"))
+			Expect(adapter.FindById(johnSnow.Get("id")).Exists()).Should(BeTrue())
+		})
+
+		It("should run the hooks without a transaction when it cannot begin", func() {
+			r := createAction(noTransactionAdapter{adapter}, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "Michael",
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("slug").String()).Should(Equal("michael"))
+			Expect(changes).Should(Equal([]string{"Michael"}))
+		})
+
+		It("beforeQuery should change the params of find and count", func() {
+			rs := findAction(adapter, getInstance)(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+			Expect(rs.Len()).Should(Equal(1))
+			Expect(rs.First().Get("name").String()).Should(Equal("John"))
+
+			count := countAction(adapter, getInstance)(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+			Expect(count.Int()).Should(Equal(1))
+		})
+	})
+
+	Describe("hooks of the bulk and soft delete actions", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+		ctx, delegates := contextAndDelegated("bulk-hooks-test", moleculer.Config{})
+		delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+		calls := []string{}
+		record := func(name string) Hook {
+			return func(ctx moleculer.Context, tx Adapter, p moleculer.Payload) moleculer.Payload {
+				calls = append(calls, name)
+				return nil
+			}
+		}
+		svc := &moleculer.ServiceSchema{
+			Name: "user",
+			Settings: map[string]interface{}{
+				"softDelete": true,
+				"beforeEntityUpdate": func(ctx moleculer.Context, tx Adapter, params moleculer.Payload) moleculer.Payload {
+					calls = append(calls, "beforeEntityUpdate")
+					if params.Get("update").Exists() {
+						return params.Add("update", params.Get("update").Add("updatedBy", "hook"))
+					}
+					return nil
+				},
+				"afterEntityUpdate":  record("afterEntityUpdate"),
+				"beforeEntityRemove": record("beforeEntityRemove"),
+				"afterEntityRemove": func(ctx moleculer.Context, tx Adapter, result moleculer.Payload) moleculer.Payload {
+					calls = append(calls, "afterEntityRemove")
+					if result.Get("deletedCount").Int() > 1 {
+						return payload.Error("only one record can be removed!")
+					}
+					return nil
+				},
+				"afterEntityChange": record("afterEntityChange"),
+			},
+		}
+		getInstance := func() *moleculer.ServiceSchema { return svc }
+		var johnSnow moleculer.Payload
+		BeforeEach(func() {
+			calls = []string{}
+			johnSnow, _, _ = mocks.ConnectAndLoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+		count := func() int {
+			return countAction(adapter, getInstance)(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload).Int()
+		}
+
+		It("findAndUpdate and updateMany should call the update hooks", func() {
+			r := findAndUpdateAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query":  map[string]interface{}{"name": "John"},
+				"update": map[string]interface{}{"age": 30},
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(2))
+			Expect(r.First().Get("updatedBy").String()).Should(Equal("hook"))
+			Expect(calls).Should(Equal([]string{"beforeEntityUpdate", "afterEntityUpdate", "afterEntityChange"}))
+
+			calls = []string{}
+			r = updateManyAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query":  map[string]interface{}{"name": "Marie"},
+				"update": map[string]interface{}{"age": 31},
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(calls).Should(Equal([]string{"beforeEntityUpdate", "afterEntityUpdate", "afterEntityChange"}))
+			marie := adapter.FindOne(payload.New(map[string]interface{}{"query": map[string]interface{}{"name": "Marie"}}))
+			Expect(marie.Get("updatedBy").String()).Should(Equal("hook"))
+		})
+
+		It("removeMany should call the remove hooks and roll back when they fail", func() {
+			total := count()
+			r := removeManyAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query": map[string]interface{}{"name": "John"},
+			})).(moleculer.Payload)
+			Expect(r.Error().Error()).Should(Equal("only one record can be removed!"))
+			Expect(calls).Should(Equal([]string{"beforeEntityRemove", "afterEntityRemove"}))
+			Expect(count()).Should(Equal(total))
+
+			calls = []string{}
+			r = removeManyAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query": map[string]interface{}{"name": "Marie"},
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(calls).Should(Equal([]string{"beforeEntityRemove", "afterEntityRemove", "afterEntityChange"}))
+			Expect(count()).Should(Equal(total - 1))
+		})
+
+		It("restore and purge should call the hooks", func() {
+			r := removeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": johnSnow.Get("id").String(),
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+
+			calls = []string{}
+			r = restoreAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": johnSnow.Get("id").String(),
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("name").String()).Should(Equal("John"))
+			Expect(calls).Should(Equal([]string{"beforeEntityUpdate", "afterEntityUpdate", "afterEntityChange"}))
+
+			removeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": johnSnow.Get("id").String(),
+			}))
+			calls = []string{}
+			r = purgeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("deletedCount").Int()).Should(Equal(1))
+			Expect(calls).Should(Equal([]string{"beforeEntityRemove", "afterEntityRemove", "afterEntityChange"}))
+		})
+	})
+
+	Describe("entity events", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+		ctx, delegates := contextAndDelegated("events-test", moleculer.Config{})
+		broadcasts := []moleculer.BrokerContext{}
+		emits := []moleculer.BrokerContext{}
+		delegates.BroadcastEvent = func(context moleculer.BrokerContext) {
+			broadcasts = append(broadcasts, context)
+		}
+		delegates.EmitEvent = func(context moleculer.BrokerContext) {
+			emits = append(emits, context)
+		}
+		settings := map[string]interface{}{
+			"entityEvents": map[string]interface{}{
+				"mode":   "emit",
+				"update": false,
+			},
+		}
+		getInstance := func() *moleculer.ServiceSchema {
+			return &moleculer.ServiceSchema{Name: "user", Settings: settings}
+		}
+		var johnSnow moleculer.Payload
+		BeforeEach(func() {
+			broadcasts = []moleculer.BrokerContext{}
+			emits = []moleculer.BrokerContext{}
+			johnSnow, _, _ = mocks.ConnectAndLoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should emit the events with the entity and the context meta", func() {
+			ctx.UpdateMeta(payload.Empty().Add("user", "admin"))
+			r := createAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "Michael",
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(broadcasts).Should(BeEmpty())
+			Expect(len(emits)).Should(Equal(1))
+			Expect(emits[0].EventName()).Should(Equal("user.created"))
+			Expect(emits[0].Payload().Get("entity").Get("name").String()).Should(Equal("Michael"))
+			Expect(emits[0].Payload().Get("meta").Get("user").String()).Should(Equal("admin"))
+
+			r = removeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": johnSnow.Get("id").String(),
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(len(emits)).Should(Equal(2))
+			Expect(emits[1].EventName()).Should(Equal("user.removed"))
+			Expect(emits[1].Payload().Get("previous").Get("name").String()).Should(Equal("John"))
+		})
+
+		It("should not publish disabled events", func() {
+			r := updateAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id":   johnSnow.Get("id").String(),
+				"name": "Johnny",
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(emits).Should(BeEmpty())
+			Expect(broadcasts).Should(BeEmpty())
+
+			settings["entityEvents"] = false
+			defer func() {
+				settings["entityEvents"] = map[string]interface{}{"mode": "emit", "update": false}
+			}()
+			r = createAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "Michael",
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(emits).Should(BeEmpty())
+		})
+	})
+
+	Describe("soft delete", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+		ctx, delegates := contextAndDelegated("soft-delete-test", moleculer.Config{})
+		delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+		svc := &moleculer.ServiceSchema{
+			Name:     "user",
+			Settings: map[string]interface{}{"softDelete": true, "pageSize": 10},
+		}
+		getInstance := func() *moleculer.ServiceSchema { return svc }
+		var johnSnow, marie moleculer.Payload
+		BeforeEach(func() {
+			johnSnow, marie, _ = mocks.ConnectAndLoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+		remove := func(id moleculer.Payload) moleculer.Payload {
+			return removeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": id.String(),
+			})).(moleculer.Payload)
+		}
+		count := func() int {
+			return countAction(adapter, getInstance)(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload).Int()
+		}
+
+		It("remove should set deletedAt and hide the record from find, count and get", func() {
+			total := count()
+			r := remove(johnSnow.Get("id"))
+			Expect(r.Get("deletedCount").Int()).Should(Equal(1))
+			Expect(adapter.FindById(johnSnow.Get("id")).Get("deletedAt").Exists()).Should(BeTrue())
+
+			Expect(count()).Should(Equal(total - 1))
+			rs := findAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query": map[string]interface{}{"name": "John"},
+			})).(moleculer.Payload)
+			Expect(rs.Len()).Should(Equal(1))
+
+			r = getAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": johnSnow.Get("id").String(),
+			})).(moleculer.Payload)
+			Expect(r.Get("name").Exists()).Should(BeFalse())
+
+			list := payload.New(listAction(adapter, getInstance)(ctx.(moleculer.Context), payload.Empty()))
+			Expect(list.Get("total").Int()).Should(Equal(total - 1))
+
+			//removing again does not change anything
+			r = remove(johnSnow.Get("id"))
+			Expect(r.Get("deletedCount").Int()).Should(Equal(0))
+		})
+
+		It("restore should bring back a deleted record", func() {
+			total := count()
+			remove(johnSnow.Get("id"))
+			r := restoreAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": johnSnow.Get("id").String(),
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("name").String()).Should(Equal("John"))
+			Expect(count()).Should(Equal(total))
+
+			r = restoreAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": marie.Get("id").String(),
+			})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+		})
+
+		It("purge should remove the deleted records", func() {
+			total := adapter.Count(payload.Empty()).Int()
+			remove(johnSnow.Get("id"))
+			remove(marie.Get("id"))
+			r := purgeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id": johnSnow.Get("id").String(),
+			})).(moleculer.Payload)
+			Expect(r.Get("deletedCount").Int()).Should(Equal(1))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(total - 1))
+
+			r = purgeAction(adapter, getInstance)(ctx.(moleculer.Context), payload.Empty()).(moleculer.Payload)
+			Expect(r.Get("deletedCount").Int()).Should(Equal(1))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(total - 2))
+		})
+
+		It("removeMany should soft delete the matching records", func() {
+			total := count()
+			r := removeManyAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query": map[string]interface{}{"name": "John"},
+			})).(moleculer.Payload)
+			Expect(r.Get("deletedCount").Int()).Should(Equal(2))
+			Expect(count()).Should(Equal(total - 2))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(total))
+		})
+
+		It("removeMany should not soft delete all the records with an empty query", func() {
+			total := count()
+			r := removeManyAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query": map[string]interface{}{},
+				"ids":   []string{},
+			})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(count()).Should(Equal(total))
+		})
+	})
+
+	Describe("timestamps and versioning", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+		ctx, delegates := contextAndDelegated("versioning-test", moleculer.Config{})
+		delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+		svc := &moleculer.ServiceSchema{
+			Name:     "user",
+			Settings: map[string]interface{}{"timestamps": true, "versioning": true},
+		}
+		getInstance := func() *moleculer.ServiceSchema { return svc }
+		BeforeEach(func() {
+			mocks.ConnectAndLoadUsers(adapter)
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+		update := func(params map[string]interface{}) moleculer.Payload {
+			return updateAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(params)).(moleculer.Payload)
+		}
+
+		It("create should set createdAt, updatedAt and version", func() {
+			r := createAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "Arya",
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("createdAt").Exists()).Should(BeTrue())
+			Expect(r.Get("updatedAt").Exists()).Should(BeTrue())
+			Expect(r.Get("version").Int()).Should(Equal(1))
+		})
+
+		It("update should increment the version and fail with a conflict on a stale version", func() {
+			arya := createAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "Arya",
+			})).(moleculer.Payload)
+			id := arya.Get("id").String()
+
+			r := update(map[string]interface{}{"id": id, "lastname": "Stark", "version": 1})
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("version").Int()).Should(Equal(2))
+			Expect(r.Get("lastname").String()).Should(Equal("Stark"))
+			Expect(r.Get("createdAt").Value()).Should(Equal(arya.Get("createdAt").Value()))
+
+			r = update(map[string]interface{}{"id": id, "lastname": "Snow", "version": 1})
+			Expect(r.IsError()).Should(BeTrue())
+			conflict, isConflict := r.Error().(VersionConflictError)
+			Expect(isConflict).Should(BeTrue())
+			Expect(conflict.Current).Should(Equal(int64(2)))
+			Expect(adapter.FindById(arya.Get("id")).Get("lastname").String()).Should(Equal("Stark"))
+
+			//without version the current version is incremented
+			r = update(map[string]interface{}{"id": id, "lastname": "Snow"})
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Get("version").Int()).Should(Equal(3))
+		})
+
+		It("update should not apply when the version changed after it was read", func() {
+			arya := createAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "Arya",
+			})).(moleculer.Payload)
+			adapter.UpdateById(arya.Get("id"), payload.New(map[string]interface{}{"version": 5}))
+			r := updateByIdVersion(adapter, arya.Get("id"), 1, payload.New(map[string]interface{}{"name": "No one", "version": 2}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Exists()).Should(BeFalse())
+			Expect(adapter.FindById(arya.Get("id")).Get("name").String()).Should(Equal("Arya"))
+		})
+
+		It("findAndUpdate should stamp and version all matching records", func() {
+			r := findAndUpdateAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query":  map[string]interface{}{"name": "John"},
+				"update": map[string]interface{}{"lastname": "Doe"},
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(2))
+			for _, item := range r.Array() {
+				Expect(item.Get("version").Int()).Should(Equal(1))
+				Expect(item.Get("updatedAt").Exists()).Should(BeTrue())
+			}
+
+			r = findAndUpdateAction(adapter, getInstance)(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"query":   map[string]interface{}{"name": "John"},
+				"update":  map[string]interface{}{"lastname": "Snow"},
+				"version": 5,
+			})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(adapter.Find(payload.New(map[string]interface{}{"query": map[string]interface{}{"lastname": "Doe"}})).Len()).Should(Equal(2))
+		})
+	})
 })
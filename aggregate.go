@@ -0,0 +1,246 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// Aggregate functions of the aggregate action.
+const (
+	AggCount = "count"
+	AggSum   = "sum"
+	AggAvg   = "avg"
+	AggMin   = "min"
+	AggMax   = "max"
+)
+
+// Metric is an aggregate function of a field returned as Name. Count without Field counts the records,
+// with a Field counts the records where the field is not null.
+type Metric struct {
+	Name  string
+	Func  string
+	Field string
+}
+
+// Aggregation is the group by fields and the metrics of the aggregate action, parsed by ParseAggregation.
+type Aggregation struct {
+	GroupBy []string
+	Metrics []Metric
+}
+
+// AggregateAdapter is implemented by adapters that calculate the aggregation in the database.
+type AggregateAdapter interface {
+	Adapter
+	// Aggregate returns one record per group, sorted by the group by fields, with the group by fields and the metrics
+	// of the records matching the params (query, search and searchFields). Without group by returns a single record.
+	Aggregate(params moleculer.Payload, aggregation Aggregation) moleculer.Payload
+}
+
+// validName the metric names and fields are used as column aliases and document keys.
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseAggregation parses the groupBy and metrics params. Example:
+//
+//	{
+//		"groupBy": []string{"status"},
+//		"metrics": {"orders": {"count": "*"}, "total": {"sum": "amount"}, "biggest": {"$max": "amount"}},
+//	}
+//
+// The functions are count, sum, avg, min and max (also with $). Without metrics the records are counted as count.
+func ParseAggregation(params moleculer.Payload) (Aggregation, error) {
+	aggregation := Aggregation{GroupBy: sortEntries(params.Get("groupBy")), Metrics: []Metric{}}
+	for _, field := range aggregation.GroupBy {
+		if !validName.MatchString(field) {
+			return Aggregation{}, errors.New(fmt.Sprint("Invalid groupBy field: ", field))
+		}
+	}
+	metrics := params.Get("metrics")
+	if !metrics.Exists() {
+		aggregation.Metrics = append(aggregation.Metrics, Metric{Name: AggCount, Func: AggCount})
+		return aggregation, nil
+	}
+	if !metrics.IsMap() {
+		return Aggregation{}, errors.New("Invalid metrics! It must be an object.")
+	}
+	for _, entry := range sortedEntries(metrics) {
+		metric, err := parseMetric(entry.key, entry.value)
+		if err != nil {
+			return Aggregation{}, err
+		}
+		for _, field := range aggregation.GroupBy {
+			if field == metric.Name {
+				return Aggregation{}, errors.New(fmt.Sprint("Invalid metric name: ", metric.Name, " is a groupBy field."))
+			}
+		}
+		aggregation.Metrics = append(aggregation.Metrics, metric)
+	}
+	return aggregation, nil
+}
+
+// parseMetric parses one metric: "name": {"func": "field"}.
+func parseMetric(name string, value moleculer.Payload) (Metric, error) {
+	if !validName.MatchString(name) {
+		return Metric{}, errors.New(fmt.Sprint("Invalid metric name: ", name))
+	}
+	if !value.IsMap() || value.Len() != 1 {
+		return Metric{}, errors.New(fmt.Sprint("Invalid metric: ", name, " it must be an object with one function."))
+	}
+	entry := sortedEntries(value)[0]
+	metric := Metric{Name: name, Func: strings.TrimPrefix(strings.ToLower(entry.key), "$"), Field: entry.value.String()}
+	switch metric.Func {
+	case AggCount:
+		if metric.Field == "*" || metric.Field == "" {
+			metric.Field = ""
+			return metric, nil
+		}
+	case AggSum, AggAvg, AggMin, AggMax:
+	default:
+		return Metric{}, errors.New(fmt.Sprint("Invalid metric function: ", entry.key, " metric: ", name))
+	}
+	if !validName.MatchString(metric.Field) {
+		return Metric{}, errors.New(fmt.Sprint("Invalid metric field: ", metric.Field, " metric: ", name))
+	}
+	return metric, nil
+}
+
+// accumulator keeps the partial result of a metric of one group.
+type accumulator struct {
+	count    int
+	sum      float64
+	extreme  interface{}
+	hasValue bool
+}
+
+func (acc *accumulator) add(metric Metric, record moleculer.Payload) {
+	if metric.Field == "" {
+		acc.count++
+		return
+	}
+	value := record.Get(metric.Field)
+	if !value.Exists() {
+		return
+	}
+	acc.count++
+	switch metric.Func {
+	case AggSum, AggAvg:
+		number, _ := toFloat(value.Value())
+		acc.sum = acc.sum + number
+	case AggMin, AggMax:
+		result := 0
+		if acc.hasValue {
+			result = CompareValues(value.Value(), acc.extreme)
+		}
+		if !acc.hasValue || (metric.Func == AggMin && result < 0) || (metric.Func == AggMax && result > 0) {
+			acc.extreme = value.Value()
+			acc.hasValue = true
+		}
+	}
+}
+
+func (acc *accumulator) result(metric Metric) interface{} {
+	switch metric.Func {
+	case AggCount:
+		return acc.count
+	case AggSum:
+		return acc.sum
+	case AggAvg:
+		if acc.count == 0 {
+			return nil
+		}
+		return acc.sum / float64(acc.count)
+	}
+	return acc.extreme
+}
+
+// aggregateGroup the group by values and the accumulators of the metrics of one group.
+type aggregateGroup struct {
+	values       []interface{}
+	accumulators []*accumulator
+}
+
+// AggregateStream calculates the aggregation of the records of the stream in memory.
+// Only the groups are kept in memory, so it can be used with FindStream on large collections.
+// Stops at the first error payload, cancel the FindStream context then.
+func AggregateStream(stream <-chan moleculer.Payload, aggregation Aggregation) moleculer.Payload {
+	groups := map[string]*aggregateGroup{}
+	keys := []string{}
+	for record := range stream {
+		if record.IsError() {
+			return record
+		}
+		values := []interface{}{}
+		for _, field := range aggregation.GroupBy {
+			values = append(values, record.Get(field).Value())
+		}
+		key := fmt.Sprintf("%#v", values)
+		group, exists := groups[key]
+		if !exists {
+			group = &aggregateGroup{values: values}
+			for range aggregation.Metrics {
+				group.accumulators = append(group.accumulators, &accumulator{})
+			}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		for i, metric := range aggregation.Metrics {
+			group.accumulators[i].add(metric, record)
+		}
+	}
+	if len(groups) == 0 && len(aggregation.GroupBy) == 0 {
+		groups[""] = &aggregateGroup{}
+		for range aggregation.Metrics {
+			groups[""].accumulators = append(groups[""].accumulators, &accumulator{})
+		}
+		keys = append(keys, "")
+	}
+	list := []moleculer.Payload{}
+	for _, key := range keys {
+		group := groups[key]
+		row := map[string]interface{}{}
+		for i, field := range aggregation.GroupBy {
+			row[field] = group.values[i]
+		}
+		for i, metric := range aggregation.Metrics {
+			row[metric.Name] = group.accumulators[i].result(metric)
+		}
+		list = append(list, payload.New(row))
+	}
+	sortRecords(list, aggregation.GroupBy)
+	return payload.New(list)
+}
+
+// aggregate uses the adapter Aggregate when available, otherwise calculates it in memory with FindStream.
+func aggregate(adapter Adapter, params moleculer.Payload, aggregation Aggregation) moleculer.Payload {
+	if aadapter, ok := adapter.(AggregateAdapter); ok {
+		return aadapter.Aggregate(params, aggregation)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := FindStream(ctx, adapter, params.Remove("groupBy", "metrics", "sort", "limit", "offset"))
+	if err != nil {
+		return payload.New(err)
+	}
+	return AggregateStream(stream, aggregation)
+}
+
+// aggregateAction calculates the metrics of the records matching the query, grouped by the groupBy fields.
+func aggregateAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		settings := getInstance().Settings
+		query := callHook(settings, beforeQuery, ctx, adapter, excludeDeleted(settings, decodeParams(settings, params)))
+		if query.IsError() {
+			return query
+		}
+		aggregation, err := ParseAggregation(query)
+		if err != nil {
+			return payload.New(err)
+		}
+		return aggregate(adapter, query, aggregation)
+	}
+}
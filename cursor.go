@@ -0,0 +1,184 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// KeysetAdapter is implemented by adapters with a native way to continue a sorted listing after a record,
+// like the search_after of Elasticsearch. Other adapters use a keyset query: the records after the
+// sort values of the last record of the previous page.
+type KeysetAdapter interface {
+	Adapter
+	// FindAfter returns the records matching the params (query, search and limit) sorted by the sort entries
+	// ("-age", "name", the last one is the idField) that come after the after values (nil for the first page).
+	// Also returns the sort values of the last record, used as the after values of the next page.
+	FindAfter(params moleculer.Payload, sort []string, after []interface{}) (moleculer.Payload, []interface{})
+}
+
+// cursor is the content of the opaque token returned by the scroll action.
+type cursor struct {
+	Sort  []string      `json:"s"`
+	After []interface{} `json:"a"`
+}
+
+// timeValue keeps the time values of the cursor, so they are not compared as strings.
+const timeValue = "$t"
+
+// encodeCursor creates the token with the sort entries and the sort values of the last record.
+func encodeCursor(sort []string, after []interface{}) string {
+	values := []interface{}{}
+	for _, value := range after {
+		if t, isTime := value.(time.Time); isTime {
+			value = map[string]interface{}{timeValue: t.Format(time.RFC3339Nano)}
+		}
+		values = append(values, value)
+	}
+	bts, _ := json.Marshal(cursor{sort, values})
+	return base64.RawURLEncoding.EncodeToString(bts)
+}
+
+// decodeCursor parses the token created by encodeCursor.
+func decodeCursor(token string) (cursor, error) {
+	c := cursor{}
+	bts, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(bts, &c)
+	}
+	if err != nil || len(c.Sort) == 0 || len(c.Sort) != len(c.After) {
+		return cursor{}, errors.New("Invalid cursor: " + token)
+	}
+	for i, value := range c.After {
+		if m, isMap := value.(map[string]interface{}); isMap {
+			text, isText := m[timeValue].(string)
+			if !isText {
+				return cursor{}, errors.New("Invalid cursor: " + token)
+			}
+			t, err := time.Parse(time.RFC3339Nano, text)
+			if err != nil {
+				return cursor{}, errors.New("Invalid cursor: " + token)
+			}
+			c.After[i] = t
+		}
+	}
+	return c, nil
+}
+
+// scrollSort return the sort entries of the sort param with the idField as the last entry,
+// so records with the same sort values have a stable order.
+func scrollSort(param moleculer.Payload, idField string) []string {
+	sort := []string{}
+	for _, entry := range sortEntries(param) {
+		if strings.TrimPrefix(entry, "-") == idField {
+			return append(sort, entry)
+		}
+		sort = append(sort, entry)
+	}
+	return append(sort, idField)
+}
+
+// keysetAfter return the query of the records that come after the value in the order of the field.
+// Like in SQL, records without the field come first in ascending order. returns nil when there is none.
+func keysetAfter(field string, desc bool, value interface{}) map[string]interface{} {
+	switch {
+	case !desc && value == nil:
+		return map[string]interface{}{field: map[string]interface{}{"$exists": true}}
+	case !desc:
+		return map[string]interface{}{field: map[string]interface{}{"$gt": value}}
+	case value == nil:
+		return nil
+	}
+	return map[string]interface{}{"$or": []interface{}{
+		map[string]interface{}{field: map[string]interface{}{"$lt": value}},
+		map[string]interface{}{field: nil},
+	}}
+}
+
+// keysetQuery return the query of the records after the values in the sort order:
+// (a > va) or (a = va and (b > vb or (b = vb and ...)))
+func keysetQuery(sort []string, after []interface{}) map[string]interface{} {
+	field := strings.TrimPrefix(sort[0], "-")
+	or := []interface{}{}
+	if next := keysetAfter(field, strings.HasPrefix(sort[0], "-"), after[0]); next != nil {
+		or = append(or, next)
+	}
+	if len(sort) > 1 {
+		or = append(or, map[string]interface{}{"$and": []interface{}{
+			map[string]interface{}{field: after[0]},
+			keysetQuery(sort[1:], after[1:]),
+		}})
+	}
+	return map[string]interface{}{"$or": or}
+}
+
+// findAfter uses the adapter FindAfter when available, otherwise adds the keyset query to the params query.
+func findAfter(adapter Adapter, params moleculer.Payload, sort []string, after []interface{}) (moleculer.Payload, []interface{}) {
+	if kadapter, ok := adapter.(KeysetAdapter); ok {
+		return kadapter.FindAfter(params, sort, after)
+	}
+	params = payload.Empty().AddMany(params.RawMap()).Remove("sort").Add("sort", sort)
+	if after != nil {
+		query := keysetQuery(sort, after)
+		if params.Get("query").Exists() {
+			query = map[string]interface{}{"$and": []interface{}{params.Get("query").Value(), query}}
+		}
+		params = params.Remove("query").Add("query", query)
+	}
+	rows := adapter.Find(params)
+	if rows.IsError() || rows.Len() == 0 {
+		return rows, nil
+	}
+	last := rows.Array()[rows.Len()-1]
+	values := []interface{}{}
+	for _, entry := range sort {
+		values = append(values, last.Get(strings.TrimPrefix(entry, "-")).Value())
+	}
+	return rows, values
+}
+
+// scrollAction returns a page of records and the cursor of the next page. The next page is found by the
+// sort values of the last record (keyset) instead of an offset, so deep pages are as fast as the first one.
+// The sort is only read on the first page, the cursor keeps it. cursor is nil on the last page.
+func scrollAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		settings := getInstance().Settings
+		query := callHook(settings, beforeQuery, ctx, adapter, excludeDeleted(settings, decodeParams(settings, params)))
+		if query.IsError() {
+			return query
+		}
+		pageSize := settings["pageSize"].(int)
+		if query.Get("pageSize").Exists() {
+			pageSize = query.Get("pageSize").Int()
+		}
+		sort := scrollSort(query.Get("sort"), idFieldName(settings))
+		var after []interface{}
+		if query.Get("cursor").Exists() {
+			c, err := decodeCursor(query.Get("cursor").String())
+			if err != nil {
+				return payload.New(err)
+			}
+			sort, after = c.Sort, c.After
+		}
+		query = query.Remove("cursor", "pageSize", "page", "offset", "limit", "sort").Add("limit", pageSize)
+		rows, last := findAfter(adapter, query, sort, after)
+		rows = callHook(settings, afterQuery, ctx, adapter, rows)
+		if rows.IsError() {
+			return rows
+		}
+		var next interface{}
+		if rows.Len() == pageSize && last != nil {
+			next = encodeCursor(sort, last)
+		}
+		return map[string]interface{}{
+			"rows":     transformResult(ctx, params, rows, getInstance),
+			"cursor":   next,
+			"pageSize": pageSize,
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// DistinctAdapter is implemented by adapters that find the distinct values of a field in the database.
+type DistinctAdapter interface {
+	Adapter
+	// Distinct returns the list of distinct values of the field, sorted and without null, in the records
+	// matching the params (query, search and searchFields).
+	Distinct(params moleculer.Payload, field string) moleculer.Payload
+}
+
+// DistinctStream returns the sorted distinct values of the field in the records of the stream, without null.
+// Only the distinct values are kept in memory. Stops at the first error payload, cancel the FindStream context then.
+func DistinctStream(stream <-chan moleculer.Payload, field string) moleculer.Payload {
+	values := map[string]interface{}{}
+	for record := range stream {
+		if record.IsError() {
+			return record
+		}
+		value := record.Get(field)
+		if !value.Exists() {
+			continue
+		}
+		values[fmt.Sprintf("%#v", value.Value())] = value.Value()
+	}
+	return payload.New(sortedValues(values))
+}
+
+// sortedValues return the values of the map sorted with CompareValues.
+func sortedValues(values map[string]interface{}) []interface{} {
+	list := []interface{}{}
+	for _, value := range values {
+		list = append(list, value)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return CompareValues(list[i], list[j]) < 0
+	})
+	return list
+}
+
+// distinct uses the adapter Distinct when available, otherwise collects the values with FindStream.
+func distinct(adapter Adapter, params moleculer.Payload, field string) moleculer.Payload {
+	if dadapter, ok := adapter.(DistinctAdapter); ok {
+		return dadapter.Distinct(params, field)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := FindStream(ctx, adapter, params.Remove("field", "sort", "limit", "offset"))
+	if err != nil {
+		return payload.New(err)
+	}
+	return DistinctStream(stream, field)
+}
+
+// distinctAction returns the distinct values of params.field in the records matching the query.
+func distinctAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		settings := getInstance().Settings
+		query := callHook(settings, beforeQuery, ctx, adapter, excludeDeleted(settings, decodeParams(settings, params)))
+		if query.IsError() {
+			return query
+		}
+		field := query.Get("field").String()
+		if !validName.MatchString(field) {
+			return payload.New(errors.New(fmt.Sprint("Invalid distinct field: ", field)))
+		}
+		result := distinct(adapter, query, field)
+		if field == idFieldName(settings) && !result.IsError() {
+			encode, _ := idCodec(settings)
+			return result.MapOver(encode)
+		}
+		return result
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	elastic "github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
@@ -13,6 +14,7 @@ import (
 	"github.com/moleculer-go/moleculer/payload"
 	"github.com/moleculer-go/moleculer/serializer"
 	"github.com/moleculer-go/moleculer/util"
+	"github.com/moleculer-go/store"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -21,6 +23,7 @@ type Adapter struct {
 	es   *elastic.Client
 
 	indexName string
+	idField   string
 
 	connected  bool
 	log        *log.Entry
@@ -46,6 +49,13 @@ func (a *Adapter) loadSettings(settings map[string]interface{}) {
 	if mappings, ok := settings["mappings"].(map[string]interface{}); ok {
 		a.mappings = mappings
 	}
+	//the elastic _id is returned in the idField. Default: documentID
+	if idField, ok := settings["idField"].(string); ok && idField != "" {
+		a.idField = idField
+	}
+	if a.idField == "" {
+		a.idField = "documentID"
+	}
 }
 
 func (a *Adapter) printClusterInfo() {
@@ -157,17 +167,162 @@ func (a *Adapter) handleResponse(res *esapi.Response, err error, errorMsg string
 	return r
 }
 
+//documentSource return the document id and source of a record.
+//the id is taken from the idField when present, otherwise a random id is generated.
+func (a *Adapter) documentSource(record moleculer.Payload) (string, moleculer.Payload) {
+	if record.Get(a.idField).Exists() {
+		return record.Get(a.idField).String(), record.Remove(a.idField)
+	}
+	return util.RandomString(12), record
+}
+
 //Insert index document
 func (a *Adapter) Insert(params moleculer.Payload) moleculer.Payload {
+	id, source := a.documentSource(params)
 	req := esapi.IndexRequest{
 		Index:      a.indexName,
-		DocumentID: util.RandomString(12),
-		Body:       strings.NewReader(a.serializer.PayloadToString(params)),
+		DocumentID: id,
+		Body:       strings.NewReader(a.serializer.PayloadToString(source)),
 		Refresh:    "true",
 	}
 	res, err := req.Do(context.Background(), a.es)
-	a.handleResponse(res, err, "Error indexing documentID: "+req.DocumentID)
-	return params.Add("documentID", req.DocumentID)
+	r := a.handleResponse(res, err, "Error indexing documentID: "+req.DocumentID)
+	if r.IsError() {
+		return r
+	}
+	return source.Add(a.idField, req.DocumentID)
+}
+
+//bulk send the NDJSON lines to the _bulk API and return the response.
+//returns an error payload when any of the bulk items failed.
+func (a *Adapter) bulk(lines []string) moleculer.Payload {
+	req := esapi.BulkRequest{
+		Index:   a.indexName,
+		Body:    strings.NewReader(strings.Join(lines, "\n") + "\n"),
+		Refresh: "true",
+	}
+	res, err := req.Do(context.Background(), a.es)
+	r := a.handleResponse(res, err, "Error on bulk request")
+	if r.IsError() || !r.Get("errors").Bool() {
+		return r
+	}
+	msg := "Error on bulk request"
+	failed := false
+	r.Get("items").ForEach(func(idx interface{}, item moleculer.Payload) bool {
+		item.ForEach(func(action interface{}, result moleculer.Payload) bool {
+			if result.Get("error").Exists() {
+				msg = msg + " - root cause: " + result.Get("error").Get("reason").String()
+				failed = true
+			}
+			return false
+		})
+		return !failed
+	})
+	a.log.Error(msg)
+	return payload.PayloadError(msg, r)
+}
+
+//InsertMany index all documents of the list with a single _bulk request.
+func (a *Adapter) InsertMany(params moleculer.Payload) moleculer.Payload {
+	if !params.IsArray() {
+		return payload.Error("InsertMany() only support lists!")
+	}
+	records := params.Array()
+	if len(records) == 0 {
+		return payload.EmptyList()
+	}
+	ids := make([]string, len(records))
+	sources := make([]moleculer.Payload, len(records))
+	lines := []string{}
+	for i, record := range records {
+		ids[i], sources[i] = a.documentSource(record)
+		action := payload.Empty().Add("index", map[string]interface{}{"_id": ids[i]})
+		lines = append(lines, a.serializer.PayloadToString(action), a.serializer.PayloadToString(sources[i]))
+	}
+	r := a.bulk(lines)
+	if r.IsError() {
+		return r
+	}
+	list := make([]moleculer.Payload, len(records))
+	for i, source := range sources {
+		list[i] = source.Add(a.idField, ids[i])
+	}
+	return payload.New(list)
+}
+
+//UpdateMany apply params.update to all documents matching the params using the update by query API.
+func (a *Adapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	update := params.Get("update")
+	if !update.Exists() || !update.IsMap() {
+		return payload.Error("UpdateMany() requires the update param!")
+	}
+	filter, err := a.bulkFilter(params.Remove("update"))
+	if err != nil {
+		return payload.New(err)
+	}
+	refresh := true
+	body := payload.New(map[string]interface{}{
+		"query": filter.Get("query").Value(),
+		"script": map[string]interface{}{
+			"source": updateScript,
+			"lang":   "painless",
+			"params": map[string]interface{}{"update": update.RawMap()},
+		},
+	})
+	req := esapi.UpdateByQueryRequest{
+		Index:   []string{a.indexName},
+		Body:    strings.NewReader(a.serializer.PayloadToString(body)),
+		Refresh: &refresh,
+	}
+	res, err := req.Do(context.Background(), a.es)
+	r := a.handleResponse(res, err, "Error updating docs by query")
+	if r.IsError() {
+		return r
+	}
+	return payload.Empty().Add("modifiedCount", r.Get("updated").Int64())
+}
+
+//RemoveMany remove the documents with params.ids (using a _bulk request) or matching the params (using delete by query).
+func (a *Adapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	if params.Get("ids").Exists() {
+		lines := []string{}
+		for _, id := range params.Get("ids").StringArray() {
+			action := payload.Empty().Add("delete", map[string]interface{}{"_id": id})
+			lines = append(lines, a.serializer.PayloadToString(action))
+		}
+		if len(lines) == 0 {
+			return payload.Empty().Add("deletedCount", 0)
+		}
+		r := a.bulk(lines)
+		if r.IsError() {
+			return r
+		}
+		deletedCount := 0
+		r.Get("items").ForEach(func(idx interface{}, item moleculer.Payload) bool {
+			if item.Get("delete").Get("result").String() == "deleted" {
+				deletedCount++
+			}
+			return true
+		})
+		return payload.Empty().Add("deletedCount", deletedCount)
+	}
+	filter, err := a.bulkFilter(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	refresh := true
+	body := payload.Empty().Add("query", filter.Get("query"))
+	req := esapi.DeleteByQueryRequest{
+		Index:   []string{a.indexName},
+		Body:    strings.NewReader(a.serializer.PayloadToString(body)),
+		Refresh: &refresh,
+	}
+	res, err := req.Do(context.Background(), a.es)
+	r := a.handleResponse(res, err, "Error deleting docs by query")
+	if r.IsError() {
+		return r
+	}
+	return payload.Empty().Add("deletedCount", r.Get("deleted").Int64())
 }
 
 //RemoveAll remove all documents from the index
@@ -183,7 +338,7 @@ func (a *Adapter) RemoveAll() moleculer.Payload {
 	return a.handleResponse(res, err, "Error deleting docs by query")
 }
 
-//RemoveById remove document from the index by id
+//RemoveById remove document from the index by id. returns the deletedCount, 0 when the document does not exist.
 func (a *Adapter) RemoveById(id moleculer.Payload) moleculer.Payload {
 	req := esapi.DeleteRequest{
 		Index:      a.indexName,
@@ -191,15 +346,23 @@ func (a *Adapter) RemoveById(id moleculer.Payload) moleculer.Payload {
 		Refresh:    "true",
 	}
 	res, err := req.Do(context.Background(), a.es)
-	return a.handleResponse(res, err, "Error deleting docs by id: "+id.String())
+	if err == nil && res.StatusCode == 404 {
+		res.Body.Close()
+		return payload.Empty().Add("deletedCount", 0)
+	}
+	r := a.handleResponse(res, err, "Error deleting docs by id: "+id.String())
+	if r.IsError() {
+		return r
+	}
+	return payload.Empty().Add("deletedCount", 1)
 }
 
 func (adapter *Adapter) Update(params moleculer.Payload) moleculer.Payload {
-	id := params.Get("documentID")
+	id := params.Get(adapter.idField)
 	if !id.Exists() {
-		return payload.Error("Cannot update record without documentID")
+		return payload.Error("Cannot update record without ", adapter.idField)
 	}
-	return adapter.UpdateById(id, params.Remove("documentID"))
+	return adapter.UpdateById(id, params.Remove(adapter.idField))
 }
 
 //UpdateById update document by id
@@ -297,24 +460,163 @@ func sortsFromStringArray(sort moleculer.Payload) map[string]string {
 	return sorts
 }
 
-func parseFilter(params moleculer.Payload) moleculer.Payload {
-	query := payload.Empty()
-	if params.Get("query").Exists() {
-		query = params.Get("query")
+//parseFilter create the search body (query, size, from and sort) from the params.
+//The search is the must clause and the query filter (translated by filterQuery) the filter clause.
+func (a *Adapter) parseFilter(params moleculer.Payload) (moleculer.Payload, error) {
+	filter, err := store.ParseQuery(params.Get("query"))
+	if err != nil {
+		return nil, err
+	}
+	query := parseSearchFields(params, payload.Empty())
+	if !filter.IsEmpty() {
+		query = payload.New(map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   []interface{}{query.Value()},
+				"filter": []interface{}{a.filterQuery(filter)},
+			},
+		})
 	}
-	query = parseSearchFields(params, query)
 	queryParams := parseQueryParams(params)
-	return queryParams.Add("query", query)
+	return queryParams.Add("query", query), nil
+}
+
+//bulkFilter return the filter of UpdateMany and RemoveMany. An empty query without search matches all the
+//documents and is an error, so a bad request can not change the whole index.
+func (a *Adapter) bulkFilter(params moleculer.Payload) (moleculer.Payload, error) {
+	filter, err := store.ParseQuery(params.Get("query"))
+	if err != nil {
+		return nil, err
+	}
+	if filter.IsEmpty() && (!params.Get("search").Exists() || params.Get("search").String() == "") {
+		return nil, errors.New("A query or search filter is required to change many records.")
+	}
+	return a.parseFilter(params)
+}
+
+//ranges maps the filter operators to the range query ones.
+var ranges = map[string]string{
+	store.OpGt:  "gt",
+	store.OpGte: "gte",
+	store.OpLt:  "lt",
+	store.OpLte: "lte",
+}
+
+//boolQuery create a bool query with a single clause.
+func boolQuery(clause string, queries ...interface{}) map[string]interface{} {
+	b := map[string]interface{}{clause: queries}
+	if clause == "should" {
+		b["minimum_should_match"] = 1
+	}
+	return map[string]interface{}{"bool": b}
+}
+
+//termQuery create a term (or terms for lists) query. For strings the .keyword sub field created by the
+//dynamic mapping of text fields is also checked, so the exact value matches keyword and text fields.
+func termQuery(term, field string, value interface{}) map[string]interface{} {
+	query := map[string]interface{}{term: map[string]interface{}{field: value}}
+	if !isText(value) {
+		return query
+	}
+	return boolQuery("should", query, map[string]interface{}{term: map[string]interface{}{field + ".keyword": value}})
+}
+
+//isText check if the value (or the first item of a list) is a string.
+func isText(value interface{}) bool {
+	if list, isList := value.([]interface{}); isList && len(list) > 0 {
+		value = list[0]
+	}
+	_, isString := value.(string)
+	return isString
+}
+
+//wildcardPattern converts a like pattern (% any text, _ one character) to a wildcard pattern.
+func wildcardPattern(like string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "%", "*", "_", "?")
+	return replacer.Replace(like)
+}
+
+//filterQuery translate the query filter to an ES query. The idField is translated to an ids query.
+func (a *Adapter) filterQuery(filter store.Filter) map[string]interface{} {
+	switch filter.Op {
+	case store.OpAnd, store.OpOr, store.OpNot:
+		queries := []interface{}{}
+		for _, child := range filter.Filters {
+			queries = append(queries, a.filterQuery(child))
+		}
+		clause := map[string]string{store.OpAnd: "filter", store.OpOr: "should", store.OpNot: "must_not"}[filter.Op]
+		return boolQuery(clause, queries...)
+	}
+	field := filter.Field
+	exists := map[string]interface{}{"exists": map[string]interface{}{"field": field}}
+	switch filter.Op {
+	case store.OpExists:
+		if filter.Value.(bool) {
+			return exists
+		}
+		return boolQuery("must_not", exists)
+	case store.OpEq, store.OpIn:
+		values := filter.Values()
+		if filter.Op == store.OpEq {
+			values = []interface{}{filter.Value}
+		}
+		if field == a.idField {
+			return map[string]interface{}{"ids": map[string]interface{}{"values": values}}
+		}
+		if filter.Op == store.OpEq {
+			return termQuery("term", field, filter.Value)
+		}
+		return termQuery("terms", field, values)
+	case store.OpNe:
+		return boolQuery("filter", exists, boolQuery("must_not", a.filterQuery(store.Filter{Op: store.OpEq, Field: field, Value: filter.Value})))
+	case store.OpNin:
+		return boolQuery("filter", exists, boolQuery("must_not", a.filterQuery(store.Filter{Op: store.OpIn, Field: field, Value: filter.Value})))
+	case store.OpBetween:
+		values := filter.Values()
+		return map[string]interface{}{"range": map[string]interface{}{field: map[string]interface{}{"gte": values[0], "lte": values[1]}}}
+	case store.OpLike:
+		wildcard := map[string]interface{}{"value": wildcardPattern(filter.Value.(string)), "case_insensitive": true}
+		return boolQuery("should",
+			map[string]interface{}{"wildcard": map[string]interface{}{field: wildcard}},
+			map[string]interface{}{"wildcard": map[string]interface{}{field + ".keyword": wildcard}})
+	}
+	return map[string]interface{}{"range": map[string]interface{}{field: map[string]interface{}{ranges[filter.Op]: filter.Value}}}
 }
 
 func getHits(params, search moleculer.Payload) moleculer.Payload {
 	return search.Get("hits").Get("hits")
 }
 
+//hitToPayload return the document source with the _id in the idField.
+func (a *Adapter) hitToPayload(hit moleculer.Payload) moleculer.Payload {
+	source := hit.Get("_source")
+	if !source.Exists() {
+		source = payload.Empty()
+	}
+	return source.Add(a.idField, hit.Get("_id").String())
+}
+
 func (a *Adapter) Find(params moleculer.Payload) moleculer.Payload {
 
-	query := a.serializer.PayloadToString(parseFilter(params))
-	a.log.Traceln("Find() params: ", params, "query: ", query)
+	filter, err := a.parseFilter(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	a.log.Traceln("Find() params: ", params)
+	p := a.search(filter)
+	if p.IsError() {
+		return p
+	}
+	list := getHits(params, p)
+	result := list.MapOver(a.hitToPayload)
+	a.log.Traceln("find result transformed: ")
+	a.log.Traceln(result)
+	return result
+}
+
+//search runs the search body and returns the ES response.
+func (a *Adapter) search(body moleculer.Payload) moleculer.Payload {
+	query := a.serializer.PayloadToString(body)
+	a.log.Traceln("search query: ", query)
 
 	res, err := a.es.Search(
 		a.es.Search.WithContext(context.Background()),
@@ -323,6 +625,11 @@ func (a *Adapter) Find(params moleculer.Payload) moleculer.Payload {
 		a.es.Search.WithTrackTotalHits(true),
 		a.es.Search.WithPretty(),
 	)
+	return a.searchResponse(res, err)
+}
+
+//searchResponse parse the response of a search or scroll request.
+func (a *Adapter) searchResponse(res *esapi.Response, err error) moleculer.Payload {
 	if err != nil {
 		return payload.New(err)
 	}
@@ -337,16 +644,446 @@ func (a *Adapter) Find(params moleculer.Payload) moleculer.Payload {
 
 	a.log.Traceln("search result:")
 	a.log.Traceln(p)
-	list := getHits(params, p)
-	result := list.MapOver(func(in moleculer.Payload) moleculer.Payload {
-		return in.Get("_source")
+	return p
+}
+
+//streamBatch number of documents fetched by each scroll request of FindStream.
+var streamBatch = 100
+
+//scrollKeepAlive time ES keeps the scroll context between the scroll requests.
+var scrollKeepAlive = time.Minute
+
+//FindStream implements store.StreamingAdapter using the scroll API. The documents are fetched in batches of streamBatch
+//as the channel is consumed and the scroll is cleared at the end or when ctx is done. params.limit limits the total of documents
+//and the first params.offset documents are skipped, since the scroll API does not support from.
+func (a *Adapter) FindStream(ctx context.Context, params moleculer.Payload) (<-chan moleculer.Payload, error) {
+	body, err := a.parseFilter(params.Remove("limit", "offset"))
+	if err != nil {
+		return nil, err
+	}
+	body = body.Add("size", streamBatch)
+	res, err := a.es.Search(
+		a.es.Search.WithContext(ctx),
+		a.es.Search.WithIndex(a.indexName),
+		a.es.Search.WithBody(strings.NewReader(a.serializer.PayloadToString(body))),
+		a.es.Search.WithScroll(scrollKeepAlive),
+	)
+	page := a.searchResponse(res, err)
+	if page.IsError() {
+		return nil, page.Error()
+	}
+	limit, offset := -1, 0
+	if params.Get("limit").Exists() {
+		limit = params.Get("limit").Int()
+	}
+	if params.Get("offset").Exists() {
+		offset = params.Get("offset").Int()
+	}
+	stream := make(chan moleculer.Payload)
+	go func() {
+		scrollID := page.Get("_scroll_id").String()
+		defer close(stream)
+		defer func() {
+			a.clearScroll(scrollID)
+		}()
+		sent, skipped := 0, 0
+		for {
+			hits := getHits(params, page)
+			if hits.Len() == 0 {
+				return
+			}
+			for _, hit := range hits.Array() {
+				if skipped < offset {
+					skipped++
+					continue
+				}
+				if limit >= 0 && sent >= limit {
+					return
+				}
+				if !store.SendRecord(ctx, stream, a.hitToPayload(hit)) {
+					return
+				}
+				sent++
+			}
+			page = a.searchResponse(a.es.Scroll(
+				a.es.Scroll.WithContext(ctx),
+				a.es.Scroll.WithScrollID(scrollID),
+				a.es.Scroll.WithScroll(scrollKeepAlive),
+			))
+			if page.IsError() {
+				store.SendRecord(ctx, stream, page)
+				return
+			}
+			scrollID = page.Get("_scroll_id").String()
+		}
+	}()
+	return stream, nil
+}
+
+//clearScroll release the scroll context.
+func (a *Adapter) clearScroll(scrollID string) {
+	res, err := a.es.ClearScroll(a.es.ClearScroll.WithScrollID(scrollID))
+	if err != nil {
+		a.log.Error("error clearing scroll: ", err)
+		return
+	}
+	res.Body.Close()
+}
+
+//afterSorts create the sort list of the search_after query. The idField is sorted by _id.
+func (a *Adapter) afterSorts(sort []string) []interface{} {
+	sorts := []interface{}{}
+	for _, entry := range sort {
+		field, direction := sortEntry(entry)
+		if field == a.idField {
+			field = "_id"
+		}
+		sorts = append(sorts, map[string]interface{}{field: map[string]interface{}{"order": direction, "missing": missingFirst[direction]}})
+	}
+	return sorts
+}
+
+//missingFirst sorts the documents without the field first in ascending order, like the other adapters.
+var missingFirst = map[string]string{"asc": "_first", "desc": "_last"}
+
+//FindAfter implements store.KeysetAdapter using search_after. The after values are the sort values of the last hit.
+func (a *Adapter) FindAfter(params moleculer.Payload, sort []string, after []interface{}) (moleculer.Payload, []interface{}) {
+	body, err := a.parseFilter(params.Remove("sort", "offset"))
+	if err != nil {
+		return payload.New(err), nil
+	}
+	body = body.Add("sort", a.afterSorts(sort))
+	if after != nil {
+		body = body.Add("search_after", after)
+	}
+	p := a.search(body)
+	if p.IsError() {
+		return p, nil
+	}
+	hits := getHits(params, p)
+	if hits.Len() == 0 {
+		return payload.EmptyList(), nil
+	}
+	last := hits.Array()[hits.Len()-1].Get("sort")
+	return hits.MapOver(a.hitToPayload), listValues(last)
+}
+
+//listValues return the values of a list.
+func listValues(list moleculer.Payload) []interface{} {
+	values := []interface{}{}
+	for _, item := range list.Array() {
+		values = append(values, item.Value())
+	}
+	return values
+}
+
+//metricAggregations maps the aggregate functions to ES metric aggregations.
+var metricAggregations = map[string]string{
+	store.AggCount: "value_count",
+	store.AggSum:   "sum",
+	store.AggAvg:   "avg",
+	store.AggMin:   "min",
+	store.AggMax:   "max",
+}
+
+//compositeSize number of groups fetched by each request of the composite aggregation.
+var compositeSize = 1000
+
+//metricAggs create the metric aggregations. The count of documents is the doc_count and has no aggregation.
+func metricAggs(aggregation store.Aggregation) map[string]interface{} {
+	aggs := map[string]interface{}{}
+	for _, metric := range aggregation.Metrics {
+		if metric.Field == "" {
+			continue
+		}
+		aggs[metric.Name] = map[string]interface{}{
+			metricAggregations[metric.Func]: map[string]interface{}{"field": metric.Field},
+		}
+	}
+	return aggs
+}
+
+//metricValues read the metrics from the aggregations of the response or of a bucket.
+//Dates are returned as value_as_string.
+func metricValues(aggregation store.Aggregation, aggs moleculer.Payload, docCount int64) map[string]interface{} {
+	row := map[string]interface{}{}
+	for _, metric := range aggregation.Metrics {
+		result := aggs.Get(metric.Name)
+		switch {
+		case metric.Field == "":
+			row[metric.Name] = docCount
+		case metric.Func == store.AggCount:
+			row[metric.Name] = result.Get("value").Int64()
+		case result.Get("value_as_string").Exists():
+			row[metric.Name] = result.Get("value_as_string").String()
+		default:
+			row[metric.Name] = result.Get("value").Value()
+		}
+	}
+	return row
+}
+
+//Aggregate implements store.AggregateAdapter with metric aggregations, grouped by a composite aggregation.
+//The group by fields must be keyword, numeric or date fields.
+func (a *Adapter) Aggregate(params moleculer.Payload, aggregation store.Aggregation) moleculer.Payload {
+	filter, err := a.parseFilter(params.Remove("sort", "limit", "offset"))
+	if err != nil {
+		return payload.New(err)
+	}
+	body := payload.Empty().Add("size", 0).Add("query", filter.Get("query"))
+	if len(aggregation.GroupBy) == 0 {
+		p := a.search(body.Add("aggs", metricAggs(aggregation)))
+		if p.IsError() {
+			return p
+		}
+		row := metricValues(aggregation, p.Get("aggregations"), p.Get("hits").Get("total").Get("value").Int64())
+		return payload.New([]moleculer.Payload{payload.New(row)})
+	}
+	sources := []interface{}{}
+	for _, field := range aggregation.GroupBy {
+		sources = append(sources, map[string]interface{}{
+			field: map[string]interface{}{"terms": map[string]interface{}{"field": field, "missing_bucket": true}},
+		})
+	}
+	rows := []moleculer.Payload{}
+	err = a.compositeBuckets(body, sources, metricAggs(aggregation), func(bucket moleculer.Payload) {
+		row := metricValues(aggregation, bucket, bucket.Get("doc_count").Int64())
+		for _, field := range aggregation.GroupBy {
+			row[field] = bucket.Get("key").Get(field).Value()
+		}
+		rows = append(rows, payload.New(row))
 	})
-	a.log.Traceln("find result transformed: ")
-	a.log.Traceln(result)
-	return result
+	if err != nil {
+		return payload.New(err)
+	}
+	return payload.New(rows)
+}
+
+//compositeBuckets pages through the buckets of a composite aggregation of the sources, calling fn with each bucket.
+func (a *Adapter) compositeBuckets(body moleculer.Payload, sources []interface{}, aggs map[string]interface{}, fn func(bucket moleculer.Payload)) error {
+	var after interface{}
+	for {
+		composite := map[string]interface{}{"size": compositeSize, "sources": sources}
+		if after != nil {
+			composite["after"] = after
+		}
+		groups := map[string]interface{}{"composite": composite}
+		if len(aggs) > 0 {
+			groups["aggs"] = aggs
+		}
+		p := a.search(body.Add("aggs", map[string]interface{}{"groups": groups}))
+		if p.IsError() {
+			return p.Error()
+		}
+		buckets := p.Get("aggregations").Get("groups").Get("buckets")
+		for _, bucket := range buckets.Array() {
+			fn(bucket)
+		}
+		afterKey := p.Get("aggregations").Get("groups").Get("after_key")
+		if buckets.Len() < compositeSize || !afterKey.Exists() {
+			return nil
+		}
+		after = afterKey.Value()
+	}
+}
+
+//Distinct implements store.DistinctAdapter with a terms source of a composite aggregation, sorted by the values.
+//The field must be a keyword, numeric or date field.
+func (a *Adapter) Distinct(params moleculer.Payload, field string) moleculer.Payload {
+	filter, err := a.parseFilter(params.Remove("sort", "limit", "offset"))
+	if err != nil {
+		return payload.New(err)
+	}
+	body := payload.Empty().Add("size", 0).Add("query", filter.Get("query"))
+	sources := []interface{}{map[string]interface{}{
+		field: map[string]interface{}{"terms": map[string]interface{}{"field": field}},
+	}}
+	values := []interface{}{}
+	err = a.compositeBuckets(body, sources, nil, func(bucket moleculer.Payload) {
+		values = append(values, bucket.Get("key").Get(field).Value())
+	})
+	if err != nil {
+		return payload.New(err)
+	}
+	return payload.New(values)
 }
 
 //FindOne find document return just the first match
 func (a *Adapter) FindOne(params moleculer.Payload) moleculer.Payload {
 	return a.Find(params.Add("limit", 1)).First()
 }
+
+//FindById get a document by its documentID
+func (a *Adapter) FindById(id moleculer.Payload) moleculer.Payload {
+	req := esapi.GetRequest{
+		Index:      a.indexName,
+		DocumentID: id.String(),
+	}
+	res, err := req.Do(context.Background(), a.es)
+	if err == nil && res.StatusCode == 404 {
+		res.Body.Close()
+		return payload.New(nil)
+	}
+	r := a.handleResponse(res, err, "Error getting doc by id: "+id.String())
+	if r.IsError() {
+		return r
+	}
+	return a.hitToPayload(r)
+}
+
+//FindByIds get multiple documents by documentID, the result keeps the order of the ids.
+//Documents not found are nil entries in the result, like the other adapters.
+func (a *Adapter) FindByIds(ids moleculer.Payload) moleculer.Payload {
+	if !ids.IsArray() {
+		return payload.Error("FindByIds() only support lists!")
+	}
+	body := payload.Empty().Add("ids", ids.StringArray())
+	req := esapi.MgetRequest{
+		Index: a.indexName,
+		Body:  strings.NewReader(a.serializer.PayloadToString(body)),
+	}
+	res, err := req.Do(context.Background(), a.es)
+	r := a.handleResponse(res, err, "Error getting docs by ids: "+ids.String())
+	if r.IsError() {
+		return r
+	}
+	list := []moleculer.Payload{}
+	r.Get("docs").ForEach(func(idx interface{}, doc moleculer.Payload) bool {
+		if doc.Get("found").Bool() {
+			list = append(list, a.hitToPayload(doc))
+		} else {
+			list = append(list, payload.New(nil))
+		}
+		return true
+	})
+	return payload.New(list)
+}
+
+//Count count the documents matching the query/search params.
+func (a *Adapter) Count(params moleculer.Payload) moleculer.Payload {
+	filter, err := a.parseFilter(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	body := payload.Empty().Add("query", filter.Get("query"))
+	req := esapi.CountRequest{
+		Index: []string{a.indexName},
+		Body:  strings.NewReader(a.serializer.PayloadToString(body)),
+	}
+	res, err := req.Do(context.Background(), a.es)
+	r := a.handleResponse(res, err, "Error counting docs")
+	if r.IsError() {
+		return r
+	}
+	return payload.New(r.Get("count").Int64())
+}
+
+//updateScript painless script that copies all fields in params.update to the document source.
+var updateScript = "for (entry in params.update.entrySet()) { ctx._source[entry.getKey()] = entry.getValue(); }"
+
+//updateByIds update the documents with the given ids using the update by query API.
+func (a *Adapter) updateByIds(ids []string, update moleculer.Payload) moleculer.Payload {
+	return a.updateByQuery(map[string]interface{}{
+		"ids": map[string]interface{}{"values": ids},
+	}, update)
+}
+
+//UpdateByIdVersion update the document when versionField is equal to version, using the update by query API
+//with a term filter on the version. returns payload.New(nil) when no document matched.
+func (a *Adapter) UpdateByIdVersion(id moleculer.Payload, versionField string, version int64, update moleculer.Payload) moleculer.Payload {
+	r := a.updateByQuery(map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []interface{}{
+				map[string]interface{}{"ids": map[string]interface{}{"values": []string{id.String()}}},
+				map[string]interface{}{"term": map[string]interface{}{versionField: version}},
+			},
+		},
+	}, update)
+	if r.IsError() {
+		return r
+	}
+	if r.Get("updated").Int() == 0 {
+		return payload.New(nil)
+	}
+	return a.FindById(id)
+}
+
+//updateByQuery update the documents matching the query using the update by query API.
+func (a *Adapter) updateByQuery(query map[string]interface{}, update moleculer.Payload) moleculer.Payload {
+	refresh := true
+	body := payload.New(map[string]interface{}{
+		"query": query,
+		"script": map[string]interface{}{
+			"source": updateScript,
+			"lang":   "painless",
+			"params": map[string]interface{}{"update": update.RawMap()},
+		},
+	})
+	req := esapi.UpdateByQueryRequest{
+		Index:   []string{a.indexName},
+		Body:    strings.NewReader(a.serializer.PayloadToString(body)),
+		Refresh: &refresh,
+	}
+	res, err := req.Do(context.Background(), a.es)
+	return a.handleResponse(res, err, "Error updating docs by query")
+}
+
+//findIds return the ids of the documents matching the params. A page (limit) is read with Find, which applies
+//the from/size of the search, otherwise the ids are read from all the pages of the results with FindStream.
+func (a *Adapter) findIds(params moleculer.Payload) ([]string, moleculer.Payload) {
+	ids := []string{}
+	if params.Get("limit").Exists() {
+		list := a.Find(params)
+		if list.IsError() {
+			return nil, list
+		}
+		for _, record := range list.Array() {
+			ids = append(ids, record.Get(a.idField).String())
+		}
+		return ids, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := a.FindStream(ctx, params)
+	if err != nil {
+		return nil, payload.New(err)
+	}
+	for record := range stream {
+		if record.IsError() {
+			return nil, record
+		}
+		ids = append(ids, record.Get(a.idField).String())
+	}
+	return ids, nil
+}
+
+//FindAndUpdate find the documents matching the params and apply the update to all of them, using the update
+//by query API. returns the list of updated documents. With limit or offset only the ids of the page are updated.
+func (a *Adapter) FindAndUpdate(params moleculer.Payload) moleculer.Payload {
+	update := params.Get("update")
+	if !update.Exists() || !update.IsMap() {
+		return payload.Error("FindAndUpdate() requires the update param!")
+	}
+	params = params.Remove("update")
+	filter, err := a.parseFilter(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	ids, r := a.findIds(params)
+	if r != nil {
+		return r
+	}
+	if len(ids) == 0 {
+		return payload.EmptyList()
+	}
+	if params.Get("limit").Exists() || params.Get("offset").Exists() {
+		//only the page of documents is updated
+		r = a.updateByIds(ids, update)
+	} else {
+		r = a.updateByQuery(filter.Get("query").RawMap(), update)
+	}
+	if r.IsError() {
+		return r
+	}
+	return a.FindByIds(payload.New(ids))
+}
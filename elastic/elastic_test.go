@@ -1,13 +1,22 @@
 package elastic
 
 import (
+	"github.com/moleculer-go/moleculer"
 	"github.com/moleculer-go/moleculer/payload"
 	"github.com/moleculer-go/moleculer/util"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/storetest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
 )
 
+var _ store.Adapter = &Adapter{}
+var _ store.KeysetAdapter = &Adapter{}
+var _ store.StreamingAdapter = &Adapter{}
+var _ store.AggregateAdapter = &Adapter{}
+var _ store.DistinctAdapter = &Adapter{}
+
 var _ = Describe("Elastic", func() {
 
 	log.SetLevel(log.InfoLevel)
@@ -128,6 +137,68 @@ var _ = Describe("Elastic", func() {
 		Expect(out.Get("sort").Get("name").String()).Should(Equal("asc"))
 	})
 
+	It("metricAggs and metricValues should map the metrics to ES aggregations", func() {
+		aggregation, err := store.ParseAggregation(payload.New(map[string]interface{}{
+			"metrics": map[string]interface{}{"orders": map[string]interface{}{"count": "*"}, "total": map[string]interface{}{"$sum": "amount"}},
+		}))
+		Expect(err).Should(Succeed())
+		aggs := payload.New(metricAggs(aggregation))
+		Expect(aggs.Len()).Should(Equal(1))
+		Expect(aggs.Get("total").Get("sum").Get("field").String()).Should(Equal("amount"))
+
+		row := payload.New(metricValues(aggregation, payload.New(map[string]interface{}{
+			"total": map[string]interface{}{"value": 30.5},
+		}), 3))
+		Expect(row.Get("orders").Int()).Should(Equal(3))
+		Expect(row.Get("total").Float()).Should(Equal(30.5))
+	})
+
+	It("afterSorts should sort the idField by _id", func() {
+		adapter := Adapter{idField: "documentID"}
+		sorts := payload.New(adapter.afterSorts([]string{"-age", "documentID"}))
+		Expect(sorts.First().Get("age.order").String()).Should(Equal("desc"))
+		Expect(sorts.First().Get("age.missing").String()).Should(Equal("_last"))
+		Expect(sorts.Array()[1].Get("_id.order").String()).Should(Equal("asc"))
+	})
+
+	It("parseFilter should translate the query to ES filters", func() {
+		adapter := Adapter{idField: "documentID"}
+		out, err := adapter.parseFilter(payload.New(map[string]interface{}{
+			"search":       "John",
+			"searchFields": []string{"name"},
+			"query": map[string]interface{}{
+				"documentID": map[string]interface{}{"$in": []string{"a", "b"}},
+				"age":        map[string]interface{}{"$between": []int{18, 30}},
+				"deletedAt":  nil,
+				"email":      map[string]interface{}{"$like": "%@gmail.com"},
+			},
+		}))
+		Expect(err).Should(Succeed())
+		query := out.Get("query.bool")
+		Expect(query.Get("must").First().Get("multi_match.query").String()).Should(Equal("John"))
+		filters := query.Get("filter").First().Get("bool.filter").Array()
+		Expect(len(filters)).Should(Equal(4))
+		Expect(filters[0].Get("range.age.gte").Int()).Should(Equal(18))
+		Expect(filters[0].Get("range.age.lte").Int()).Should(Equal(30))
+		Expect(filters[1].Get("bool.must_not").First().Get("exists.field").String()).Should(Equal("deletedAt"))
+		Expect(filters[2].Get("ids.values").Len()).Should(Equal(2))
+		Expect(filters[3].Get("bool.should").First().Get("wildcard.email.value").String()).Should(Equal("*@gmail.com"))
+
+		_, err = adapter.parseFilter(payload.Empty().Add("query", map[string]interface{}{"age": map[string]interface{}{"$regex": "1"}}))
+		Expect(err).ShouldNot(Succeed())
+	})
+
+	It("bulkFilter should reject an empty filter", func() {
+		adapter := Adapter{idField: "documentID"}
+		_, err := adapter.bulkFilter(payload.New(map[string]interface{}{"query": map[string]interface{}{}}))
+		Expect(err).ShouldNot(Succeed())
+		_, err = adapter.bulkFilter(payload.New(map[string]interface{}{"search": ""}))
+		Expect(err).ShouldNot(Succeed())
+		out, err := adapter.bulkFilter(payload.New(map[string]interface{}{"search": "John"}))
+		Expect(err).Should(Succeed())
+		Expect(out.Get("query.multi_match.query").String()).Should(Equal("John"))
+	})
+
 	It("Find should respect offset and limit", func() {
 		adapter := Adapter{}
 		adapter.Init(logger, map[string]interface{}{
@@ -252,13 +323,19 @@ var _ = Describe("Elastic", func() {
 
 		r := adapter.RemoveById(r1.Get("documentID"))
 		Expect(r.IsError()).Should(BeFalse())
+		Expect(r.Get("deletedCount").Int()).Should(Equal(1))
 
 		r = adapter.Find(payload.Empty())
 		Expect(r.IsError()).Should(BeFalse())
 		Expect(r.Len()).Should(Equal(1))
 
+		r = adapter.RemoveById(r1.Get("documentID"))
+		Expect(r.IsError()).Should(BeFalse())
+		Expect(r.Get("deletedCount").Int()).Should(Equal(0))
+
 		r = adapter.RemoveById(r2.Get("documentID"))
 		Expect(r.IsError()).Should(BeFalse())
+		Expect(r.Get("deletedCount").Int()).Should(Equal(1))
 
 		r = adapter.Find(payload.Empty())
 		Expect(r.IsError()).Should(BeFalse())
@@ -326,4 +403,164 @@ var _ = Describe("Elastic", func() {
 		Expect(r.IsError()).Should(BeFalse())
 		Expect(r.First().Get("age").Int()).Should(Equal(38))
 	})
+
+	It("Insert should return an error when indexing fails", func() {
+		adapter := Adapter{}
+		adapter.Init(logger, map[string]interface{}{
+			"indexName": "insert_error_test_index",
+			"mappings": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"age": map[string]string{"type": "integer"},
+				},
+			},
+		})
+		adapter.Connect()
+		adapter.RemoveAll()
+
+		r := adapter.Insert(payload.Empty().Add("age", "not a number"))
+		Expect(r.IsError()).Should(BeTrue())
+	})
+
+	It("should find documents by id and by ids", func() {
+		adapter := Adapter{}
+		adapter.Init(logger, map[string]interface{}{
+			"indexName": "find_by_id_test_index",
+		})
+		adapter.Connect()
+		adapter.RemoveAll()
+
+		r1 := adapter.Insert(payload.Empty().Add("name", "anne"))
+		r2 := adapter.Insert(payload.Empty().Add("name", "john"))
+
+		r := adapter.FindById(r1.Get("documentID"))
+		Expect(r.Error()).Should(Succeed())
+		Expect(r.Get("name").String()).Should(Equal("anne"))
+		Expect(r.Get("documentID").String()).Should(Equal(r1.Get("documentID").String()))
+
+		r = adapter.FindById(payload.New("not_there"))
+		Expect(r.Exists()).Should(BeFalse())
+
+		r = adapter.FindByIds(payload.EmptyList().AddItem(r2.Get("documentID")).AddItem(payload.New("not_there")).AddItem(r1.Get("documentID")))
+		Expect(r.Error()).Should(Succeed())
+		Expect(r.Len()).Should(Equal(3))
+		Expect(r.Array()[0].Get("name").String()).Should(Equal("john"))
+		Expect(r.Array()[1].Exists()).Should(BeFalse())
+		Expect(r.Array()[2].Get("name").String()).Should(Equal("anne"))
+	})
+
+	It("should count documents", func() {
+		adapter := Adapter{}
+		adapter.Init(logger, map[string]interface{}{
+			"indexName": "count_test_index",
+		})
+		adapter.Connect()
+		adapter.RemoveAll()
+
+		adapter.Insert(payload.Empty().Add("name", "counted"))
+		adapter.Insert(payload.Empty().Add("name", "counted"))
+		adapter.Insert(payload.Empty().Add("name", "other"))
+
+		r := adapter.Count(payload.Empty())
+		Expect(r.Error()).Should(Succeed())
+		Expect(r.Int()).Should(Equal(3))
+
+		r = adapter.Count(payload.New(map[string]interface{}{
+			"search":       "counted",
+			"searchFields": []string{"name"},
+		}))
+		Expect(r.Error()).Should(Succeed())
+		Expect(r.Int()).Should(Equal(2))
+	})
+
+	It("FindAndUpdate should update all matching documents", func() {
+		adapter := Adapter{}
+		adapter.Init(logger, map[string]interface{}{
+			"indexName": "find_and_update_test_index",
+		})
+		adapter.Connect()
+		adapter.RemoveAll()
+
+		//more documents than the default size of a search (10)
+		for age := 10; age < 22; age++ {
+			adapter.Insert(payload.Empty().Add("name", "anne").Add("age", age))
+		}
+		adapter.Insert(payload.Empty().Add("name", "john").Add("age", 22))
+
+		r := adapter.FindAndUpdate(payload.New(map[string]interface{}{
+			"search":       "anne",
+			"searchFields": []string{"name"},
+			"update":       map[string]interface{}{"age": 30},
+		}))
+		Expect(r.Error()).Should(Succeed())
+		Expect(r.Len()).Should(Equal(12))
+		r.ForEach(func(idx interface{}, item moleculer.Payload) bool {
+			Expect(item.Get("age").Int()).Should(Equal(30))
+			return true
+		})
+
+		r = adapter.Find(payload.New(map[string]interface{}{
+			"search":       "john",
+			"searchFields": []string{"name"},
+		}))
+		Expect(r.First().Get("age").Int()).Should(Equal(22))
+	})
+
+	It("should insert, update and remove many documents", func() {
+		adapter := Adapter{}
+		adapter.Init(logger, map[string]interface{}{
+			"indexName": "bulk_test_index",
+		})
+		adapter.Connect()
+		adapter.RemoveAll()
+
+		r := adapter.InsertMany(payload.New([]map[string]interface{}{
+			{"name": "anne", "age": 18},
+			{"name": "anne", "age": 20},
+			{"name": "john", "age": 22},
+		}))
+		Expect(r.Error()).Should(Succeed())
+		Expect(r.Len()).Should(Equal(3))
+		Expect(r.First().Get("documentID").Exists()).Should(BeTrue())
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(3))
+
+		u := adapter.UpdateMany(payload.New(map[string]interface{}{
+			"search":       "anne",
+			"searchFields": []string{"name"},
+			"update":       map[string]interface{}{"age": 30},
+		}))
+		Expect(u.Error()).Should(Succeed())
+		Expect(u.Get("modifiedCount").Int()).Should(Equal(2))
+
+		d := adapter.RemoveMany(payload.New(map[string]interface{}{
+			"ids": []string{r.First().Get("documentID").String()},
+		}))
+		Expect(d.Error()).Should(Succeed())
+		Expect(d.Get("deletedCount").Int()).Should(Equal(1))
+
+		d = adapter.RemoveMany(payload.New(map[string]interface{}{
+			"search":       "anne",
+			"searchFields": []string{"name"},
+		}))
+		Expect(d.Error()).Should(Succeed())
+		Expect(d.Get("deletedCount").Int()).Should(Equal(1))
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(1))
+	})
+})
+
+var _ = storetest.Suite("Elastic Adapter", func() store.Adapter {
+	//keyword fields, so the specs can sort and aggregate by them
+	keyword := map[string]interface{}{"type": "keyword"}
+	return &Adapter{
+		indexName: "store_suite",
+		idField:   "id",
+		mappings: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"name":     keyword,
+				"lastname": keyword,
+				"age":      map[string]interface{}{"type": "integer"},
+				"master":   keyword,
+				"friends":  keyword,
+			},
+		},
+	}
 })
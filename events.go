@@ -0,0 +1,83 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// eventsConfig return the entityEvents setting as a payload. Example:
+//
+//	"entityEvents": map[string]interface{}{
+//		"mode":   "emit", // broadcast (default) or emit
+//		"remove": false,  // disable the event of the remove action
+//	}
+//
+// "entityEvents": false disables the events of all actions.
+func eventsConfig(settings map[string]interface{}) moleculer.Payload {
+	config, exists := settings["entityEvents"]
+	if !exists || config == nil {
+		return payload.Empty()
+	}
+	return payload.New(config)
+}
+
+// eventEnabled check if the action publishes its entity event.
+func eventEnabled(settings map[string]interface{}, action string) bool {
+	config := eventsConfig(settings)
+	if !config.IsMap() {
+		return config.Bool()
+	}
+	return !config.Get(action).Exists() || config.Get(action).Bool()
+}
+
+// publishEvent broadcast or emit the entity event of the action.
+// The event payload is the data with the meta of the caller context.
+func publishEvent(ctx moleculer.Context, getInstance func() *moleculer.ServiceSchema, action, event string, data map[string]interface{}) {
+	settings := getInstance().Settings
+	if !eventEnabled(settings, action) {
+		return
+	}
+	meta := ctx.Meta()
+	if meta == nil || !meta.Exists() {
+		meta = payload.Empty()
+	}
+	data["meta"] = meta.Value()
+	name := getInstance().Name + "." + event
+	if eventsConfig(settings).Get("mode").String() == "emit" {
+		ctx.Emit(name, data)
+		return
+	}
+	ctx.Broadcast(name, data)
+}
+
+// entityChanges return the fields changed between the previous and the new entity: { field: { from, to } }
+func entityChanges(previous, entity moleculer.Payload) map[string]interface{} {
+	changes := map[string]interface{}{}
+	if previous == nil || entity == nil || !previous.IsMap() || !entity.IsMap() {
+		return changes
+	}
+	entity.ForEach(func(field interface{}, value moleculer.Payload) bool {
+		before := previous.Get(field.(string))
+		if !before.Exists() || fmt.Sprint(before.Value()) != fmt.Sprint(value.Value()) {
+			changes[field.(string)] = map[string]interface{}{"from": before.Value(), "to": value.Value()}
+		}
+		return true
+	})
+	previous.ForEach(func(field interface{}, value moleculer.Payload) bool {
+		if !entity.Get(field.(string)).Exists() {
+			changes[field.(string)] = map[string]interface{}{"from": value.Value(), "to": nil}
+		}
+		return true
+	})
+	return changes
+}
+
+// payloadValue return the value of the payload or nil.
+func payloadValue(p moleculer.Payload) interface{} {
+	if p == nil || !p.Exists() || p.IsError() {
+		return nil
+	}
+	return p.Value()
+}
@@ -0,0 +1,279 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+const (
+	hashidsAlphabet     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
+	hashidsSeparators   = "cfhistuCFHISTU"
+	hashidsSepDiv       = 3.5
+	hashidsGuardDiv     = 12.0
+	hashidsHexChunkSize = 12
+)
+
+// HashIDs obfuscates ids using the hashids algorithm (https://hashids.org).
+// Use its EncodeID and DecodeID methods as the encodeID and decodeID settings:
+//
+//	hashIDs := store.HashIDs{Salt: "my salt", MinLength: 8}
+//	Settings: map[string]interface{}{
+//		"encodeID": hashIDs.EncodeID,
+//		"decodeID": hashIDs.DecodeID,
+//	}
+type HashIDs struct {
+	Salt      string
+	MinLength int
+}
+
+// setup generates the alphabet, separators and guards for the salt.
+func (h HashIDs) setup() (alphabet, seps, guards []rune) {
+	salt := []rune(h.Salt)
+	for _, r := range hashidsAlphabet {
+		if strings.ContainsRune(hashidsSeparators, r) {
+			seps = append(seps, r)
+		} else {
+			alphabet = append(alphabet, r)
+		}
+	}
+	seps = consistentShuffle(seps, salt)
+	if len(seps) == 0 || float64(len(alphabet))/float64(len(seps)) > hashidsSepDiv {
+		sepsLength := int(math.Ceil(float64(len(alphabet)) / hashidsSepDiv))
+		if sepsLength == 1 {
+			sepsLength = 2
+		}
+		if sepsLength > len(seps) {
+			diff := sepsLength - len(seps)
+			seps = append(seps, alphabet[:diff]...)
+			alphabet = alphabet[diff:]
+		} else {
+			seps = seps[:sepsLength]
+		}
+	}
+	alphabet = consistentShuffle(alphabet, salt)
+	guardCount := int(math.Ceil(float64(len(alphabet)) / hashidsGuardDiv))
+	if len(alphabet) < 3 {
+		guards = seps[:guardCount]
+		seps = seps[guardCount:]
+	} else {
+		guards = alphabet[:guardCount]
+		alphabet = alphabet[guardCount:]
+	}
+	return alphabet, seps, guards
+}
+
+func consistentShuffle(alphabet, salt []rune) []rune {
+	result := make([]rune, len(alphabet))
+	copy(result, alphabet)
+	if len(salt) == 0 {
+		return result
+	}
+	for i, v, p := len(result)-1, 0, 0; i > 0; i, v = i-1, v+1 {
+		v %= len(salt)
+		integer := int(salt[v])
+		p += integer
+		j := (integer + v + p) % i
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+func hashNumber(input int64, alphabet []rune) []rune {
+	hash := []rune{}
+	size := int64(len(alphabet))
+	for {
+		hash = append([]rune{alphabet[input%size]}, hash...)
+		input = input / size
+		if input == 0 {
+			return hash
+		}
+	}
+}
+
+func unhashNumber(input, alphabet []rune) (int64, error) {
+	var number int64
+	for _, r := range input {
+		pos := runeIndex(alphabet, r)
+		if pos < 0 {
+			return 0, errors.New("Invalid hash!")
+		}
+		number = number*int64(len(alphabet)) + int64(pos)
+	}
+	return number, nil
+}
+
+func runeIndex(list []rune, r rune) int {
+	for i, item := range list {
+		if item == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Encode encodes a list of non negative numbers in a hash.
+func (h HashIDs) Encode(numbers ...int64) (string, error) {
+	if len(numbers) == 0 {
+		return "", errors.New("Encode() requires at least one number!")
+	}
+	alphabet, seps, guards := h.setup()
+	salt := []rune(h.Salt)
+	var numbersHash int64
+	for i, n := range numbers {
+		if n < 0 {
+			return "", errors.New("Encode() only support non negative numbers!")
+		}
+		numbersHash += n % int64(i+100)
+	}
+	lottery := alphabet[numbersHash%int64(len(alphabet))]
+	result := []rune{lottery}
+	for i, n := range numbers {
+		buffer := append(append([]rune{lottery}, salt...), alphabet...)
+		alphabet = consistentShuffle(alphabet, buffer[:len(alphabet)])
+		last := hashNumber(n, alphabet)
+		result = append(result, last...)
+		if i+1 < len(numbers) {
+			n %= int64(last[0]) + int64(i)
+			result = append(result, seps[n%int64(len(seps))])
+		}
+	}
+	if len(result) < h.MinLength {
+		guard := guards[(numbersHash+int64(result[0]))%int64(len(guards))]
+		result = append([]rune{guard}, result...)
+		if len(result) < h.MinLength {
+			guard = guards[(numbersHash+int64(result[2]))%int64(len(guards))]
+			result = append(result, guard)
+		}
+	}
+	half := len(alphabet) / 2
+	for len(result) < h.MinLength {
+		alphabet = consistentShuffle(alphabet, alphabet)
+		result = append(append(append([]rune{}, alphabet[half:]...), result...), alphabet[:half]...)
+		if excess := len(result) - h.MinLength; excess > 0 {
+			result = result[excess/2 : excess/2+h.MinLength]
+		}
+	}
+	return string(result), nil
+}
+
+func splitRunes(input []rune, separators []rune) [][]rune {
+	parts := [][]rune{}
+	current := []rune{}
+	for _, r := range input {
+		if runeIndex(separators, r) >= 0 {
+			parts = append(parts, current)
+			current = []rune{}
+		} else {
+			current = append(current, r)
+		}
+	}
+	return append(parts, current)
+}
+
+// Decode decodes the numbers of a hash generated by Encode.
+func (h HashIDs) Decode(hash string) ([]int64, error) {
+	alphabet, seps, guards := h.setup()
+	salt := []rune(h.Salt)
+	parts := splitRunes([]rune(hash), guards)
+	breakdown := parts[0]
+	if len(parts) == 2 || len(parts) == 3 {
+		breakdown = parts[1]
+	}
+	if len(breakdown) == 0 {
+		return nil, errors.New("Invalid hash!")
+	}
+	lottery := breakdown[0]
+	numbers := []int64{}
+	for _, sub := range splitRunes(breakdown[1:], seps) {
+		buffer := append(append([]rune{lottery}, salt...), alphabet...)
+		alphabet = consistentShuffle(alphabet, buffer[:len(alphabet)])
+		number, err := unhashNumber(sub, alphabet)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, number)
+	}
+	if check, err := h.Encode(numbers...); err != nil || check != hash {
+		return nil, errors.New("Invalid hash!")
+	}
+	return numbers, nil
+}
+
+// id kinds, the first number of the hash.
+const (
+	intID = 0
+	hexID = 1
+)
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodeID encodes integer ids (SQLite) and hex string ids (Mongo ObjectID).
+// Other ids are returned unchanged.
+func (h HashIDs) EncodeID(id moleculer.Payload) moleculer.Payload {
+	numbers := []int64{}
+	if s, isString := id.Value().(string); isString {
+		if !isHex(s) {
+			return id
+		}
+		//each chunk is prefixed with 1 to keep the leading zeros
+		numbers = append(numbers, hexID)
+		for i := 0; i < len(s); i += hashidsHexChunkSize {
+			end := i + hashidsHexChunkSize
+			if end > len(s) {
+				end = len(s)
+			}
+			n, err := strconv.ParseInt("1"+s[i:end], 16, 64)
+			if err != nil {
+				return id
+			}
+			numbers = append(numbers, n)
+		}
+	} else if isNumber(id.Value()) {
+		numbers = append(numbers, intID, id.Int64())
+	} else {
+		return id
+	}
+	hash, err := h.Encode(numbers...)
+	if err != nil {
+		return id
+	}
+	return payload.New(hash)
+}
+
+// DecodeID decodes ids encoded by EncodeID. A raw integer or hex id, that EncodeID would have encoded, is an
+// invalid id error, so the database ids can not be used in place of the hashes. Other values are returned unchanged.
+func (h HashIDs) DecodeID(id moleculer.Payload) moleculer.Payload {
+	numbers, err := h.Decode(id.String())
+	if err != nil || len(numbers) < 2 {
+		if s, isString := id.Value().(string); isNumber(id.Value()) || (isString && isHex(s)) {
+			return payload.Error("Invalid id: ", id.String())
+		}
+		return id
+	}
+	if numbers[0] == intID && len(numbers) == 2 {
+		return payload.New(numbers[1])
+	}
+	if numbers[0] == hexID {
+		hex := ""
+		for _, n := range numbers[1:] {
+			hex = hex + strconv.FormatInt(n, 16)[1:]
+		}
+		return payload.New(hex)
+	}
+	return id
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"github.com/moleculer-go/moleculer/payload"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HashIDs", func() {
+
+	It("should encode and decode numbers", func() {
+		h := HashIDs{Salt: "this is my salt"}
+		Expect(h.Encode(12345)).Should(Equal("NkK9"))
+		Expect(h.Encode(683, 94108, 123, 5)).Should(Equal("aBMswoO2UB3Sj"))
+		Expect(h.Decode("aBMswoO2UB3Sj")).Should(Equal([]int64{683, 94108, 123, 5}))
+
+		h = HashIDs{Salt: "this is my salt", MinLength: 8}
+		Expect(h.Encode(1)).Should(Equal("gB0NV05e"))
+		Expect(h.Decode("gB0NV05e")).Should(Equal([]int64{1}))
+	})
+
+	It("should fail to decode an invalid hash", func() {
+		h := HashIDs{Salt: "this is my salt"}
+		_, err := h.Decode("NkK8")
+		Expect(err).Should(HaveOccurred())
+		_, err = h.Decode("")
+		Expect(err).Should(HaveOccurred())
+		_, err = HashIDs{Salt: "other salt"}.Decode("NkK9")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("EncodeID and DecodeID should round trip integer and hex ids", func() {
+		h := HashIDs{Salt: "moleculer", MinLength: 6}
+
+		encoded := h.EncodeID(payload.New(int64(42)))
+		Expect(encoded.String()).ShouldNot(Equal("42"))
+		Expect(len(encoded.String()) >= 6).Should(BeTrue())
+		Expect(h.DecodeID(encoded).Int64()).Should(Equal(int64(42)))
+
+		objectID := "05d8f5a1b2c3d4e5f6a7b8c9"
+		encoded = h.EncodeID(payload.New(objectID))
+		Expect(encoded.String()).ShouldNot(Equal(objectID))
+		Expect(h.DecodeID(encoded).String()).Should(Equal(objectID))
+	})
+
+	It("EncodeID and DecodeID should not change other ids", func() {
+		h := HashIDs{Salt: "moleculer"}
+		Expect(h.EncodeID(payload.New("not-an-id")).String()).Should(Equal("not-an-id"))
+		Expect(h.DecodeID(payload.New("not-an-id")).String()).Should(Equal("not-an-id"))
+	})
+
+	It("DecodeID should reject the raw integer and hex ids", func() {
+		h := HashIDs{Salt: "moleculer"}
+		Expect(h.DecodeID(payload.New(5)).IsError()).Should(BeTrue())
+		Expect(h.DecodeID(payload.New("5")).IsError()).Should(BeTrue())
+		Expect(h.DecodeID(payload.New("05d8f5a1b2c3d4e5f6a7b8c9")).IsError()).Should(BeTrue())
+		Expect(h.DecodeID(payload.New("5")).Error().Error()).Should(Equal("Invalid id: 5"))
+	})
+})
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"github.com/moleculer-go/moleculer"
+)
+
+// Hook is an extension point called by the mixin actions before or after the adapter operation.
+// adapter is scoped to the action transaction when the adapter supports transactions.
+// The returned payload replaces the params (before hooks) or the result (after hooks), nil keeps them unchanged.
+// Return an error payload to abort the action, the transaction is rolled back.
+type Hook func(ctx moleculer.Context, adapter Adapter, p moleculer.Payload) moleculer.Payload
+
+// hook settings names
+const (
+	//beforeEntityCreate : called with each entity before it is inserted by the create and insertMany actions.
+	beforeEntityCreate = "beforeEntityCreate"
+	//afterEntityCreate : called with each inserted entity.
+	afterEntityCreate = "afterEntityCreate"
+	//beforeEntityUpdate : called with the params of the update, updateMany, findAndUpdate and restore actions.
+	beforeEntityUpdate = "beforeEntityUpdate"
+	//afterEntityUpdate : called with the result of the update, updateMany, findAndUpdate and restore actions.
+	afterEntityUpdate = "afterEntityUpdate"
+	//beforeEntityRemove : called with the params of the remove, removeMany and purge actions.
+	beforeEntityRemove = "beforeEntityRemove"
+	//afterEntityRemove : called with the result of the remove, removeMany and purge actions.
+	afterEntityRemove = "afterEntityRemove"
+	//afterEntityChange : called after afterEntityCreate, afterEntityUpdate and afterEntityRemove.
+	afterEntityChange = "afterEntityChange"
+	//beforeQuery : called with the params of the find, list and count actions.
+	beforeQuery = "beforeQuery"
+	//afterQuery : called with the result of the find and get actions and the rows of the list action.
+	afterQuery = "afterQuery"
+)
+
+// hookFromSettings return the hook with the given name or nil when not defined.
+func hookFromSettings(settings map[string]interface{}, name string) Hook {
+	switch fn := settings[name].(type) {
+	case Hook:
+		return fn
+	case func(moleculer.Context, Adapter, moleculer.Payload) moleculer.Payload:
+		return fn
+	}
+	return nil
+}
+
+// hasHooks check if any of the hooks is defined.
+func hasHooks(settings map[string]interface{}, names ...string) bool {
+	for _, name := range names {
+		if hookFromSettings(settings, name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// callHook calls the hook with the given name. When the hook is not defined p is returned.
+func callHook(settings map[string]interface{}, name string, ctx moleculer.Context, adapter Adapter, p moleculer.Payload) moleculer.Payload {
+	hook := hookFromSettings(settings, name)
+	if hook == nil || (p != nil && p.IsError()) {
+		return p
+	}
+	if r := hook(ctx, adapter, p); r != nil {
+		return r
+	}
+	return p
+}
+
+// callAfterHooks calls the after hook with the given name followed by afterEntityChange.
+func callAfterHooks(settings map[string]interface{}, name string, ctx moleculer.Context, adapter Adapter, result moleculer.Payload) moleculer.Payload {
+	if result.IsError() {
+		return result
+	}
+	result = callHook(settings, name, ctx, adapter, result)
+	if result.IsError() {
+		return result
+	}
+	return callHook(settings, afterEntityChange, ctx, adapter, result)
+}
+
+// runWithHooks calls fn in a transaction when any of the hooks is defined,
+// so the hooks and the adapter operation are committed or rolled back together.
+// Without hooks, or when the transaction cannot begin (e.g. a standalone Mongo server),
+// fn is called with the adapter itself.
+func runWithHooks(adapter Adapter, settings map[string]interface{}, hooks []string, fn func(tx Adapter) moleculer.Payload) moleculer.Payload {
+	tadapter, ok := adapter.(TransactionalAdapter)
+	if !ok || !hasHooks(settings, hooks...) {
+		return fn(adapter)
+	}
+	tx, err := tadapter.Begin()
+	if err != nil {
+		return fn(adapter)
+	}
+	return runTransaction(tx, fn)
+}
@@ -3,7 +3,9 @@ package store
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/go-memdb"
 	"github.com/moleculer-go/moleculer"
@@ -14,23 +16,64 @@ import (
 
 //MemoryAdapter stores data in memory!
 type MemoryAdapter struct {
+	// SearchFields are searched when the search param has no searchFields. Without SearchFields all the fields are searched.
 	SearchFields []string
-	Table        string
-	db           *memdb.MemDB
-	logger       *log.Entry
+	// SearchMode is SearchContains (default), SearchPrefix or SearchWords.
+	SearchMode string
+	Table      string
+	db         *memdb.MemDB
+	logger     *log.Entry
+	idField    string
+	// txn is set when the adapter is scoped to a transaction
+	txn *memdb.Txn
+	// inserted keeps the insertion order of the records, so records with the same sort values
+	// are returned in the order they were inserted, like in the other adapters.
+	inserted *insertionOrder
+}
+
+// insertionOrder maps the record ids to their insertion sequence.
+type insertionOrder struct {
+	mutex    sync.Mutex
+	sequence int64
+	ids      map[string]int64
+}
+
+func (o *insertionOrder) add(id string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.sequence++
+	o.ids[id] = o.sequence
+}
+
+func (o *insertionOrder) of(id string) int64 {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.ids[id]
 }
 
 func (adapter *MemoryAdapter) Init(logger *log.Entry, settings map[string]interface{}) {
 	adapter.logger = logger
+	if field, ok := settings["idField"].(string); ok && field != "" {
+		adapter.idField = field
+	}
+}
+
+// idFieldName return the idField setting. Default: id
+func (adapter *MemoryAdapter) idFieldName() string {
+	if adapter.idField == "" {
+		return "id"
+	}
+	return adapter.idField
 }
 
 func (adapter *MemoryAdapter) generateSchema() *memdb.DBSchema {
 
+	idField := adapter.idFieldName()
 	Indexes := map[string]*memdb.IndexSchema{
 		"id": &memdb.IndexSchema{
 			Name:    "id",
 			Unique:  true,
-			Indexer: &PayloadIndex{Field: "id"},
+			Indexer: &PayloadIndex{Field: idField},
 		},
 		"all": &memdb.IndexSchema{
 			Name:    "all",
@@ -38,13 +81,12 @@ func (adapter *MemoryAdapter) generateSchema() *memdb.DBSchema {
 			Indexer: &PayloadIndex{Field: "all"},
 		},
 	}
-	if adapter.SearchFields != nil {
-		for _, field := range adapter.SearchFields {
-			Indexes[field] = &memdb.IndexSchema{
-				Name:    field,
-				Unique:  false,
-				Indexer: &PayloadIndex{Field: field},
-			}
+	if adapter.SearchMode == SearchWords && len(adapter.SearchFields) > 0 {
+		Indexes[wordsIndex] = &memdb.IndexSchema{
+			Name:         wordsIndex,
+			Unique:       false,
+			AllowMissing: true,
+			Indexer:      &WordsIndex{Fields: adapter.SearchFields},
 		}
 	}
 	return &memdb.DBSchema{
@@ -64,6 +106,7 @@ func (adapter *MemoryAdapter) Connect() error {
 		return err
 	}
 	adapter.db = db
+	adapter.inserted = &insertionOrder{ids: map[string]int64{}}
 	return nil
 }
 
@@ -72,72 +115,239 @@ func (adapter *MemoryAdapter) Disconnect() error {
 	return nil
 }
 
-func (adapter *MemoryAdapter) FindAndUpdate(param moleculer.Payload) moleculer.Payload {
-	update := param.Get("update")
-	param = param.Remove("update")
-
-	originals := adapter.Find(param)
-	if originals.IsError() {
-		return originals
+// begin return the memdb transaction used by one operation and the function to finish it.
+// When the adapter is scoped to a transaction, finishing it is left to Commit/Rollback.
+func (adapter *MemoryAdapter) begin(write bool) (*memdb.Txn, func(commit bool)) {
+	if adapter.txn != nil {
+		return adapter.txn, func(bool) {}
 	}
-	result := []moleculer.Payload{}
-	for _, item := range originals.Array() {
-		id := item.Get("id")
-		if err := adapter.UpdateById(id, update); err != nil {
-			result = append(result, payload.New(err))
+	tx := adapter.db.Txn(write)
+	return tx, func(commit bool) {
+		if commit {
+			tx.Commit()
 		} else {
-			result = append(result, adapter.FindById(id))
+			tx.Abort()
 		}
 	}
-	return payload.New(result)
 }
 
-func (adapter *MemoryAdapter) Find(params moleculer.Payload) moleculer.Payload {
-	searchFields := []string{"all"}
-	search := "*"
-	if params.Get("searchFields").Exists() {
-		searchFields = params.Get("searchFields").StringArray()
+// memoryTransaction is a MemoryAdapter scoped to a memdb write transaction.
+type memoryTransaction struct {
+	*MemoryAdapter
+}
+
+func (t *memoryTransaction) Commit() error {
+	t.txn.Commit()
+	return nil
+}
+
+func (t *memoryTransaction) Rollback() error {
+	t.txn.Abort()
+	return nil
+}
+
+// Begin starts a memdb write transaction. Other writes will wait until it is committed or rolled back.
+func (adapter *MemoryAdapter) Begin() (Transaction, error) {
+	if adapter.db == nil {
+		return nil, errors.New("Adapter not connected!")
 	}
+	if adapter.txn != nil {
+		return nil, errors.New("Nested transactions are not supported!")
+	}
+	scoped := *adapter
+	scoped.txn = adapter.db.Txn(true)
+	return &memoryTransaction{&scoped}, nil
+}
+
+// inTxn calls fn with an adapter scoped to a write transaction, reusing the current one when there is one.
+// The transaction is committed unless fn returns an error payload.
+func (adapter *MemoryAdapter) inTxn(fn func(scoped *MemoryAdapter) moleculer.Payload) moleculer.Payload {
+	if adapter.txn != nil {
+		return fn(adapter)
+	}
+	tx, err := adapter.Begin()
+	if err != nil {
+		return payload.New(err)
+	}
+	r := fn(tx.(*memoryTransaction).MemoryAdapter)
+	if r.IsError() {
+		tx.Rollback()
+	} else {
+		tx.Commit()
+	}
+	return r
+}
+
+// FindAndUpdate applies param.update to the records matching the params in a single transaction
+// and returns them after the update.
+func (adapter *MemoryAdapter) FindAndUpdate(param moleculer.Payload) moleculer.Payload {
+	update := param.Get("update")
+	if !update.Exists() || !update.IsMap() {
+		return payload.Error("FindAndUpdate() requires the update param!")
+	}
+	param = param.Remove("update")
+	return adapter.inTxn(func(scoped *MemoryAdapter) moleculer.Payload {
+		originals := scoped.Find(param)
+		if originals.IsError() {
+			return originals
+		}
+		result := []moleculer.Payload{}
+		for _, item := range originals.Array() {
+			id := item.Get(scoped.idFieldName())
+			if r := scoped.UpdateById(id, payload.New(update.RawMap())); r.IsError() {
+				return r
+			}
+			result = append(result, scoped.FindById(id))
+		}
+		return payload.New(result)
+	})
+}
+
+// Find return the records matching the search (see SearchMode) and the query, sorted and paginated.
+func (adapter *MemoryAdapter) Find(params moleculer.Payload) moleculer.Payload {
+	search := ""
 	if params.Get("search").Exists() {
 		search = params.Get("search").String()
 	}
-
-	indexName := strings.Join(searchFields, "-")
-	tx := adapter.db.Txn(false)
-	defer tx.Abort()
-	results, err := tx.Get(adapter.Table, indexName, search)
+	index, args := adapter.searchCandidates(params, search)
+	tx, done := adapter.begin(false)
+	defer done(false)
+	results, err := tx.Get(adapter.Table, index, args...)
 	if err != nil {
 		return payload.Error("Failed trying to find. Error: ", err.Error())
 	}
+	filter, err := ParseQuery(params.Get("query"))
+	if err != nil {
+		return payload.New(err)
+	}
 	items := []moleculer.Payload{}
+	//the words index returns a record once for each word with the prefix
+	found := map[string]bool{}
 	for {
 		value := results.Next()
 		if value == nil {
 			break
 		}
-		items = append(items, payload.New(value))
+		item := payload.New(value)
+		id := item.Get(adapter.idFieldName()).String()
+		if found[id] {
+			continue
+		}
+		found[id] = true
+		if (search == "" || adapter.searchMatch(params, item, search)) && filter.Match(item) {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return adapter.inserted.of(items[i].Get(adapter.idFieldName()).String()) < adapter.inserted.of(items[j].Get(adapter.idFieldName()).String())
+	})
+	sortRecords(items, sortEntries(params.Get("sort")))
+	return payload.New(paginate(items, params.Get("offset"), params.Get("limit")))
+}
+
+// sortEntries return the fields of the sort param: "-age name" or []string{"-age", "name"}.
+func sortEntries(param moleculer.Payload) []string {
+	if !param.Exists() {
+		return []string{}
+	}
+	if param.IsArray() {
+		return param.StringArray()
+	}
+	return strings.Fields(param.String())
+}
+
+// sortRecords sorts the records by the fields, a field starting with - is sorted in descending order.
+// Like in SQL, records without the field come first in ascending order. The sort is stable.
+func sortRecords(items []moleculer.Payload, fields []string) {
+	if len(fields) == 0 {
+		return
 	}
-	return payload.New(items)
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, field := range fields {
+			desc := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+			a, b := items[i].Get(field), items[j].Get(field)
+			result := 0
+			switch {
+			case !a.Exists() && !b.Exists():
+			case !a.Exists():
+				result = -1
+			case !b.Exists():
+				result = 1
+			default:
+				result = CompareValues(a.Value(), b.Value())
+			}
+			if result != 0 {
+				return (result < 0) != desc
+			}
+		}
+		return false
+	})
 }
 
+// paginate skips offset records and returns at most limit records. A negative limit returns all records.
+func paginate(items []moleculer.Payload, offset, limit moleculer.Payload) []moleculer.Payload {
+	if offset.Exists() && offset.Int() > 0 {
+		if offset.Int() >= len(items) {
+			return []moleculer.Payload{}
+		}
+		items = items[offset.Int():]
+	}
+	if limit.Exists() && limit.Int() >= 0 && limit.Int() < len(items) {
+		items = items[:limit.Int()]
+	}
+	return items
+}
+
+// Aggregate implements AggregateAdapter, calculating the metrics of the matching records in memory.
+func (adapter *MemoryAdapter) Aggregate(params moleculer.Payload, aggregation Aggregation) moleculer.Payload {
+	items := adapter.Find(params.Remove("sort", "limit", "offset"))
+	if items.IsError() {
+		return items
+	}
+	stream := make(chan moleculer.Payload, items.Len())
+	for _, item := range items.Array() {
+		stream <- item
+	}
+	close(stream)
+	return AggregateStream(stream, aggregation)
+}
+
+// Distinct implements DistinctAdapter, collecting the distinct values of the matching records in memory.
+func (adapter *MemoryAdapter) Distinct(params moleculer.Payload, field string) moleculer.Payload {
+	items := adapter.Find(params.Remove("sort", "limit", "offset"))
+	if items.IsError() {
+		return items
+	}
+	stream := make(chan moleculer.Payload, items.Len())
+	for _, item := range items.Array() {
+		stream <- item
+	}
+	close(stream)
+	return DistinctStream(stream, field)
+}
+
+// FindOne return the first record matching the params (search, query and sort) or an empty payload.
 func (adapter *MemoryAdapter) FindOne(params moleculer.Payload) moleculer.Payload {
-	indexName := strings.Join(params.Get("searchFields").StringArray(), "-")
-	search := params.Get("search").String()
-	tx := adapter.db.Txn(false)
-	defer tx.Abort()
-	result, err := tx.First(adapter.Table, indexName, search)
-	if err != nil {
-		return payload.Error("Failed trying to findOne. searchFields: ", indexName, " search: ", search, " Error: ", err.Error())
+	result := adapter.Find(payload.Empty().AddMany(params.RawMap()).Add("limit", 1))
+	if result.IsError() {
+		return result
 	}
-	return payload.New(result)
+	if result.Len() == 0 {
+		return payload.New(nil)
+	}
+	return result.First()
 }
 
 func (adapter *MemoryAdapter) FindById(params moleculer.Payload) moleculer.Payload {
-	findParams := payload.New(map[string]interface{}{
-		"searchFields": []string{"id"},
-		"search":       params.String(),
-	})
-	return adapter.FindOne(findParams)
+	search := params.String()
+	tx, done := adapter.begin(false)
+	defer done(false)
+	result, err := tx.First(adapter.Table, "id", search)
+	if err != nil {
+		return payload.Error("Failed trying to find by id: ", search, " Error: ", err.Error())
+	}
+	return payload.New(result)
 }
 
 func (adapter *MemoryAdapter) FindByIds(params moleculer.Payload) moleculer.Payload {
@@ -156,67 +366,173 @@ func (adapter *MemoryAdapter) Count(params moleculer.Payload) moleculer.Payload
 
 func (adapter *MemoryAdapter) Insert(params moleculer.Payload) moleculer.Payload {
 	params = params.AddMany(map[string]interface{}{
-		"id":  util.RandomString(12),
-		"all": "*",
+		adapter.idFieldName(): util.RandomString(12),
+		"all":                 "*",
 	})
-	tx := adapter.db.Txn(true)
+	tx, done := adapter.begin(true)
 	err := tx.Insert(adapter.Table, params)
 	if err != nil {
-		defer tx.Abort()
+		done(false)
 		return payload.Error("Failed trying to Insert. Error: ", err.Error())
 	}
-	defer tx.Commit()
+	done(true)
+	adapter.inserted.add(params.Get(adapter.idFieldName()).String())
 	return params
 }
 
+// InsertMany inserts all records of the list in a single transaction.
+func (adapter *MemoryAdapter) InsertMany(params moleculer.Payload) moleculer.Payload {
+	if !params.IsArray() {
+		return payload.Error("InsertMany() only support lists!")
+	}
+	return adapter.inTxn(func(scoped *MemoryAdapter) moleculer.Payload {
+		list := []moleculer.Payload{}
+		for _, item := range params.Array() {
+			r := scoped.Insert(item)
+			if r.IsError() {
+				return r
+			}
+			list = append(list, r)
+		}
+		return payload.New(list)
+	})
+}
+
+// UpdateMany applies params.update to all records matching the params in a single transaction.
+func (adapter *MemoryAdapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	update := params.Get("update")
+	if !update.Exists() || !update.IsMap() {
+		return payload.Error("UpdateMany() requires the update param!")
+	}
+	if err := noFilterError(params); err != nil {
+		return payload.New(err)
+	}
+	return adapter.inTxn(func(scoped *MemoryAdapter) moleculer.Payload {
+		items := scoped.Find(params.Remove("update"))
+		if items.IsError() {
+			return items
+		}
+		for _, item := range items.Array() {
+			r := scoped.UpdateById(item.Get(scoped.idFieldName()), payload.New(update.RawMap()))
+			if r.IsError() {
+				return r
+			}
+		}
+		return payload.Empty().Add("modifiedCount", items.Len())
+	})
+}
+
+// noFilterError return an error when the query and the search of the params are empty, so UpdateMany and
+// RemoveMany do not change all the records.
+func noFilterError(params moleculer.Payload) error {
+	filter, err := ParseQuery(params.Get("query"))
+	if err != nil {
+		return err
+	}
+	search := params.Get("search")
+	if filter.IsEmpty() && (!search.Exists() || search.String() == "") {
+		return errors.New("A query or search filter is required to change many records.")
+	}
+	return nil
+}
+
+// RemoveMany removes the records with params.ids or matching the params in a single transaction.
+func (adapter *MemoryAdapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	if !params.Get("ids").Exists() {
+		if err := noFilterError(params); err != nil {
+			return payload.New(err)
+		}
+	}
+	return adapter.inTxn(func(scoped *MemoryAdapter) moleculer.Payload {
+		var items moleculer.Payload
+		if params.Get("ids").Exists() {
+			items = scoped.FindByIds(params.Get("ids"))
+		} else {
+			items = scoped.Find(params)
+		}
+		if items.IsError() {
+			return items
+		}
+		deletedCount := 0
+		for _, item := range items.Array() {
+			if !item.Exists() {
+				continue
+			}
+			r := scoped.RemoveById(item.Get(scoped.idFieldName()))
+			if r.IsError() {
+				return r
+			}
+			deletedCount = deletedCount + r.Get("deletedCount").Int()
+		}
+		return payload.Empty().Add("deletedCount", deletedCount)
+	})
+}
+
 func (adapter *MemoryAdapter) Update(params moleculer.Payload) moleculer.Payload {
-	one := adapter.FindById(params.Get("id"))
+	one := adapter.FindById(params.Get(adapter.idFieldName()))
 	if !one.IsError() && one.Exists() {
-		tx := adapter.db.Txn(true)
+		tx, done := adapter.begin(true)
 		err := tx.Delete(adapter.Table, one.Value())
 		if err != nil {
-			defer tx.Abort()
+			done(false)
 			return payload.Error("Failed trying to update record. source error: ", err.Error())
 		}
-		rec := one.AddMany(params.RawMap())
+		//copy the record, so changes are not visible outside the transaction before commit
+		rec := payload.Empty().AddMany(one.RawMap()).AddMany(params.RawMap())
 		err = tx.Insert(adapter.Table, rec)
 		if err != nil {
-			defer tx.Abort()
+			done(false)
 			return payload.Error("Failed trying to update record. source error: ", err.Error())
 		}
-		defer tx.Commit()
+		done(true)
 		return rec
 	}
-	return payload.Error("Failed trying to update record. Could not find record with id: ", params.Get("id").String())
+	return payload.Error("Failed trying to update record. Could not find record with id: ", params.Get(adapter.idFieldName()).String())
 }
 
 func (adapter *MemoryAdapter) UpdateById(id, params moleculer.Payload) moleculer.Payload {
-	return adapter.Update(params.Add("id", id))
+	return adapter.Update(params.Add(adapter.idFieldName(), id))
+}
+
+// UpdateByIdVersion applies the update in a transaction when the record versionField is equal to version.
+// returns payload.New(nil) when no record matched.
+func (adapter *MemoryAdapter) UpdateByIdVersion(id moleculer.Payload, versionField string, version int64, update moleculer.Payload) moleculer.Payload {
+	return adapter.inTxn(func(scoped *MemoryAdapter) moleculer.Payload {
+		current := scoped.FindById(id)
+		if current.IsError() {
+			return current
+		}
+		if !current.Exists() || current.Get(versionField).Int64() != version {
+			return payload.New(nil)
+		}
+		return scoped.UpdateById(id, update)
+	})
 }
 
 func (adapter *MemoryAdapter) RemoveById(params moleculer.Payload) moleculer.Payload {
 	one := adapter.FindById(params)
 	if !one.IsError() && one.Exists() {
-		tx := adapter.db.Txn(true)
+		tx, done := adapter.begin(true)
 		err := tx.Delete(adapter.Table, one.Value())
 		if err != nil {
-			defer tx.Abort()
+			done(false)
 			return payload.Error("Failed trying to removed record. source error: ", err.Error())
 		}
-		defer tx.Commit()
+		done(true)
 		return payload.Empty().Add("deletedCount", 1)
 	}
-	return nil
+	return payload.Empty().Add("deletedCount", 0)
 }
 
 func (adapter *MemoryAdapter) RemoveAll() moleculer.Payload {
-	items := adapter.Count(payload.New(nil))
-	if items.IsError() {
-		return items
+	tx, done := adapter.begin(true)
+	count, err := tx.DeleteAll(adapter.Table, "all", "*")
+	if err != nil {
+		done(false)
+		return payload.Error("Failed trying to remove all records. source error: ", err.Error())
 	}
-	adapter.Disconnect()
-	adapter.Connect()
-	return items
+	done(true)
+	return payload.New(count)
 }
 
 type PayloadIndex struct {
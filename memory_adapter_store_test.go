@@ -0,0 +1,14 @@
+package store_test
+
+import (
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/storetest"
+)
+
+// the storetest package imports store, so the conformance suite is registered from the external test package.
+var _ = storetest.Suite("Memory Adapter", func() store.Adapter {
+	return &store.MemoryAdapter{
+		Table:        "users",
+		SearchFields: []string{"name"},
+	}
+})
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"os"
 
 	"github.com/moleculer-go/cupaloy/v2"
@@ -40,6 +41,114 @@ var _ = Describe("MemoryAdapter", func() {
 		Expect(snap.SnapshotMulti("Find()", r.Remove("id", "friends", "master").Sort("lastname"))).Should(Succeed())
 	})
 
+	It("Find() should search case-insensitive substrings in the searchFields", func() {
+		search := func(params M) []string {
+			r := adapter.Find(payload.New(params).Add("sort", "lastname"))
+			Expect(r.Error()).Should(BeNil())
+			lastnames := []string{}
+			for _, item := range r.Array() {
+				lastnames = append(lastnames, item.Get("lastname").String())
+			}
+			return lastnames
+		}
+		Expect(search(M{"search": "OHN"})).Should(Equal([]string{"Snow", "Travolta"}))
+		Expect(search(M{"search": "an", "searchFields": []string{"name", "lastname"}})).Should(Equal([]string{"Assange", "Man", "Pan"}))
+		Expect(search(M{"search": "an", "searchFields": "lastname", "query": M{"age": 13}})).Should(Equal([]string{"Man", "Pan"}))
+		Expect(search(M{"search": "volta"})).Should(BeEmpty())
+		Expect(adapter.Count(payload.New(M{"search": "J"})).Int()).Should(Equal(3))
+	})
+
+	It("Find() should search the prefix in SearchPrefix mode", func() {
+		prefixAdapter := &MemoryAdapter{Table: "user", SearchFields: []string{"name", "lastname"}, SearchMode: SearchPrefix}
+		mocks.ConnectAndLoadUsers(prefixAdapter)
+		defer prefixAdapter.Disconnect()
+		Expect(prefixAdapter.Count(payload.New(M{"search": "jo"})).Int()).Should(Equal(2))
+		Expect(prefixAdapter.Count(payload.New(M{"search": "ma"})).Int()).Should(Equal(2))
+		Expect(prefixAdapter.Count(payload.New(M{"search": "ohn"})).Int()).Should(Equal(0))
+	})
+
+	It("Find() should match all the words with the inverted index in SearchWords mode", func() {
+		wordsAdapter := &MemoryAdapter{Table: "posts", SearchFields: []string{"title", "tags"}, SearchMode: SearchWords}
+		Expect(wordsAdapter.Connect()).Should(Succeed())
+		defer wordsAdapter.Disconnect()
+		wordsAdapter.InsertMany(payload.New([]M{
+			{"title": "Searching in memory", "tags": []string{"Prototype"}},
+			{"title": "Search, the SQLite way", "tags": []string{"full-text", "production"}},
+			{"title": "Without search fields"},
+			{"body": "no title"},
+		}))
+		titles := func(params M) []string {
+			r := wordsAdapter.Find(payload.New(params).Add("sort", "title"))
+			Expect(r.Error()).Should(BeNil())
+			list := []string{}
+			for _, item := range r.Array() {
+				list = append(list, item.Get("title").String())
+			}
+			return list
+		}
+		Expect(titles(M{"search": "SEARCH"})).Should(Equal([]string{"Search, the SQLite way", "Searching in memory", "Without search fields"}))
+		Expect(titles(M{"search": "sqlite search"})).Should(Equal([]string{"Search, the SQLite way"}))
+		Expect(titles(M{"search": "proto"})).Should(Equal([]string{"Searching in memory"}))
+		Expect(titles(M{"search": "text full"})).Should(Equal([]string{"Search, the SQLite way"}))
+		Expect(titles(M{"search": "ite"})).Should(BeEmpty())
+		r := wordsAdapter.Find(payload.New(M{"search": "title", "searchFields": "body"}))
+		Expect(r.Len()).Should(Equal(1))
+		Expect(r.First().Get("body").String()).Should(Equal("no title"))
+
+		wordsAdapter.Update(payload.New(wordsAdapter.FindOne(payload.New(M{"search": "memory"})).RawMap()).Add("title", "Prototypes"))
+		Expect(titles(M{"search": "memory"})).Should(BeEmpty())
+		Expect(titles(M{"search": "proto"})).Should(Equal([]string{"Prototypes"}))
+	})
+
+	It("Find() should match the portable query operators", func() {
+		find := func(query map[string]interface{}) moleculer.Payload {
+			r := adapter.Find(payload.Empty().Add("query", query))
+			Expect(r.Error()).Should(BeNil())
+			return r
+		}
+		Expect(find(M{"age": M{"$between": []int{25, 65}}}).Len()).Should(Equal(3))
+		Expect(find(M{"lastname": M{"$like": "%o%"}}).Len()).Should(Equal(2))
+		Expect(find(M{"$or": []M{{"age": 25}, {"age": M{"$gt": 70}}}}).Len()).Should(Equal(2))
+		Expect(find(M{"$not": M{"name": "John"}}).Len()).Should(Equal(4))
+		Expect(find(M{"master": nil}).Len()).Should(Equal(4))
+		Expect(find(M{"master": M{"$ne": "none"}}).Len()).Should(Equal(2))
+
+		r := adapter.Find(payload.Empty().Add("query", M{"age": M{"$regex": "2"}}))
+		Expect(r.IsError()).Should(BeTrue())
+	})
+
+	It("FindStream() should read all records in batches", func() {
+		defer func(size int) { streamBatchSize = size }(streamBatchSize)
+		streamBatchSize = 2
+		stream, err := FindStream(context.Background(), adapter, payload.New(M{"sort": "age", "offset": 1, "limit": 4}))
+		Expect(err).Should(BeNil())
+		lastnames := []string{}
+		for record := range stream {
+			lastnames = append(lastnames, record.Get("lastname").String())
+		}
+		Expect(lastnames).Should(Equal([]string{"Man", "Snow", "Assange", "Travolta"}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err = FindStream(ctx, adapter, payload.New(M{"sort": "age"}))
+		Expect(err).Should(BeNil())
+		Expect((<-stream).Error()).Should(BeNil())
+		cancel()
+		Eventually(stream).Should(BeClosed())
+
+		count := 0
+		err = StreamEach(adapter, payload.Empty(), func(record moleculer.Payload) bool {
+			count++
+			return count < 3
+		})
+		Expect(err).Should(BeNil())
+		Expect(count).Should(Equal(3))
+
+		err = StreamEach(adapter, payload.New(M{"query": M{"age": M{"$regex": "1"}}}), func(record moleculer.Payload) bool {
+			return true
+		})
+		Expect(err).ShouldNot(BeNil())
+	})
+
 	It("FindById() should return one matching records by ID", func() {
 		r := adapter.FindById(johnSnow.Get("id"))
 		Expect(r.Error()).Should(BeNil())
@@ -104,4 +213,90 @@ var _ = Describe("MemoryAdapter", func() {
 		Expect(total.Int()).Should(Equal(0))
 	})
 
+	It("Commit() should persist the changes made in the transaction", func() {
+		tx, err := adapter.Begin()
+		Expect(err).Should(BeNil())
+		r := tx.Insert(payload.New(map[string]interface{}{
+			"name":     "Julio",
+			"lastname": "Cesar",
+		}))
+		Expect(r.Error()).Should(BeNil())
+		Expect(tx.RemoveById(johnSnow.Get("id")).Get("deletedCount").Int()).Should(Equal(1))
+		Expect(tx.Count(payload.Empty()).Int()).Should(Equal(6))
+		Expect(tx.Commit()).Should(Succeed())
+
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+		Expect(adapter.FindById(r.Get("id")).Get("name").String()).Should(Equal("Julio"))
+		Expect(adapter.FindById(johnSnow.Get("id")).Exists()).Should(BeFalse())
+	})
+
+	It("Rollback() should discard the changes made in the transaction", func() {
+		tx, err := adapter.Begin()
+		Expect(err).Should(BeNil())
+		Expect(tx.RemoveAll().Int()).Should(Equal(6))
+		Expect(tx.Count(payload.Empty()).Int()).Should(Equal(0))
+		Expect(tx.Rollback()).Should(Succeed())
+
+		Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+
+		tx, err = adapter.Begin()
+		Expect(err).Should(BeNil())
+		r := tx.UpdateMany(payload.New(map[string]interface{}{
+			"query":  map[string]interface{}{"name": "John"},
+			"update": map[string]interface{}{"age": 99},
+		}))
+		Expect(r.Get("modifiedCount").Int()).Should(Equal(2))
+		Expect(tx.Rollback()).Should(Succeed())
+
+		Expect(adapter.FindById(johnSnow.Get("id")).Get("age").Int()).Should(Equal(25))
+	})
+
+	It("InsertMany() should insert all records", func() {
+		r := adapter.InsertMany(payload.New([]map[string]interface{}{
+			{"name": "Julio", "lastname": "Cesar"},
+			{"name": "Julio", "lastname": "Iglesias"},
+		}))
+		Expect(r.Error()).Should(BeNil())
+		Expect(r.Len()).Should(Equal(2))
+		Expect(adapter.Count(payload.New(map[string]interface{}{
+			"searchFields": []string{"name"},
+			"search":       "Julio",
+		})).Int()).Should(Equal(2))
+	})
+
+	It("RunInTransaction() should rollback when the result is an error", func() {
+		r := RunInTransaction(adapter, func(tx Adapter) moleculer.Payload {
+			tx.RemoveById(johnSnow.Get("id"))
+			return payload.Error("abort!")
+		})
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(Equal("abort!"))
+		Expect(adapter.FindById(johnSnow.Get("id")).Exists()).Should(BeTrue())
+
+		r = RunInTransaction(adapter, func(tx Adapter) moleculer.Payload {
+			return tx.RemoveById(johnSnow.Get("id"))
+		})
+		Expect(r.Error()).Should(BeNil())
+		Expect(adapter.FindById(johnSnow.Get("id")).Exists()).Should(BeFalse())
+	})
+
+	It("should use the idField setting as the record id", func() {
+		custom := &MemoryAdapter{Table: "user"}
+		custom.Init(nil, map[string]interface{}{"idField": "_id"})
+		Expect(custom.Connect()).Should(Succeed())
+		defer custom.Disconnect()
+		r := custom.Insert(payload.New(map[string]interface{}{"name": "Arya"}))
+		Expect(r.Get("id").Exists()).Should(BeFalse())
+		id := r.Get("_id")
+		Expect(custom.FindById(id).Get("name").String()).Should(Equal("Arya"))
+
+		r = custom.UpdateById(id, payload.New(map[string]interface{}{"lastname": "Stark"}))
+		Expect(r.Error()).Should(BeNil())
+		Expect(custom.FindById(id).Get("lastname").String()).Should(Equal("Stark"))
+
+		r = custom.RemoveById(id)
+		Expect(r.Get("deletedCount").Int()).Should(Equal(1))
+		Expect(custom.Count(payload.Empty()).Int()).Should(Equal(0))
+	})
+
 })
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// Search modes of the MemoryAdapter. The search is case-insensitive in all modes.
+const (
+	// SearchContains matches the records where a search field contains the search text. Default.
+	SearchContains = "contains"
+	// SearchPrefix matches the records where a search field starts with the search text.
+	SearchPrefix = "prefix"
+	// SearchWords matches the records where each word of the search text is the start of a word of the
+	// search fields, in any order, like a full-text search. The words are kept in an inverted index.
+	SearchWords = "words"
+)
+
+// wordsIndex name of the memdb index of the words of the SearchFields.
+const wordsIndex = "words"
+
+// searchWords return the lower case words of the text.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// fieldTexts return the lower case texts of the field of the record, the items of a list one by one.
+func fieldTexts(record moleculer.Payload, field string) []string {
+	value := record.Get(field)
+	if !value.Exists() {
+		return nil
+	}
+	if value.IsArray() {
+		texts := []string{}
+		for _, item := range value.Array() {
+			texts = append(texts, strings.ToLower(item.String()))
+		}
+		return texts
+	}
+	return []string{strings.ToLower(value.String())}
+}
+
+// searchFieldsOf return the fields to search: the searchFields param, the SearchFields
+// or, when both are empty, all the fields of the record.
+func (adapter *MemoryAdapter) searchFieldsOf(params, record moleculer.Payload) []string {
+	if params.Get("searchFields").Exists() {
+		return sortEntries(params.Get("searchFields"))
+	}
+	if len(adapter.SearchFields) > 0 {
+		return adapter.SearchFields
+	}
+	fields := []string{}
+	for field := range record.RawMap() {
+		if field != "all" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// searchMatch return true when the record matches the search text in one of the search fields.
+func (adapter *MemoryAdapter) searchMatch(params, record moleculer.Payload, search string) bool {
+	fields := adapter.searchFieldsOf(params, record)
+	if adapter.SearchMode == SearchWords {
+		words := []string{}
+		for _, field := range fields {
+			for _, text := range fieldTexts(record, field) {
+				words = append(words, searchWords(text)...)
+			}
+		}
+		for _, term := range searchWords(search) {
+			found := false
+			for _, word := range words {
+				if strings.HasPrefix(word, term) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	search = strings.ToLower(search)
+	for _, field := range fields {
+		for _, text := range fieldTexts(record, field) {
+			if (adapter.SearchMode == SearchPrefix && strings.HasPrefix(text, search)) ||
+				(adapter.SearchMode != SearchPrefix && strings.Contains(text, search)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// searchCandidates return the memdb index and the args used to iterate the records that can match the search.
+// In the words mode the first word is looked up in the inverted index of the SearchFields,
+// otherwise all the records are checked.
+func (adapter *MemoryAdapter) searchCandidates(params moleculer.Payload, search string) (index string, args []interface{}) {
+	words := searchWords(search)
+	if adapter.SearchMode != SearchWords || len(adapter.SearchFields) == 0 || len(words) == 0 {
+		return "all", []interface{}{"*"}
+	}
+	for _, field := range sortEntries(params.Get("searchFields")) {
+		indexed := false
+		for _, searchField := range adapter.SearchFields {
+			indexed = indexed || field == searchField
+		}
+		if !indexed {
+			return "all", []interface{}{"*"}
+		}
+	}
+	return wordsIndex + "_prefix", []interface{}{words[0]}
+}
+
+// WordsIndex is a memdb index of the words of the fields, used by the SearchWords mode of the MemoryAdapter.
+// Each word is a key of the index, so the records with a word can be found by the prefix of the word.
+type WordsIndex struct {
+	Fields []string
+}
+
+func (s *WordsIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, errors.New("WordsIndex requires one argument.")
+	}
+	word, ok := args[0].(string)
+	if !ok {
+		return nil, errors.New("WordsIndex can only handle string arguments.")
+	}
+	return []byte(strings.ToLower(word) + "\x00"), nil
+}
+
+func (s *WordsIndex) PrefixFromArgs(args ...interface{}) ([]byte, error) {
+	key, err := s.FromArgs(args...)
+	if err != nil {
+		return nil, err
+	}
+	return key[:len(key)-1], nil
+}
+
+func (s *WordsIndex) FromObject(obj interface{}) (bool, [][]byte, error) {
+	p, isPayload := obj.(moleculer.Payload)
+	m, isMap := obj.(map[string]interface{})
+	if !isPayload && !isMap {
+		return false, nil, errors.New("Invalid type. It must be moleculer.Payload!")
+	}
+	if isMap {
+		p = payload.New(m)
+	}
+	keys := [][]byte{}
+	seen := map[string]bool{}
+	for _, field := range s.Fields {
+		for _, text := range fieldTexts(p, field) {
+			for _, word := range searchWords(text) {
+				if !seen[word] {
+					seen[word] = true
+					keys = append(keys, []byte(word+"\x00"))
+				}
+			}
+		}
+	}
+	return len(keys) > 0, keys, nil
+}
@@ -0,0 +1,257 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Index is an index of the collection, created on Connect when it does not exist.
+type Index struct {
+	// Name default: the MongoDB default name, like status_1_createdAt_-1
+	Name string
+	// Keys are the fields of the index, a field prefixed with - is in descending order.
+	Keys   []string
+	Unique bool
+	Sparse bool
+	// ExpireAfter removes the documents when the date in the key is older than it (TTL index of a single date field).
+	// Must be whole seconds.
+	ExpireAfter time.Duration
+	// PartialFilter indexes only the documents matching the filter, a MongoDB filter like {"status": {"$eq": "active"}}.
+	PartialFilter map[string]interface{}
+}
+
+// IndexDrift is a difference between the Indexes and the indexes of the collection, found on Connect.
+// An index with a different definition is not changed, it must be dropped to be created again.
+type IndexDrift struct {
+	Name    string
+	Problem string
+}
+
+func (drift IndexDrift) String() string {
+	return "index " + drift.Name + ": " + drift.Problem
+}
+
+// indexSpec is an index of the collection returned by listIndexes.
+type indexSpec struct {
+	Name                    string `bson:"name"`
+	Key                     bson.D `bson:"key"`
+	Unique                  bool   `bson:"unique"`
+	Sparse                  bool   `bson:"sparse"`
+	ExpireAfterSeconds      *int64 `bson:"expireAfterSeconds"`
+	PartialFilterExpression bson.M `bson:"partialFilterExpression"`
+}
+
+// indexKeys return the keys document of the index.
+func indexKeys(index Index) bson.D {
+	keys := bson.D{}
+	for _, key := range index.Keys {
+		if strings.HasPrefix(key, "-") {
+			keys = append(keys, bson.E{Key: strings.TrimPrefix(key, "-"), Value: -1})
+		} else {
+			keys = append(keys, bson.E{Key: key, Value: 1})
+		}
+	}
+	return keys
+}
+
+// indexName return the Name of the index or the MongoDB default name.
+func indexName(index Index) string {
+	if index.Name != "" {
+		return index.Name
+	}
+	parts := []string{}
+	for _, key := range indexKeys(index) {
+		parts = append(parts, fmt.Sprint(key.Key, "_", key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// validateIndexes checks the Indexes before creating them. A TTL index must have a single key and
+// ExpireAfter must be whole seconds, MongoDB expireAfterSeconds would truncate it (0 removes the documents at once).
+func validateIndexes(indexes []Index) error {
+	for _, index := range indexes {
+		if len(index.Keys) == 0 {
+			return errors.New(fmt.Sprint("index ", index.Name, " has no Keys"))
+		}
+		if index.ExpireAfter <= 0 {
+			continue
+		}
+		if len(index.Keys) != 1 {
+			return errors.New(fmt.Sprint("index ", indexName(index), ": ExpireAfter requires a single key, the TTL index can not be compound"))
+		}
+		if index.ExpireAfter%time.Second != 0 {
+			return errors.New(fmt.Sprint("index ", indexName(index), ": ExpireAfter ", index.ExpireAfter, " must be whole seconds"))
+		}
+	}
+	return nil
+}
+
+// indexModel return the model used to create the index.
+func indexModel(index Index) mongo.IndexModel {
+	opts := options.Index().SetName(indexName(index))
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+	if index.Sparse {
+		opts.SetSparse(true)
+	}
+	if index.ExpireAfter > 0 {
+		opts.SetExpireAfterSeconds(int32(index.ExpireAfter / time.Second))
+	}
+	if len(index.PartialFilter) > 0 {
+		opts.SetPartialFilterExpression(index.PartialFilter)
+	}
+	return mongo.IndexModel{Keys: indexKeys(index), Options: opts}
+}
+
+// canonical return a text of the value that is the same for equal documents, regardless of
+// the order of the map keys and of the numeric types.
+func canonical(value interface{}) string {
+	switch v := value.(type) {
+	case bson.M:
+		return canonical(map[string]interface{}(v))
+	case map[string]interface{}:
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		parts := []string{}
+		for _, key := range keys {
+			parts = append(parts, key+":"+canonical(v[key]))
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	case bson.D:
+		parts := []string{}
+		for _, e := range v {
+			parts = append(parts, e.Key+":"+canonical(e.Value))
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	case bson.A:
+		return canonical([]interface{}(v))
+	case []interface{}:
+		parts := []string{}
+		for _, item := range v {
+			parts = append(parts, canonical(item))
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	case int:
+		return fmt.Sprint(float64(v))
+	case int32:
+		return fmt.Sprint(float64(v))
+	case int64:
+		return fmt.Sprint(float64(v))
+	case float32:
+		return fmt.Sprint(float64(v))
+	}
+	return fmt.Sprintf("%#v", value)
+}
+
+// indexDrifts compares the declared indexes with the indexes of the collection.
+// returns the declared indexes that are missing and the differences. The indexes of the collection that are
+// not declared and not in managed (_id_, the text index) are also differences.
+func indexDrifts(declared []Index, existing []indexSpec, managed map[string]bool) (missing []Index, drifts []IndexDrift) {
+	byName := map[string]indexSpec{}
+	for _, spec := range existing {
+		byName[spec.Name] = spec
+	}
+	names := map[string]bool{}
+	for _, index := range declared {
+		name := indexName(index)
+		names[name] = true
+		spec, exists := byName[name]
+		if !exists {
+			missing = append(missing, index)
+			continue
+		}
+		problems := []string{}
+		if canonical(spec.Key) != canonical(indexKeys(index)) {
+			problems = append(problems, fmt.Sprint("keys are ", canonical(spec.Key), " instead of ", canonical(indexKeys(index))))
+		}
+		if spec.Unique != index.Unique {
+			problems = append(problems, fmt.Sprint("unique is ", spec.Unique, " instead of ", index.Unique))
+		}
+		if spec.Sparse != index.Sparse {
+			problems = append(problems, fmt.Sprint("sparse is ", spec.Sparse, " instead of ", index.Sparse))
+		}
+		expireAfter := int64(-1)
+		if spec.ExpireAfterSeconds != nil {
+			expireAfter = *spec.ExpireAfterSeconds
+		}
+		if (index.ExpireAfter > 0 && expireAfter != int64(index.ExpireAfter/time.Second)) || (index.ExpireAfter <= 0 && expireAfter >= 0) {
+			problems = append(problems, fmt.Sprint("expireAfterSeconds is ", expireAfter, " instead of ", int64(index.ExpireAfter/time.Second)))
+		}
+		if canonical(spec.PartialFilterExpression) != canonical(index.PartialFilter) &&
+			(len(spec.PartialFilterExpression) > 0 || len(index.PartialFilter) > 0) {
+			problems = append(problems, fmt.Sprint("partial filter is ", canonical(spec.PartialFilterExpression), " instead of ", canonical(index.PartialFilter)))
+		}
+		if len(problems) > 0 {
+			drifts = append(drifts, IndexDrift{Name: name, Problem: strings.Join(problems, ", ")})
+		}
+	}
+	for _, spec := range existing {
+		if !names[spec.Name] && !managed[spec.Name] {
+			drifts = append(drifts, IndexDrift{Name: spec.Name, Problem: "not declared in Indexes"})
+		}
+	}
+	return missing, drifts
+}
+
+// ensureIndexes creates the missing Indexes and logs the differences with the indexes of the collection.
+func (adapter *MongoAdapter) ensureIndexes(ctx context.Context) error {
+	adapter.indexDrifts = nil
+	if len(adapter.Indexes) == 0 {
+		return nil
+	}
+	if err := validateIndexes(adapter.Indexes); err != nil {
+		return err
+	}
+	cursor, err := adapter.coll.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	existing := []indexSpec{}
+	for cursor.Next(ctx) {
+		spec := indexSpec{}
+		if err := cursor.Decode(&spec); err != nil {
+			return err
+		}
+		existing = append(existing, spec)
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	managed := map[string]bool{"_id_": true}
+	if adapter.SearchMode == SearchText {
+		managed[adapter.textIndexName()] = true
+	}
+	missing, drifts := indexDrifts(adapter.Indexes, existing, managed)
+	for _, drift := range drifts {
+		adapter.logger.Warn("MongoAdapter collection ", adapter.Collection, " ", drift.String())
+	}
+	adapter.indexDrifts = drifts
+	if len(missing) == 0 {
+		return nil
+	}
+	models := []mongo.IndexModel{}
+	for _, index := range missing {
+		adapter.logger.Info("MongoAdapter creating index ", indexName(index), " of collection ", adapter.Collection)
+		models = append(models, indexModel(index))
+	}
+	_, err = adapter.coll.Indexes().CreateMany(ctx, models)
+	return err
+}
+
+// IndexDrifts return the differences between the Indexes and the indexes of the collection found on Connect.
+func (adapter *MongoAdapter) IndexDrifts() []IndexDrift {
+	return adapter.indexDrifts
+}
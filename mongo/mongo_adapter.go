@@ -2,13 +2,16 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/moleculer-go/moleculer"
 	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -23,15 +26,32 @@ type MongoAdapter struct {
 	Timeout    time.Duration
 	Database   string
 	Collection string
-	client     *mongo.Client
-	coll       *mongo.Collection
-	logger     *log.Entry
-	mutex      *sync.Mutex
+	// SearchFields are searched when the search param has no searchFields. In SearchText mode they are the
+	// fields of the text index and the searchFields param is not used.
+	SearchFields []string
+	// SearchMode is SearchEquals (default), SearchText or SearchRegex.
+	SearchMode string
+	// Indexes are created on Connect when they do not exist. See IndexDrifts.
+	Indexes     []Index
+	indexDrifts []IndexDrift
+	client      *mongo.Client
+	coll        *mongo.Collection
+	logger      *log.Entry
+	mutex       *sync.Mutex
+	idField     string
+	// session is set when the adapter is scoped to a transaction
+	session mongo.Session
+	// transactions is set on Connect when the server is a replica set or a sharded cluster
+	transactions bool
 }
 
 func (adapter *MongoAdapter) Init(logger *log.Entry, settings map[string]interface{}) {
 	adapter.logger = logger
 	adapter.mutex = &sync.Mutex{}
+	adapter.idField = "id"
+	if idField, ok := settings["idField"].(string); ok && idField != "" {
+		adapter.idField = idField
+	}
 }
 
 // Connect connect to mongo, stores the client and the collection.
@@ -53,6 +73,19 @@ func (adapter *MongoAdapter) Connect() error {
 		return err
 	}
 	adapter.coll = adapter.client.Database(adapter.Database).Collection(adapter.Collection)
+	adapter.transactions = adapter.supportsTransactions(ctx)
+	err = adapter.ensureTextIndex(ctx)
+	if err != nil {
+		adapter.logger.Error("MongoAdapter Connect() error creating the text index - error: ", err)
+		adapter.coll = nil
+		return err
+	}
+	err = adapter.ensureIndexes(ctx)
+	if err != nil {
+		adapter.logger.Error("MongoAdapter Connect() error creating the indexes - error: ", err)
+		adapter.coll = nil
+		return err
+	}
 	adapter.logger.Debug("MongoAdapter Connected !")
 	return nil
 }
@@ -118,20 +151,6 @@ func parseFindOptions(params moleculer.Payload) *options.FindOptions {
 	return &opts
 }
 
-func parseFindOneAndUpdateOptions(params moleculer.Payload) *options.FindOneAndUpdateOptions {
-	opts := options.FindOneAndUpdateOptions{}
-	sort := params.Get("sort")
-	if sort.Exists() {
-		if sort.IsArray() {
-			opts.Sort = sortsFromStringArray(sort)
-		} else {
-			opts.Sort = sortsFromString(sort)
-		}
-
-	}
-	return &opts
-}
-
 func sortEntry(entry string) primitive.E {
 	item := primitive.E{entry, 1}
 	if strings.Index(entry, "-") == 0 {
@@ -168,25 +187,160 @@ func sortsFromStringArray(sort moleculer.Payload) bson.D {
 	return sorts
 }
 
-func parseFilter(params moleculer.Payload) bson.M {
-	query := payload.Empty()
-	if params.Get("query").Exists() {
-		query = params.Get("query")
+// objectID converts an id to primitive.ObjectID. Values that are not an hex ObjectID are returned unchanged.
+func objectID(id interface{}) interface{} {
+	if hex, isString := id.(string); isString {
+		if objId, err := primitive.ObjectIDFromHex(hex); err == nil {
+			return objId
+		}
+	}
+	return id
+}
+
+// idFilter converts the value of the idField in a filter to ObjectIDs.
+// Supports a single id, a list of ids and operators like $in, $nin, $eq and $ne.
+func idFilter(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		list := []interface{}{}
+		for _, id := range v {
+			list = append(list, idFilter(id))
+		}
+		return list
+	case []string:
+		list := []interface{}{}
+		for _, id := range v {
+			list = append(list, objectID(id))
+		}
+		return list
+	case map[string]interface{}:
+		return idFilter(bson.M(v))
+	case bson.M:
+		filter := bson.M{}
+		for op, opValue := range v {
+			filter[op] = idFilter(opValue)
+		}
+		return filter
+	}
+	return objectID(value)
+}
+
+// parseFilter creates the mongo filter from the query and search params.
+// The idField is mapped to _id.
+func (adapter *MongoAdapter) parseFilter(params moleculer.Payload) (bson.M, error) {
+	filter, err := store.ParseQuery(params.Get("query"))
+	if err != nil {
+		return nil, err
+	}
+	query := adapter.filterToBson(filter)
+	search := adapter.searchFilter(params)
+	if len(search) == 0 {
+		return query, nil
+	}
+	if len(query) == 0 {
+		return search, nil
+	}
+	return bson.M{"$and": []interface{}{query, search}}, nil
+}
+
+// bulkFilter return the filter of UpdateMany and RemoveMany. A filter that matches all the records, like an
+// empty query or a search without search fields, is an error, so a bad request can not change the whole collection.
+func (adapter *MongoAdapter) bulkFilter(params moleculer.Payload) (bson.M, error) {
+	if params.Get("search").Exists() && len(adapter.searchFilter(params)) == 0 {
+		return nil, errors.New("The search requires searchFields or SearchFields to change many records.")
 	}
-	query = parseSearchFields(params, query)
-	return query.Bson()
+	filter, err := adapter.parseFilter(params)
+	if err == nil && len(filter) == 0 {
+		err = errors.New("A query or search filter is required to change many records.")
+	}
+	return filter, err
 }
 
-func (adapter *MongoAdapter) openCursor(params moleculer.Payload) (*mongo.Cursor, context.Context, error) {
+// comparisons maps the filter operators to the mongo ones.
+var comparisons = map[string]string{
+	store.OpEq:  "$eq",
+	store.OpGt:  "$gt",
+	store.OpGte: "$gte",
+	store.OpLt:  "$lt",
+	store.OpLte: "$lte",
+	store.OpIn:  "$in",
+}
+
+// filterToBson translate the query filter to a mongo filter.
+// ne and nin also exclude the records without the field (nil in $nin), so the results are the same as in the other adapters.
+func (adapter *MongoAdapter) filterToBson(filter store.Filter) bson.M {
+	switch filter.Op {
+	case store.OpAnd, store.OpOr:
+		list := []interface{}{}
+		for _, child := range filter.Filters {
+			list = append(list, adapter.filterToBson(child))
+		}
+		if filter.Op == store.OpAnd && len(list) == 0 {
+			return bson.M{}
+		}
+		if filter.Op == store.OpAnd && len(list) == 1 {
+			return list[0].(bson.M)
+		}
+		return bson.M{"$" + filter.Op: list}
+	case store.OpNot:
+		return bson.M{"$nor": []interface{}{adapter.filterToBson(store.Filter{Op: store.OpAnd, Filters: filter.Filters})}}
+	}
+	field := filter.Field
+	value := filter.Value
+	if field == adapter.idField {
+		field = "_id"
+		value = idFilter(value)
+	}
+	switch filter.Op {
+	case store.OpExists:
+		if filter.Value.(bool) {
+			return bson.M{field: bson.M{"$ne": nil}}
+		}
+		return bson.M{field: nil}
+	case store.OpBetween:
+		values := filter.Values()
+		if field == "_id" {
+			values = idFilter(values).([]interface{})
+		}
+		return bson.M{field: bson.M{"$gte": values[0], "$lte": values[1]}}
+	case store.OpLike:
+		return bson.M{field: bson.M{"$regex": store.LikePattern(filter.Value.(string)), "$options": "i"}}
+	case store.OpNe:
+		return bson.M{field: bson.M{"$nin": []interface{}{value, nil}}}
+	case store.OpNin:
+		values, _ := value.([]interface{})
+		return bson.M{field: bson.M{"$nin": append(append([]interface{}{}, values...), nil)}}
+	}
+	return bson.M{field: bson.M{comparisons[filter.Op]: value}}
+}
+
+// execute calls fn with a context limited by the adapter timeout.
+// When the adapter is scoped to a transaction the context carries the session.
+func (adapter *MongoAdapter) execute(fn func(ctx context.Context) moleculer.Payload) moleculer.Payload {
 	adapter.checkConnected()
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
-	filter := parseFilter(params)
-	opts := parseFindOptions(params)
-	cursor, err := adapter.coll.Find(ctx, filter, opts)
+	ctx, cancel := context.WithTimeout(context.Background(), adapter.Timeout)
+	defer cancel()
+	if adapter.session == nil {
+		return fn(ctx)
+	}
+	var result moleculer.Payload
+	err := mongo.WithSession(ctx, adapter.session, func(sctx mongo.SessionContext) error {
+		result = fn(sctx)
+		return nil
+	})
 	if err != nil {
-		return nil, nil, err
+		return payload.New(err)
 	}
-	return cursor, ctx, nil
+	return result
+}
+
+func (adapter *MongoAdapter) openCursor(ctx context.Context, params moleculer.Payload) (*mongo.Cursor, error) {
+	filter, err := adapter.parseFilter(params)
+	if err != nil {
+		return nil, err
+	}
+	opts := adapter.findOptions(params)
+	return adapter.coll.Find(ctx, filter, opts)
 }
 
 // applyTransforms apply a list of transformations on the value param.
@@ -215,49 +369,121 @@ func cursorToPayload(ctx context.Context, cursor *mongo.Cursor, transform ...fun
 	return payload.New(list)
 }
 
-// idTransform transform id from primitive.ObjectID to string
-func idTransform(bm bson.M) bson.M {
-	_, hasId := bm["id"]
+// idTransform transform _id from primitive.ObjectID to string and moves it to the idField.
+func (adapter *MongoAdapter) idTransform(bm bson.M) bson.M {
+	_, hasId := bm[adapter.idField]
 	_id, has_Id := bm["_id"]
-	if has_Id && !hasId {
-		bm["id"] = _id.(primitive.ObjectID).Hex()
+	if has_Id && (!hasId || adapter.idField == "_id") {
+		if objId, isObjectID := _id.(primitive.ObjectID); isObjectID {
+			_id = objId.Hex()
+		}
 		delete(bm, "_id")
+		bm[adapter.idField] = _id
 	}
 	return bm
 }
 
+// FindAndUpdate updates all the records matching the params (query, search, sort, limit and offset)
+// and returns them after the update, in the order they matched.
 func (adapter *MongoAdapter) FindAndUpdate(param moleculer.Payload) moleculer.Payload {
 	update := param.Get("update")
 	param = param.Remove("update")
-
-	adapter.checkConnected()
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
-	filter := parseFilter(param)
-	opts := parseFindOneAndUpdateOptions(param)
-
 	updateValues := payload.Empty().Add("$set", update).Bson()
-	r := adapter.coll.FindOneAndUpdate(ctx, filter, updateValues, opts)
-	var item bson.M
-	err := r.Decode(&item)
-	if err != nil {
-		return payload.New(err)
-	}
-	transformed := applyTransforms(item, idTransform)
-
-	if err := r.Err(); err != nil {
-		return payload.New(err)
-	}
-	return payload.New(transformed)
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		cursor, err := adapter.openCursor(ctx, param)
+		if err != nil {
+			return payload.New(err)
+		}
+		ids := []interface{}{}
+		for cursor.Next(ctx) {
+			var item bson.M
+			if err := cursor.Decode(&item); err != nil {
+				cursor.Close(ctx)
+				return payload.New(err)
+			}
+			ids = append(ids, item["_id"])
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return payload.New(err)
+		}
+		if len(ids) == 0 {
+			return payload.EmptyList()
+		}
+		filter := bson.M{"_id": bson.M{"$in": ids}}
+		if _, err := adapter.coll.UpdateMany(ctx, filter, updateValues); err != nil {
+			return payload.Error("Cannot update records - error: ", err)
+		}
+		cursor, err = adapter.coll.Find(ctx, filter)
+		if err != nil {
+			return payload.New(err)
+		}
+		defer cursor.Close(ctx)
+		updated := map[interface{}]bson.M{}
+		for cursor.Next(ctx) {
+			var item bson.M
+			if err := cursor.Decode(&item); err != nil {
+				return payload.New(err)
+			}
+			updated[item["_id"]] = item
+		}
+		if err := cursor.Err(); err != nil {
+			return payload.New(err)
+		}
+		list := []moleculer.Payload{}
+		for _, id := range ids {
+			if item, ok := updated[id]; ok {
+				list = append(list, payload.New(adapter.idTransform(item)))
+			}
+		}
+		return payload.New(list)
+	})
 }
 
 // Find search the data store with the params provided.
 func (adapter *MongoAdapter) Find(params moleculer.Payload) moleculer.Payload {
-	cursor, ctx, err := adapter.openCursor(params)
-	if err != nil {
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		cursor, err := adapter.openCursor(ctx, params)
+		if err != nil {
+			return payload.New(err)
+		}
+		defer cursor.Close(ctx)
+		return cursorToPayload(ctx, cursor, withoutTextScore, adapter.idTransform)
+	})
+}
+
+// FindStream implements store.StreamingAdapter. The records are decoded from the cursor as the channel is consumed.
+// The adapter Timeout applies to opening the cursor, not to the whole iteration. The cursor is closed when ctx is done.
+func (adapter *MongoAdapter) FindStream(ctx context.Context, params moleculer.Payload) (<-chan moleculer.Payload, error) {
+	var cursor *mongo.Cursor
+	opened := adapter.execute(func(ctx context.Context) moleculer.Payload {
+		var err error
+		cursor, err = adapter.openCursor(ctx, params)
 		return payload.New(err)
+	})
+	if opened.IsError() {
+		return nil, opened.Error()
 	}
-	defer cursor.Close(ctx)
-	return cursorToPayload(ctx, cursor, idTransform)
+	stream := make(chan moleculer.Payload)
+	go func() {
+		defer close(stream)
+		defer cursor.Close(context.Background())
+		for cursor.Next(ctx) {
+			var item bson.M
+			if err := cursor.Decode(&item); err != nil {
+				store.SendRecord(ctx, stream, payload.New(err))
+				return
+			}
+			if !store.SendRecord(ctx, stream, payload.New(applyTransforms(item, withoutTextScore, adapter.idTransform))) {
+				return
+			}
+		}
+		if err := cursor.Err(); err != nil && ctx.Err() == nil {
+			stream <- payload.New(err)
+		}
+	}()
+	return stream, nil
 }
 
 func (adapter *MongoAdapter) FindOne(params moleculer.Payload) moleculer.Payload {
@@ -290,33 +516,255 @@ func (adapter *MongoAdapter) FindByIds(params moleculer.Payload) moleculer.Paylo
 
 // Count count the number of records for the given filter.
 func (adapter *MongoAdapter) Count(params moleculer.Payload) moleculer.Payload {
-	adapter.checkConnected()
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
-	filter := parseFilter(params)
-	count, err := adapter.coll.CountDocuments(ctx, filter)
+	filter, err := adapter.parseFilter(params)
 	if err != nil {
 		return payload.New(err)
 	}
-	return payload.New(count)
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		count, err := adapter.coll.CountDocuments(ctx, filter)
+		if err != nil {
+			return payload.New(err)
+		}
+		return payload.New(count)
+	})
+}
+
+// fieldPath return the document path of a field, the idField is stored in _id.
+func (adapter *MongoAdapter) fieldPath(field string) string {
+	if field == adapter.idField {
+		return "$_id"
+	}
+	return "$" + field
+}
+
+// metricExpression create the $group accumulator of a metric.
+func (adapter *MongoAdapter) metricExpression(metric store.Metric) bson.M {
+	if metric.Field == "" {
+		return bson.M{"$sum": 1}
+	}
+	path := adapter.fieldPath(metric.Field)
+	if metric.Func == store.AggCount {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{path, nil}}, 1, 0}}}
+	}
+	return bson.M{"$" + metric.Func: path}
+}
+
+// aggregatePipeline create the $match, $group and $sort stages of the aggregation.
+func (adapter *MongoAdapter) aggregatePipeline(filter bson.M, aggregation store.Aggregation) mongo.Pipeline {
+	var groupID interface{}
+	sort := bson.D{}
+	if len(aggregation.GroupBy) > 0 {
+		keys := bson.M{}
+		for _, field := range aggregation.GroupBy {
+			keys[field] = adapter.fieldPath(field)
+			sort = append(sort, bson.E{Key: "_id." + field, Value: 1})
+		}
+		groupID = keys
+	}
+	group := bson.D{{Key: "_id", Value: groupID}}
+	for _, metric := range aggregation.Metrics {
+		group = append(group, bson.E{Key: metric.Name, Value: adapter.metricExpression(metric)})
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: group}},
+	}
+	if len(sort) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: sort}})
+	}
+	return pipeline
+}
+
+// groupTransform moves the group by values from _id to the record and returns sum and avg as float64.
+func groupTransform(aggregation store.Aggregation) func(bson.M) bson.M {
+	return func(bm bson.M) bson.M {
+		keys, _ := bm["_id"].(bson.M)
+		delete(bm, "_id")
+		for _, field := range aggregation.GroupBy {
+			value := keys[field]
+			if objId, isObjectID := value.(primitive.ObjectID); isObjectID {
+				value = objId.Hex()
+			}
+			bm[field] = value
+		}
+		for _, metric := range aggregation.Metrics {
+			switch value := bm[metric.Name].(type) {
+			case int32:
+				if metric.Func != store.AggCount {
+					bm[metric.Name] = float64(value)
+				}
+			case int64:
+				if metric.Func != store.AggCount {
+					bm[metric.Name] = float64(value)
+				}
+			}
+		}
+		return bm
+	}
+}
+
+// emptyAggregation is the result of an aggregation without group by when no record matches,
+// mongo returns no groups but a single record is expected.
+func emptyAggregation(aggregation store.Aggregation) moleculer.Payload {
+	row := map[string]interface{}{}
+	for _, metric := range aggregation.Metrics {
+		switch metric.Func {
+		case store.AggCount:
+			row[metric.Name] = 0
+		case store.AggSum:
+			row[metric.Name] = 0.0
+		default:
+			row[metric.Name] = nil
+		}
+	}
+	return payload.New([]moleculer.Payload{payload.New(row)})
+}
+
+// Aggregate implements store.AggregateAdapter with a $group pipeline.
+func (adapter *MongoAdapter) Aggregate(params moleculer.Payload, aggregation store.Aggregation) moleculer.Payload {
+	filter, err := adapter.parseFilter(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		cursor, err := adapter.coll.Aggregate(ctx, adapter.aggregatePipeline(filter, aggregation))
+		if err != nil {
+			return payload.New(err)
+		}
+		defer cursor.Close(ctx)
+		result := cursorToPayload(ctx, cursor, groupTransform(aggregation))
+		if !result.IsError() && result.Len() == 0 && len(aggregation.GroupBy) == 0 {
+			return emptyAggregation(aggregation)
+		}
+		return result
+	})
+}
+
+// distinctValues removes the null values of the Distinct result, converts the ObjectIDs to string and sorts it.
+func distinctValues(values []interface{}) []interface{} {
+	list := []interface{}{}
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		if objId, isObjectID := value.(primitive.ObjectID); isObjectID {
+			value = objId.Hex()
+		}
+		list = append(list, value)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return store.CompareValues(list[i], list[j]) < 0
+	})
+	return list
+}
+
+// Distinct implements store.DistinctAdapter with the collection Distinct.
+// The values of an array field are returned one by one.
+func (adapter *MongoAdapter) Distinct(params moleculer.Payload, field string) moleculer.Payload {
+	filter, err := adapter.parseFilter(params)
+	if err != nil {
+		return payload.New(err)
+	}
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		values, err := adapter.coll.Distinct(ctx, strings.TrimPrefix(adapter.fieldPath(field), "$"), filter)
+		if err != nil {
+			return payload.New(err)
+		}
+		return payload.New(distinctValues(values))
+	})
 }
 
 func (adapter *MongoAdapter) Insert(params moleculer.Payload) moleculer.Payload {
-	adapter.checkConnected()
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
 	values := params.Bson()
-	res, err := adapter.coll.InsertOne(ctx, values)
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		res, err := adapter.coll.InsertOne(ctx, values)
+		if err != nil {
+			return payload.Error("Error while trying to insert record. Error: ", err.Error())
+		}
+		return params.Add(adapter.idField, res.InsertedID.(primitive.ObjectID).Hex())
+	})
+}
+
+// InsertMany inserts all records of the list with a single InsertMany command.
+func (adapter *MongoAdapter) InsertMany(params moleculer.Payload) moleculer.Payload {
+	if !params.IsArray() {
+		return payload.Error("InsertMany() only support lists!")
+	}
+	records := params.Array()
+	if len(records) == 0 {
+		return payload.EmptyList()
+	}
+	documents := make([]interface{}, len(records))
+	for i, record := range records {
+		documents[i] = record.Bson()
+	}
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		res, err := adapter.coll.InsertMany(ctx, documents)
+		if err != nil {
+			return payload.Error("Error while trying to insert records. Error: ", err.Error())
+		}
+		list := make([]moleculer.Payload, len(records))
+		for i, record := range records {
+			list[i] = record.Add(adapter.idField, res.InsertedIDs[i].(primitive.ObjectID).Hex())
+		}
+		return payload.New(list)
+	})
+}
+
+// UpdateMany applies params.update to all records matching the params.
+func (adapter *MongoAdapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	update := params.Get("update")
+	if !update.Exists() || !update.IsMap() {
+		return payload.Error("UpdateMany() requires the update param!")
+	}
+	filter, err := adapter.bulkFilter(params.Remove("update"))
 	if err != nil {
-		return payload.Error("Error while trying to insert record. Error: ", err.Error())
+		return payload.New(err)
 	}
-	return params.Add("id", res.InsertedID.(primitive.ObjectID).Hex())
+	values := payload.Empty().Add("$set", update).Bson()
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		ur, err := adapter.coll.UpdateMany(ctx, filter, values)
+		if err != nil {
+			return payload.Error("Cannot update records - error: ", err)
+		}
+		return payload.Empty().Add("modifiedCount", ur.ModifiedCount).Add("matchedCount", ur.MatchedCount)
+	})
+}
+
+// RemoveMany removes the records with params.ids or matching the params.
+func (adapter *MongoAdapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	var filter bson.M
+	if params.Get("ids").Exists() {
+		objIds := []primitive.ObjectID{}
+		for _, id := range params.Get("ids").StringArray() {
+			objId, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				return payload.Error("Invalid id error: ", err)
+			}
+			objIds = append(objIds, objId)
+		}
+		filter = bson.M{"_id": bson.M{"$in": objIds}}
+	} else {
+		var err error
+		if filter, err = adapter.bulkFilter(params); err != nil {
+			return payload.New(err)
+		}
+	}
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		dr, err := adapter.coll.DeleteMany(ctx, filter)
+		if err != nil {
+			return payload.Error("Cannot remove records - error: ", err)
+		}
+		return payload.Empty().Add("deletedCount", dr.DeletedCount)
+	})
 }
 
 func (adapter *MongoAdapter) Update(params moleculer.Payload) moleculer.Payload {
-	id := params.Get("id")
+	id := params.Get(adapter.idField)
 	if !id.Exists() {
 		return payload.Error("Cannot update record without id")
 	}
-	return adapter.UpdateById(id, params.Remove("id"))
+	return adapter.UpdateById(id, params.Remove(adapter.idField))
 }
 
 func (adapter *MongoAdapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
@@ -325,13 +773,38 @@ func (adapter *MongoAdapter) UpdateById(id, update moleculer.Payload) moleculer.
 	if err != nil {
 		return payload.Error("Cannot update record without id - error: ", err)
 	}
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
 	values := payload.Empty().Add("$set", update).Bson()
-	ur, uerr := adapter.coll.UpdateOne(ctx, bson.M{"_id": objId}, values)
-	if uerr != nil {
-		return payload.Error("Cannot update record - error: ", uerr)
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		ur, uerr := adapter.coll.UpdateOne(ctx, bson.M{"_id": objId}, values)
+		if uerr != nil {
+			return payload.Error("Cannot update record - error: ", uerr)
+		}
+		return payload.Empty().Add("modifiedCount", ur.ModifiedCount).Add("matchedCount", ur.MatchedCount)
+	})
+}
+
+// UpdateByIdVersion applies the update with FindOneAndUpdate filtering by _id and versionField.
+// returns payload.New(nil) when no record matched.
+func (adapter *MongoAdapter) UpdateByIdVersion(id moleculer.Payload, versionField string, version int64, update moleculer.Payload) moleculer.Payload {
+	adapter.checkConnected()
+	objId, err := primitive.ObjectIDFromHex(id.String())
+	if err != nil {
+		return payload.Error("Cannot update record without id - error: ", err)
 	}
-	return payload.Empty().Add("modifiedCount", ur.ModifiedCount).Add("matchedCount", ur.MatchedCount)
+	filter := bson.M{"_id": objId, versionField: version}
+	values := payload.Empty().Add("$set", update).Bson()
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		sr := adapter.coll.FindOneAndUpdate(ctx, filter, values, opts)
+		if sr.Err() == mongo.ErrNoDocuments {
+			return payload.New(nil)
+		}
+		var item bson.M
+		if err := sr.Decode(&item); err != nil {
+			return payload.Error("Cannot update record - error: ", err)
+		}
+		return payload.New(adapter.idTransform(item))
+	})
 }
 
 func (adapter *MongoAdapter) RemoveById(id moleculer.Payload) moleculer.Payload {
@@ -340,20 +813,82 @@ func (adapter *MongoAdapter) RemoveById(id moleculer.Payload) moleculer.Payload
 	if err != nil {
 		return payload.Error("Cannot update record without id - error: ", err)
 	}
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
-	dr, uerr := adapter.coll.DeleteOne(ctx, bson.M{"_id": objId})
-	if uerr != nil {
-		return payload.Error("Cannot update record - error: ", uerr)
-	}
-	return payload.Empty().Add("deletedCount", dr.DeletedCount)
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		dr, uerr := adapter.coll.DeleteOne(ctx, bson.M{"_id": objId})
+		if uerr != nil {
+			return payload.Error("Cannot update record - error: ", uerr)
+		}
+		return payload.Empty().Add("deletedCount", dr.DeletedCount)
+	})
 }
 
 func (adapter *MongoAdapter) RemoveAll() moleculer.Payload {
+	return adapter.execute(func(ctx context.Context) moleculer.Payload {
+		res, err := adapter.coll.DeleteMany(ctx, bson.M{})
+		if err != nil {
+			return payload.Error("Error while trying to remove all records. Error: ", err.Error())
+		}
+		return payload.Empty().Add("deletedCount", res.DeletedCount)
+	})
+}
+
+// transaction is a MongoAdapter scoped to a session with an open transaction.
+type transaction struct {
+	*MongoAdapter
+}
+
+// end commits or aborts the transaction and ends the session.
+func (t *transaction) end(commit bool) error {
+	if t.session == nil {
+		return errors.New("Transaction already finished!")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+	defer cancel()
+	session := t.session
+	t.session = nil
+	defer session.EndSession(ctx)
+	if commit {
+		return session.CommitTransaction(ctx)
+	}
+	return session.AbortTransaction(ctx)
+}
+
+func (t *transaction) Commit() error {
+	return t.end(true)
+}
+
+func (t *transaction) Rollback() error {
+	return t.end(false)
+}
+
+// supportsTransactions checks if the server is a replica set member or a mongos,
+// standalone servers do not support transactions.
+func (adapter *MongoAdapter) supportsTransactions(ctx context.Context) bool {
+	var result bson.M
+	if err := adapter.client.Database("admin").RunCommand(ctx, bson.M{"isMaster": 1}).Decode(&result); err != nil {
+		adapter.logger.Warn("MongoAdapter could not check the server type - error: ", err)
+		return false
+	}
+	_, replicaSet := result["setName"]
+	return replicaSet || result["msg"] == "isdbgrid"
+}
+
+// Begin starts a session and a transaction on it.
+// Mongo only supports transactions on replica sets (version 4.0+) and sharded clusters (version 4.2+).
+func (adapter *MongoAdapter) Begin() (store.Transaction, error) {
 	adapter.checkConnected()
-	ctx, _ := context.WithTimeout(context.Background(), adapter.Timeout)
-	res, err := adapter.coll.DeleteMany(ctx, bson.M{})
+	if !adapter.transactions {
+		return nil, errors.New("MongoAdapter transactions require a replica set or a sharded cluster")
+	}
+	session, err := adapter.client.StartSession()
 	if err != nil {
-		return payload.Error("Error while trying to remove all records. Error: ", err.Error())
+		return nil, err
+	}
+	if err = session.StartTransaction(); err != nil {
+		session.EndSession(context.Background())
+		return nil, err
 	}
-	return payload.Empty().Add("deletedCount", res.DeletedCount)
+	scoped := *adapter
+	scoped.session = session
+	return &transaction{&scoped}, nil
 }
@@ -8,10 +8,14 @@ import (
 	"github.com/moleculer-go/cupaloy/v2"
 	"github.com/moleculer-go/moleculer"
 	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
 	"github.com/moleculer-go/store/mocks"
+	"github.com/moleculer-go/store/storetest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var snap = cupaloy.New(cupaloy.FailOnUpdate(os.Getenv("UPDATE_SNAPSHOTS") == "true"))
@@ -40,6 +44,10 @@ func mongoAdapter(database, collection string) *MongoAdapter {
 
 type M map[string]interface{}
 
+var _ store.StreamingAdapter = &MongoAdapter{}
+var _ store.AggregateAdapter = &MongoAdapter{}
+var _ store.DistinctAdapter = &MongoAdapter{}
+
 var _ = Describe("Mongo Adapter", func() {
 	adapter := mongoAdapter("mongo_adapter_tests", "user")
 	totalRecords := 6
@@ -303,3 +311,169 @@ var _ = Describe("Mongo Adapter", func() {
 	})
 
 })
+
+var _ = storetest.Suite("Mongo Adapter", func() store.Adapter {
+	return &MongoAdapter{
+		MongoURL:   mongoTestsHost,
+		Timeout:    2 * time.Second,
+		Database:   "mongo_adapter_tests",
+		Collection: "store_suite",
+	}
+})
+
+var _ = Describe("Mongo query translation", func() {
+	adapter := &MongoAdapter{}
+	adapter.Init(log.WithField("test", "adapter"), M{})
+
+	toBson := func(query M) bson.M {
+		filter, err := store.ParseQuery(payload.New(query))
+		Expect(err).Should(BeNil())
+		return adapter.filterToBson(filter)
+	}
+
+	It("should translate the comparison operators", func() {
+		Expect(toBson(M{"name": "John"})).Should(Equal(bson.M{"name": bson.M{"$eq": "John"}}))
+		Expect(toBson(M{"age": M{"$gt": 60}})).Should(Equal(bson.M{"age": bson.M{"$gt": 60}}))
+		Expect(toBson(M{"age": M{"between": []int{13, 25}}})).Should(Equal(bson.M{"age": bson.M{"$gte": 13, "$lte": 25}}))
+		Expect(toBson(M{"age": M{"$ne": 13}})).Should(Equal(bson.M{"age": bson.M{"$nin": []interface{}{13, nil}}}))
+		Expect(toBson(M{"deletedAt": nil})).Should(Equal(bson.M{"deletedAt": nil}))
+		Expect(toBson(M{"email": M{"$like": "%@ufc.com"}})).Should(Equal(bson.M{"email": bson.M{"$regex": `^.*@ufc\.com$`, "$options": "i"}}))
+	})
+
+	It("should translate the logical operators and the idField", func() {
+		id := "5d8b6fd4e4a8fbb3fe8e0b5a"
+		objId, _ := primitive.ObjectIDFromHex(id)
+		Expect(toBson(M{"id": id, "$or": []M{M{"name": "John"}, M{"$not": M{"age": 13}}}})).Should(Equal(bson.M{
+			"$and": []interface{}{
+				bson.M{"$or": []interface{}{
+					bson.M{"name": bson.M{"$eq": "John"}},
+					bson.M{"$nor": []interface{}{bson.M{"age": bson.M{"$eq": 13}}}},
+				}},
+				bson.M{"_id": bson.M{"$eq": objId}},
+			},
+		}))
+	})
+
+	It("should translate the aggregation to a $group pipeline", func() {
+		aggregation, err := store.ParseAggregation(payload.New(M{
+			"groupBy": []string{"status"},
+			"metrics": M{"total": M{"sum": "amount"}, "withEmail": M{"count": "email"}},
+		}))
+		Expect(err).Should(BeNil())
+		pipeline := adapter.aggregatePipeline(bson.M{}, aggregation)
+		Expect(len(pipeline)).Should(Equal(3))
+		Expect(pipeline[1][0].Value).Should(Equal(bson.D{
+			{Key: "_id", Value: bson.M{"status": "$status"}},
+			{Key: "total", Value: bson.M{"$sum": "$amount"}},
+			{Key: "withEmail", Value: bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$email", nil}}, 1, 0}}}},
+		}))
+		Expect(pipeline[2][0].Value).Should(Equal(bson.D{{Key: "_id.status", Value: 1}}))
+
+		row := groupTransform(aggregation)(bson.M{"_id": bson.M{"status": "open"}, "total": int32(10), "withEmail": int32(2)})
+		Expect(row).Should(Equal(bson.M{"status": "open", "total": 10.0, "withEmail": int32(2)}))
+	})
+
+	It("should translate the search according to the SearchMode", func() {
+		search := func(mode string, params M) bson.M {
+			searchAdapter := &MongoAdapter{SearchFields: []string{"name", "lastname"}, SearchMode: mode}
+			searchAdapter.Init(log.WithField("test", "adapter"), M{})
+			return searchAdapter.searchFilter(payload.New(params))
+		}
+		Expect(fmt.Sprint(search("", M{"search": "John"}))).Should(Equal(fmt.Sprint(bson.M{"$or": []interface{}{bson.M{"name": "John"}, bson.M{"lastname": "John"}}})))
+		Expect(search(SearchEquals, M{"search": "John", "searchFields": []string{"name"}})).Should(Equal(bson.M{"name": "John"}))
+
+		Expect(search(SearchRegex, M{"search": "jo.n", "searchFields": "name"})).Should(Equal(bson.M{"name": bson.M{"$regex": `jo\.n`, "$options": "i"}}))
+		Expect(search(SearchRegex, M{"search": "sno"})).Should(Equal(bson.M{"$or": []interface{}{
+			bson.M{"name": bson.M{"$regex": "sno", "$options": "i"}},
+			bson.M{"lastname": bson.M{"$regex": "sno", "$options": "i"}},
+		}}))
+		Expect(search(SearchRegex, M{"search": ""})).Should(BeEmpty())
+
+		Expect(search(SearchText, M{"search": `john "snow"`, "searchFields": "name"})).Should(Equal(bson.M{"$text": bson.M{"$search": `john "snow"`}}))
+		Expect(search(SearchText, M{"search": " "})).Should(BeEmpty())
+	})
+
+	It("should reject the filters of UpdateMany and RemoveMany that match all the records", func() {
+		_, err := adapter.bulkFilter(payload.New(M{"query": M{}}))
+		Expect(err).ShouldNot(BeNil())
+		_, err = adapter.bulkFilter(payload.New(M{"search": "John"}))
+		Expect(err).ShouldNot(BeNil())
+		filter, err := adapter.bulkFilter(payload.New(M{"search": "John", "searchFields": []string{"name"}}))
+		Expect(err).Should(BeNil())
+		Expect(filter).Should(Equal(bson.M{"name": "John"}))
+		regexAdapter := &MongoAdapter{SearchMode: SearchRegex}
+		regexAdapter.Init(log.WithField("test", "adapter"), M{})
+		_, err = regexAdapter.bulkFilter(payload.New(M{"search": "John"}))
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	It("should sort by the text score in SearchText mode without sort", func() {
+		textAdapter := &MongoAdapter{SearchFields: []string{"name"}, SearchMode: SearchText}
+		textAdapter.Init(log.WithField("test", "adapter"), M{})
+		opts := textAdapter.findOptions(payload.New(M{"search": "john"}))
+		Expect(opts.Sort).Should(Equal(bson.D{{Key: "_textScore", Value: bson.M{"$meta": "textScore"}}}))
+		//MongoDB before 4.4 requires the projection of the sorted text score
+		Expect(opts.Projection).Should(Equal(bson.M{"_textScore": bson.M{"$meta": "textScore"}}))
+		Expect(withoutTextScore(bson.M{"name": "John", "_textScore": 1.5})).Should(Equal(bson.M{"name": "John"}))
+		opts = textAdapter.findOptions(payload.New(M{"search": "john", "sort": "-age"}))
+		Expect(opts.Sort).Should(Equal(bson.D{{Key: "age", Value: -1}}))
+		Expect(opts.Projection).Should(BeNil())
+		Expect(textAdapter.findOptions(payload.New(M{})).Sort).Should(BeNil())
+	})
+
+	It("should sort the distinct values without null", func() {
+		objId, _ := primitive.ObjectIDFromHex("5d7b2e5b2d5a7f1c8c3e4b1a")
+		Expect(distinctValues([]interface{}{"open", nil, "closed", objId})).Should(Equal([]interface{}{
+			"5d7b2e5b2d5a7f1c8c3e4b1a", "closed", "open",
+		}))
+		Expect(distinctValues([]interface{}{int32(3), 1.5, int64(2)})).Should(Equal([]interface{}{1.5, int64(2), int32(3)}))
+	})
+
+	It("should name and create the indexes", func() {
+		Expect(indexName(Index{Keys: []string{"status", "-createdAt"}})).Should(Equal("status_1_createdAt_-1"))
+		Expect(indexName(Index{Name: "by_status", Keys: []string{"status"}})).Should(Equal("by_status"))
+
+		model := indexModel(Index{Keys: []string{"-createdAt"}, Sparse: true, ExpireAfter: 2 * time.Hour})
+		Expect(model.Keys).Should(Equal(bson.D{{Key: "createdAt", Value: -1}}))
+		opts := model.Options
+		Expect(*opts.Name).Should(Equal("createdAt_-1"))
+		Expect(*opts.Sparse).Should(BeTrue())
+		Expect(*opts.ExpireAfterSeconds).Should(Equal(int32(7200)))
+		Expect(opts.Unique).Should(BeNil())
+	})
+
+	It("should validate the TTL indexes", func() {
+		Expect(validateIndexes([]Index{{Keys: []string{"createdAt"}, ExpireAfter: time.Hour}, {Keys: []string{"a", "b"}}})).Should(Succeed())
+		Expect(validateIndexes([]Index{{Keys: []string{"createdAt"}, ExpireAfter: 500 * time.Millisecond}})).ShouldNot(Succeed())
+		Expect(validateIndexes([]Index{{Keys: []string{"createdAt"}, ExpireAfter: 1500 * time.Millisecond}})).ShouldNot(Succeed())
+		Expect(validateIndexes([]Index{{Keys: []string{"status", "createdAt"}, ExpireAfter: time.Hour}})).ShouldNot(Succeed())
+		Expect(validateIndexes([]Index{{Name: "empty"}})).ShouldNot(Succeed())
+	})
+
+	It("should find the missing indexes and the index drifts", func() {
+		declared := []Index{
+			{Keys: []string{"email"}, Unique: true},
+			{Keys: []string{"createdAt"}, ExpireAfter: time.Hour},
+			{Keys: []string{"status"}, PartialFilter: map[string]interface{}{"age": map[string]interface{}{"$gt": 18}}},
+			{Keys: []string{"name"}},
+		}
+		hour := int64(3600)
+		existing := []indexSpec{
+			{Name: "_id_", Key: bson.D{{Key: "_id", Value: int32(1)}}},
+			{Name: "email_1", Key: bson.D{{Key: "email", Value: int32(1)}}},
+			{Name: "createdAt_1", Key: bson.D{{Key: "createdAt", Value: int32(1)}}, ExpireAfterSeconds: &hour},
+			{Name: "status_1", Key: bson.D{{Key: "status", Value: 1.0}}, PartialFilterExpression: bson.M{"age": bson.M{"$gt": int32(18)}}},
+			{Name: "old_1", Key: bson.D{{Key: "old", Value: int32(1)}}},
+		}
+		missing, drifts := indexDrifts(declared, existing, map[string]bool{"_id_": true})
+		Expect(missing).Should(Equal([]Index{{Keys: []string{"name"}}}))
+		Expect(drifts).Should(Equal([]IndexDrift{
+			{Name: "email_1", Problem: "unique is false instead of true"},
+			{Name: "old_1", Problem: "not declared in Indexes"},
+		}))
+
+		existing[2].ExpireAfterSeconds = nil
+		_, drifts = indexDrifts(declared[1:2], existing[2:3], map[string]bool{})
+		Expect(drifts).Should(Equal([]IndexDrift{{Name: "createdAt_1", Problem: "expireAfterSeconds is -1 instead of 3600"}}))
+	})
+})
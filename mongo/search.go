@@ -0,0 +1,111 @@
+package mongo
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Search modes of the MongoAdapter, set in SearchMode.
+const (
+	// SearchEquals matches the records where a search field is equal to the search value. Default.
+	SearchEquals = "equals"
+	// SearchText uses the $text operator on a text index of the SearchFields, created on Connect.
+	// Without sort the records are sorted by the text score.
+	SearchText = "text"
+	// SearchRegex matches the records where a search field contains the search value, case-insensitive.
+	SearchRegex = "regex"
+)
+
+// textIndexName name of the text index created on Connect in SearchText mode.
+func (adapter *MongoAdapter) textIndexName() string {
+	return adapter.Collection + "_text"
+}
+
+// ensureTextIndex creates the text index of the SearchFields in SearchText mode.
+// A collection can have only one text index, so it fails when there is one with other fields.
+func (adapter *MongoAdapter) ensureTextIndex(ctx context.Context) error {
+	if adapter.SearchMode != SearchText || len(adapter.SearchFields) == 0 {
+		return nil
+	}
+	keys := bson.D{}
+	for _, field := range adapter.SearchFields {
+		keys = append(keys, bson.E{Key: field, Value: "text"})
+	}
+	_, err := adapter.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetName(adapter.textIndexName()),
+	})
+	return err
+}
+
+// searchFieldsOf return the fields of the searchFields param or the SearchFields.
+func (adapter *MongoAdapter) searchFieldsOf(params moleculer.Payload) []string {
+	searchFields := params.Get("searchFields")
+	if !searchFields.Exists() {
+		return adapter.SearchFields
+	}
+	if searchFields.IsArray() {
+		return searchFields.StringArray()
+	}
+	return []string{searchFields.String()}
+}
+
+// searchFilter creates the mongo filter of the search param according to the SearchMode.
+func (adapter *MongoAdapter) searchFilter(params moleculer.Payload) bson.M {
+	search := params.Get("search")
+	switch adapter.SearchMode {
+	case SearchText:
+		if !search.Exists() || strings.TrimSpace(search.String()) == "" {
+			return bson.M{}
+		}
+		return bson.M{"$text": bson.M{"$search": search.String()}}
+	case SearchRegex:
+		if !search.Exists() || search.String() == "" {
+			return bson.M{}
+		}
+		or := []interface{}{}
+		for _, field := range adapter.searchFieldsOf(params) {
+			or = append(or, bson.M{field: bson.M{"$regex": regexp.QuoteMeta(search.String()), "$options": "i"}})
+		}
+		if len(or) == 1 {
+			return or[0].(bson.M)
+		}
+		if len(or) == 0 {
+			return bson.M{}
+		}
+		return bson.M{"$or": or}
+	}
+	if !params.Get("searchFields").Exists() && len(adapter.SearchFields) > 0 {
+		params = payload.Empty().AddMany(params.RawMap()).Add("searchFields", adapter.SearchFields)
+	}
+	return parseSearchFields(params, payload.Empty()).Bson()
+}
+
+// textScoreField name of the projected text score. MongoDB before 4.4 requires the projection of the
+// $meta sort. It is removed from the results by withoutTextScore.
+const textScoreField = "_textScore"
+
+// findOptions return the find options of the params. In SearchText mode, without sort,
+// the records are sorted by the text score.
+func (adapter *MongoAdapter) findOptions(params moleculer.Payload) *options.FindOptions {
+	opts := parseFindOptions(params)
+	if opts.Sort == nil && adapter.SearchMode == SearchText && len(adapter.searchFilter(params)) > 0 {
+		textScore := bson.M{"$meta": "textScore"}
+		opts.Sort = bson.D{{Key: textScoreField, Value: textScore}}
+		opts.Projection = bson.M{textScoreField: textScore}
+	}
+	return opts
+}
+
+// withoutTextScore removes the projected text score from the record.
+func withoutTextScore(bm bson.M) bson.M {
+	delete(bm, textScoreField)
+	return bm
+}
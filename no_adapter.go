@@ -42,3 +42,12 @@ func (adapter *NotDefinedAdapter) UpdateById(params moleculer.Payload) moleculer
 func (adapter *NotDefinedAdapter) RemoveById(params moleculer.Payload) moleculer.Payload {
 	panic(msg)
 }
+func (adapter *NotDefinedAdapter) InsertMany(params moleculer.Payload) moleculer.Payload {
+	panic(msg)
+}
+func (adapter *NotDefinedAdapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	panic(msg)
+}
+func (adapter *NotDefinedAdapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	panic(msg)
+}
@@ -0,0 +1,355 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+)
+
+// Operators of the portable query language.
+const (
+	OpEq      = "eq"
+	OpNe      = "ne"
+	OpGt      = "gt"
+	OpGte     = "gte"
+	OpLt      = "lt"
+	OpLte     = "lte"
+	OpIn      = "in"
+	OpNin     = "nin"
+	OpBetween = "between"
+	OpLike    = "like"
+	OpExists  = "exists"
+	OpAnd     = "and"
+	OpOr      = "or"
+	OpNot     = "not"
+)
+
+// Filter is a node of the portable query parsed by ParseQuery and translated by each adapter.
+// Comparison nodes (eq, ne, gt, gte, lt, lte, like) have a Field and a Value,
+// in, nin and between have a list of values ([]interface{}) and exists a bool.
+// Logical nodes (and, or, not) have the child Filters, not has a single child.
+type Filter struct {
+	Op      string
+	Field   string
+	Value   interface{}
+	Filters []Filter
+}
+
+// operatorAliases maps the operators accepted in the query to the Filter operators.
+// The SQL style aliases are kept for compatibility with the SQLite adapter queries.
+var operatorAliases = map[string]string{
+	"$eq": OpEq, "=": OpEq, "==": OpEq,
+	"$ne": OpNe, "!=": OpNe, "<>": OpNe,
+	"$gt": OpGt, ">": OpGt,
+	"$gte": OpGte, ">=": OpGte,
+	"$lt": OpLt, "<": OpLt,
+	"$lte": OpLte, "<=": OpLte,
+	"$in": OpIn, "in": OpIn,
+	"$nin": OpNin, "not in": OpNin,
+	"$between": OpBetween, "between": OpBetween,
+	"$like": OpLike, "like": OpLike,
+	"$exists": OpExists,
+}
+
+// ParseQuery parses the query param. Syntax:
+//
+//	{ "name": "John" }                          -> name eq John
+//	{ "deletedAt": nil }                        -> deletedAt does not exist (missing or null)
+//	{ "age": { "$gt": 60, "$lte": 70 } }        -> age gt 60 and age lte 70
+//	{ "age": { "$in": []int{13, 25} } }         -> also $nin
+//	{ "age": { "$between": []int{13, 25} } }    -> inclusive range
+//	{ "email": { "$like": "%@gmail.com" } }     -> % any text, _ one character. Case insensitive
+//	{ "email": { "$exists": true } }
+//	{ "$or": []M{ {...}, {...} } }              -> also $and
+//	{ "$not": { ... } }
+//
+// Records without the field (missing or null) only match { "$exists": false } or nil, ne and nin included.
+// Fields are combined with and. An empty query returns an and Filter without children that matches all records.
+func ParseQuery(query moleculer.Payload) (Filter, error) {
+	if query == nil || !query.Exists() {
+		return Filter{Op: OpAnd}, nil
+	}
+	if !query.IsMap() {
+		return Filter{}, errors.New("Invalid query! It must be an object.")
+	}
+	filters := []Filter{}
+	for _, entry := range sortedEntries(query) {
+		filter, err := parseEntry(entry.key, entry.value)
+		if err != nil {
+			return Filter{}, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return Filter{Op: OpAnd, Filters: filters}, nil
+}
+
+type queryEntry struct {
+	key   string
+	value moleculer.Payload
+}
+
+// sortedEntries return the entries of the map sorted by key, so the translated queries are stable.
+func sortedEntries(p moleculer.Payload) []queryEntry {
+	entries := []queryEntry{}
+	p.ForEach(func(key interface{}, value moleculer.Payload) bool {
+		entries = append(entries, queryEntry{fmt.Sprint(key), value})
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	return entries
+}
+
+// parseEntry parses one entry of the query: a logical operator or a field.
+func parseEntry(key string, value moleculer.Payload) (Filter, error) {
+	switch strings.ToLower(key) {
+	case "$and", "and":
+		return parseList(OpAnd, value)
+	case "$or", "or":
+		return parseList(OpOr, value)
+	case "$not", "not":
+		child, err := ParseQuery(value)
+		if err != nil {
+			return Filter{}, err
+		}
+		return Filter{Op: OpNot, Filters: []Filter{child}}, nil
+	}
+	if strings.HasPrefix(key, "$") {
+		return Filter{}, errors.New(fmt.Sprint("Invalid query operator: ", key))
+	}
+	return parseField(key, value)
+}
+
+// parseList parses the list of queries of the and/or operators.
+func parseList(op string, value moleculer.Payload) (Filter, error) {
+	if !value.IsArray() {
+		return Filter{}, errors.New(fmt.Sprint("Invalid query! ", op, " requires a list."))
+	}
+	filters := []Filter{}
+	for _, item := range value.Array() {
+		child, err := ParseQuery(item)
+		if err != nil {
+			return Filter{}, err
+		}
+		filters = append(filters, child)
+	}
+	return Filter{Op: op, Filters: filters}, nil
+}
+
+// parseField parses the value or the operators map of a field.
+func parseField(field string, value moleculer.Payload) (Filter, error) {
+	if !value.Exists() {
+		return Filter{Op: OpExists, Field: field, Value: false}, nil
+	}
+	switch strings.ToUpper(value.String()) {
+	case "IS NULL":
+		return Filter{Op: OpExists, Field: field, Value: false}, nil
+	case "IS NOT NULL":
+		return Filter{Op: OpExists, Field: field, Value: true}, nil
+	}
+	if !value.IsMap() {
+		return Filter{Op: OpEq, Field: field, Value: value.Value()}, nil
+	}
+	filters := []Filter{}
+	for _, entry := range sortedEntries(value) {
+		filter, err := parseOperator(field, entry.key, entry.value)
+		if err != nil {
+			return Filter{}, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return Filter{Op: OpAnd, Filters: filters}, nil
+}
+
+// parseOperator parses one operator of a field.
+func parseOperator(field, key string, value moleculer.Payload) (Filter, error) {
+	key = strings.ToLower(key)
+	if key == "not like" || key == "not between" {
+		child, err := parseOperator(field, strings.TrimPrefix(key, "not "), value)
+		if err != nil {
+			return Filter{}, err
+		}
+		return Filter{Op: OpNot, Filters: []Filter{child}}, nil
+	}
+	op, valid := operatorAliases[key]
+	if !valid {
+		return Filter{}, errors.New(fmt.Sprint("Invalid query operator: ", key, " field: ", field))
+	}
+	switch op {
+	case OpEq, OpNe:
+		if !value.Exists() {
+			return Filter{Op: OpExists, Field: field, Value: op == OpNe}, nil
+		}
+	case OpIn, OpNin:
+		if !value.IsArray() {
+			return Filter{}, errors.New(fmt.Sprint("Invalid query! ", op, " requires a list. field: ", field))
+		}
+		return Filter{Op: op, Field: field, Value: listValues(value)}, nil
+	case OpBetween:
+		if !value.IsArray() || value.Len() != 2 {
+			return Filter{}, errors.New(fmt.Sprint("Invalid query! between requires a list with 2 values. field: ", field))
+		}
+		return Filter{Op: op, Field: field, Value: listValues(value)}, nil
+	case OpExists:
+		return Filter{Op: op, Field: field, Value: value.Bool()}, nil
+	case OpLike:
+		return Filter{Op: op, Field: field, Value: value.String()}, nil
+	}
+	return Filter{Op: op, Field: field, Value: value.Value()}, nil
+}
+
+func listValues(value moleculer.Payload) []interface{} {
+	list := []interface{}{}
+	for _, item := range value.Array() {
+		list = append(list, item.Value())
+	}
+	return list
+}
+
+// Values return the list of values of the in, nin and between filters.
+func (f Filter) Values() []interface{} {
+	list, _ := f.Value.([]interface{})
+	return list
+}
+
+// IsEmpty check if the filter matches all records: an and without children.
+func (f Filter) IsEmpty() bool {
+	return f.Op == OpAnd && len(f.Filters) == 0
+}
+
+// Match evaluates the filter against the record. Like in SQL, records without the field
+// only match the exists filter, ne and nin included.
+func (f Filter) Match(record moleculer.Payload) bool {
+	switch f.Op {
+	case OpAnd:
+		for _, child := range f.Filters {
+			if !child.Match(record) {
+				return false
+			}
+		}
+		return true
+	case OpOr:
+		for _, child := range f.Filters {
+			if child.Match(record) {
+				return true
+			}
+		}
+		return false
+	case OpNot:
+		return len(f.Filters) == 1 && !f.Filters[0].Match(record)
+	}
+	value := record.Get(f.Field)
+	if f.Op == OpExists {
+		return value.Exists() == f.Value.(bool)
+	}
+	if !value.Exists() {
+		return false
+	}
+	switch f.Op {
+	case OpNe:
+		return CompareValues(value.Value(), f.Value) != 0
+	case OpNin:
+		return !matchAny(value.Value(), f.Values())
+	case OpEq:
+		return CompareValues(value.Value(), f.Value) == 0
+	case OpGt:
+		return CompareValues(value.Value(), f.Value) > 0
+	case OpGte:
+		return CompareValues(value.Value(), f.Value) >= 0
+	case OpLt:
+		return CompareValues(value.Value(), f.Value) < 0
+	case OpLte:
+		return CompareValues(value.Value(), f.Value) <= 0
+	case OpIn:
+		return matchAny(value.Value(), f.Values())
+	case OpBetween:
+		values := f.Values()
+		return CompareValues(value.Value(), values[0]) >= 0 && CompareValues(value.Value(), values[1]) <= 0
+	case OpLike:
+		return regexp.MustCompile("(?i)" + LikePattern(f.Value.(string))).MatchString(fmt.Sprint(value.Value()))
+	}
+	return false
+}
+
+func matchAny(value interface{}, list []interface{}) bool {
+	for _, item := range list {
+		if CompareValues(value, item) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareValues compare numbers as numbers, times as times and everything else as strings.
+// returns -1, 0 or 1.
+func CompareValues(a, b interface{}) int {
+	if fa, isNumber := toFloat(a); isNumber {
+		if fb, isNumber := toFloat(b); isNumber {
+			return compareFloats(fa, fb)
+		}
+	}
+	if ta, isTime := a.(time.Time); isTime {
+		if tb, isTime := b.(time.Time); isTime {
+			if ta.Before(tb) {
+				return -1
+			}
+			if ta.After(tb) {
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func compareFloats(a, b float64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// LikePattern converts a like pattern (% any text, _ one character) to an anchored regular expression.
+func LikePattern(like string) string {
+	pattern := ""
+	for _, c := range like {
+		switch c {
+		case '%':
+			pattern = pattern + ".*"
+		case '_':
+			pattern = pattern + "."
+		default:
+			pattern = pattern + regexp.QuoteMeta(string(c))
+		}
+	}
+	return "^" + pattern + "$"
+}
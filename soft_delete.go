@@ -0,0 +1,204 @@
+package store
+
+import (
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// deletedAtField is the field set by the remove action when softDelete is enabled.
+const deletedAtField = "deletedAt"
+
+// softDeleteEnabled check the softDelete setting.
+func softDeleteEnabled(settings map[string]interface{}) bool {
+	enabled, _ := settings["softDelete"].(bool)
+	return enabled
+}
+
+// excludeDeleted adds { deletedAt: nil } to the query params, so soft deleted records are not returned.
+// When the query already filters by deletedAt it is not changed.
+func excludeDeleted(settings map[string]interface{}, params moleculer.Payload) moleculer.Payload {
+	if !softDeleteEnabled(settings) || (params != nil && params.IsError()) {
+		return params
+	}
+	if params == nil || !params.IsMap() {
+		params = payload.Empty()
+	}
+	query := payload.Empty()
+	if params.Get("query").IsMap() {
+		query = query.AddMany(params.Get("query").RawMap())
+	}
+	if _, filtered := query.RawMap()[deletedAtField]; !filtered {
+		query = query.Add(deletedAtField, nil)
+	}
+	return params.Remove("query").Add("query", query)
+}
+
+// isDeleted check if the record was soft deleted.
+func isDeleted(record moleculer.Payload) bool {
+	return record != nil && record.IsMap() && record.Get(deletedAtField).Exists()
+}
+
+// withoutDeleted removes soft deleted records from the result of FindById and FindByIds.
+func withoutDeleted(settings map[string]interface{}, result moleculer.Payload) moleculer.Payload {
+	if !softDeleteEnabled(settings) || result == nil || result.IsError() {
+		return result
+	}
+	if result.IsArray() {
+		list := []moleculer.Payload{}
+		result.ForEach(func(idx interface{}, item moleculer.Payload) bool {
+			if !isDeleted(item) {
+				list = append(list, item)
+			}
+			return true
+		})
+		return payload.New(list)
+	}
+	if isDeleted(result) {
+		return payload.New(nil)
+	}
+	return result
+}
+
+// softRemoveById sets deletedAt on the record. returns the deletedCount: 0 when the record is missing or already deleted.
+func softRemoveById(adapter Adapter, id moleculer.Payload) moleculer.Payload {
+	record := adapter.FindById(id)
+	if record.IsError() {
+		return record
+	}
+	if !record.Exists() || isDeleted(record) {
+		return payload.Empty().Add("deletedCount", 0)
+	}
+	r := adapter.UpdateById(id, payload.Empty().Add(deletedAtField, time.Now()))
+	if r.IsError() {
+		return r
+	}
+	return payload.Empty().Add("deletedCount", 1)
+}
+
+// softRemoveMany sets deletedAt on the records with params.ids or matching the params. returns the deletedCount.
+func softRemoveMany(settings map[string]interface{}, adapter Adapter, params moleculer.Payload) moleculer.Payload {
+	if !params.Get("ids").Exists() {
+		r := adapter.UpdateMany(excludeDeleted(settings, params).Add("update", map[string]interface{}{
+			deletedAtField: time.Now(),
+		}))
+		if r.IsError() {
+			return r
+		}
+		return payload.Empty().Add("deletedCount", r.Get("modifiedCount").Value())
+	}
+	return RunInTransaction(adapter, func(tx Adapter) moleculer.Payload {
+		deletedCount := 0
+		for _, id := range params.Get("ids").Array() {
+			r := softRemoveById(tx, id)
+			if r.IsError() {
+				return r
+			}
+			deletedCount = deletedCount + r.Get("deletedCount").Int()
+		}
+		return payload.Empty().Add("deletedCount", deletedCount)
+	})
+}
+
+// restoreAction clears the deletedAt of a soft deleted record.
+func restoreAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		settings := getInstance().Settings
+		if !softDeleteEnabled(settings) {
+			return payload.Error("Action restore requires the softDelete setting!")
+		}
+		if params == nil || !paramsId(settings, params).Exists() {
+			return payload.Error(idFieldName(settings), " field required!")
+		}
+		hooks := []string{beforeEntityUpdate, afterEntityUpdate, afterEntityChange}
+		entity := runWithHooks(adapter, settings, hooks, func(tx Adapter) moleculer.Payload {
+			decoded := callHook(settings, beforeEntityUpdate, ctx, tx, decodeParams(settings, params))
+			if decoded.IsError() {
+				return decoded
+			}
+			id := paramsId(settings, decoded)
+			record := tx.FindById(id)
+			if record.IsError() {
+				return payload.Error("Could not restore record. Error: ", record.Error().Error())
+			}
+			if !isDeleted(record) {
+				return payload.Error("Could not restore record. Record not found or not deleted. id: ", id.String())
+			}
+			r := tx.UpdateById(id, payload.Empty().Add(deletedAtField, nil))
+			if r.IsError() {
+				return payload.Error("Could not restore record. Error: ", r.Error().Error())
+			}
+			return callAfterHooks(settings, afterEntityUpdate, ctx, tx, tx.FindById(id))
+		})
+		if entity.IsError() {
+			return entity
+		}
+		entity = encodeIds(settings, entity)
+		publishEvent(ctx, getInstance, "restore", "restored", map[string]interface{}{
+			"id":     paramsId(settings, params).Value(),
+			"entity": payloadValue(entity),
+		})
+		return entity
+	}
+}
+
+// purgeAction permanently removes the soft deleted records with the id or ids params, or all of them.
+func purgeAction(adapter Adapter, getInstance func() *moleculer.ServiceSchema) moleculer.ActionHandler {
+	return func(ctx moleculer.Context, params moleculer.Payload) interface{} {
+		settings := getInstance().Settings
+		if !softDeleteEnabled(settings) {
+			return payload.Error("Action purge requires the softDelete setting!")
+		}
+		if params == nil || !params.IsMap() {
+			params = payload.Empty()
+		}
+		hooks := []string{beforeEntityRemove, afterEntityRemove, afterEntityChange}
+		r := runWithHooks(adapter, settings, hooks, func(tx Adapter) moleculer.Payload {
+			params := callHook(settings, beforeEntityRemove, ctx, tx, decodeParams(settings, params))
+			if params.IsError() {
+				return params
+			}
+			r := purge(settings, tx, params)
+			if r.IsError() {
+				return payload.Error("Could not purge records. Error: ", r.Error().Error())
+			}
+			return callAfterHooks(settings, afterEntityRemove, ctx, tx, r)
+		})
+		if r.IsError() {
+			return r
+		}
+		publishEvent(ctx, getInstance, "purge", "purged", map[string]interface{}{
+			"deletedCount": r.Get("deletedCount").Value(),
+		})
+		return r
+	}
+}
+
+// purge removes the deleted records with params.id or params.ids, or all the deleted records. returns the deletedCount.
+func purge(settings map[string]interface{}, adapter Adapter, params moleculer.Payload) moleculer.Payload {
+	id := paramsId(settings, params)
+	if !id.Exists() && !params.Get("ids").Exists() {
+		return adapter.RemoveMany(payload.Empty().Add("query", map[string]interface{}{
+			deletedAtField: map[string]interface{}{"$ne": nil},
+		}))
+	}
+	ids := params.Get("ids").Array()
+	if id.Exists() {
+		ids = []moleculer.Payload{id}
+	}
+	return RunInTransaction(adapter, func(tx Adapter) moleculer.Payload {
+		deletedCount := 0
+		for _, id := range ids {
+			if !isDeleted(tx.FindById(id)) {
+				continue
+			}
+			r := tx.RemoveById(id)
+			if r.IsError() {
+				return r
+			}
+			deletedCount = deletedCount + r.Get("deletedCount").Int()
+		}
+		return payload.Empty().Add("deletedCount", deletedCount)
+	})
+}
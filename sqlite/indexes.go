@@ -0,0 +1,149 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+)
+
+// Index is an index of one or more columns of the table. A column prefixed with - is in descending order.
+type Index struct {
+	// Name default: idx_<table>_<columns>
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// indexPrefix of the names of the indexes managed by the adapter.
+func (a *Adapter) indexPrefix() string {
+	return "idx_" + a.Table + "_"
+}
+
+// indexName return the Name of the index or the default name from the columns.
+func (a *Adapter) indexName(index Index) string {
+	if index.Name != "" {
+		return index.Name
+	}
+	names := []string{}
+	for _, column := range index.Columns {
+		field, direction := sortEntry(column)
+		if direction == "DESC" {
+			field = field + "_desc"
+		}
+		names = append(names, field)
+	}
+	return a.indexPrefix() + strings.Join(names, "_")
+}
+
+// indexes return the indexes of the Columns (Index and Unique) and the composite Indexes.
+func (a *Adapter) indexes() []Index {
+	list := []Index{}
+	for _, c := range a.Columns {
+		if c.Index || c.Unique {
+			list = append(list, Index{Columns: []string{c.Name}, Unique: c.Unique})
+		}
+	}
+	return append(list, a.Indexes...)
+}
+
+// indexStatement return the CREATE INDEX statement of the index, in the form SQLite keeps it in sqlite_master.
+func (a *Adapter) indexStatement(index Index) (string, error) {
+	if len(index.Columns) == 0 {
+		return "", errors.New(fmt.Sprint("Invalid index: ", index.Name, " without columns."))
+	}
+	columns := []string{}
+	for _, column := range index.Columns {
+		field, direction := sortEntry(column)
+		if !a.validField(field) {
+			return "", errors.New(fmt.Sprint("Invalid index column: ", field))
+		}
+		if direction == "DESC" {
+			field = field + " DESC"
+		}
+		columns = append(columns, field)
+	}
+	create := "CREATE INDEX "
+	if index.Unique {
+		create = "CREATE UNIQUE INDEX "
+	}
+	return create + a.indexName(index) + " ON " + a.Table + " (" + strings.Join(columns, ", ") + ")", nil
+}
+
+// tableIndexes return the statements of the indexes of the table (name -> sql) from sqlite_master.
+// The indexes created by SQLite for the constraints (sql is NULL) are not returned.
+func (a *Adapter) tableIndexes(conn *sqlite.Conn) (map[string]string, error) {
+	indexes := map[string]string{}
+	selec := "SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL;"
+	err := sqlitex.Exec(conn, selec, func(stmt *sqlite.Stmt) error {
+		indexes[stmt.GetText("name")] = stmt.GetText("sql")
+		return nil
+	}, a.Table)
+	return indexes, err
+}
+
+// syncIndexes creates the declared indexes, recreates the ones whose definition changed and drops the
+// indexes with the adapter prefix (idx_<table>_) that are no longer declared.
+func (a *Adapter) syncIndexes(conn *sqlite.Conn) error {
+	existing, err := a.tableIndexes(conn)
+	if err != nil {
+		return err
+	}
+	declared := map[string]bool{}
+	statements := []string{}
+	for _, index := range a.indexes() {
+		create, err := a.indexStatement(index)
+		if err != nil {
+			return err
+		}
+		name := a.indexName(index)
+		if declared[name] {
+			return errors.New(fmt.Sprint("Duplicated index: ", name))
+		}
+		declared[name] = true
+		current, exists := existing[name]
+		if exists && current == create {
+			continue
+		}
+		if exists {
+			statements = append(statements, "DROP INDEX "+name+";")
+		}
+		statements = append(statements, create+";")
+	}
+	for name := range existing {
+		if !declared[name] && strings.HasPrefix(name, a.indexPrefix()) {
+			statements = append([]string{"DROP INDEX " + name + ";"}, statements...)
+		}
+	}
+	if len(statements) == 0 {
+		return nil
+	}
+	return inTransaction(conn, func() error {
+		for _, statement := range statements {
+			a.log.Info("SQLite adapter - ", statement)
+			if err := sqlitex.ExecTransient(conn, statement, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sqlLiteral return the SQL literal of a column Default value.
+func sqlLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case time.Time:
+		value = v.UTC().Format(ISO8601)
+	}
+	return "'" + strings.Replace(fmt.Sprint(value), "'", "''", -1) + "'"
+}
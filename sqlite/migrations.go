@@ -0,0 +1,237 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+)
+
+// MigrationsTable default name of the table where the applied migrations are recorded.
+const MigrationsTable = "store_migrations"
+
+// Migration is a versioned change of the database, applied once on Connect in the order of Version.
+// Up (and Down) can be SQL statements, a Go function or both: the SQL runs first.
+// Each migration runs in a transaction with the record of the migrations table.
+type Migration struct {
+	Version     int
+	Description string
+	UpSQL       string
+	DownSQL     string
+	Up          func(conn *sqlite.Conn) error
+	Down        func(conn *sqlite.Conn) error
+}
+
+// migrationsTable return the MigrationsTable of the adapter or the default one.
+func (a *Adapter) migrationsTable() string {
+	if a.MigrationsTable != "" {
+		return a.MigrationsTable
+	}
+	return MigrationsTable
+}
+
+// inTransaction calls fn in a transaction, rolled back when fn returns an error.
+func inTransaction(conn *sqlite.Conn, fn func() error) error {
+	if err := sqlitex.ExecTransient(conn, "BEGIN;", nil); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		sqlitex.ExecTransient(conn, "ROLLBACK;", nil)
+		return err
+	}
+	return sqlitex.ExecTransient(conn, "COMMIT;", nil)
+}
+
+// tableColumns return the columns of the table (lower case name -> declared type) from PRAGMA table_info.
+func (a *Adapter) tableColumns(conn *sqlite.Conn) (map[string]string, error) {
+	columns := map[string]string{}
+	err := sqlitex.ExecTransient(conn, "PRAGMA table_info("+a.Table+");", func(stmt *sqlite.Stmt) error {
+		columns[strings.ToLower(stmt.GetText("name"))] = stmt.GetText("type")
+		return nil
+	})
+	return columns, err
+}
+
+// addColumns adds the Columns missing in the table with ALTER TABLE ADD COLUMN.
+// SQLite can't change the type of a column, a type drift is only logged and requires a migration.
+func (a *Adapter) addColumns(conn *sqlite.Conn) error {
+	existing, err := a.tableColumns(conn)
+	if err != nil {
+		return err
+	}
+	for _, c := range a.Columns {
+		current, exists := existing[strings.ToLower(c.Name)]
+		if exists {
+			if c.Type != "" && !strings.EqualFold(current, dbType(c.Type)) {
+				a.log.Warn("SQLite adapter - column ", a.Table, ".", c.Name, " is ", current, " in the database and ", dbType(c.Type), " in the Columns. Add a migration to change it.")
+			}
+			continue
+		}
+		alter := "ALTER TABLE " + a.Table + " ADD COLUMN " + columnDefinition(c) + ";"
+		a.log.Info("SQLite adapter - ", alter)
+		if err := sqlitex.ExecTransient(conn, alter, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appliedMigrations creates the migrations table when it does not exist
+// and return the versions of the migrations applied to the table.
+func (a *Adapter) appliedMigrations(conn *sqlite.Conn) (map[int]bool, error) {
+	create := "CREATE TABLE IF NOT EXISTS " + a.migrationsTable() +
+		" (tableName TEXT NOT NULL, version INTEGER NOT NULL, description TEXT, appliedAt TEXT, PRIMARY KEY (tableName, version));"
+	if err := sqlitex.ExecTransient(conn, create, nil); err != nil {
+		return nil, err
+	}
+	applied := map[int]bool{}
+	selec := "SELECT version FROM " + a.migrationsTable() + " WHERE tableName = ?;"
+	err := sqlitex.Exec(conn, selec, func(stmt *sqlite.Stmt) error {
+		applied[int(stmt.GetInt64("version"))] = true
+		return nil
+	}, a.Table)
+	return applied, err
+}
+
+// sortedMigrations return the Migrations sorted by Version.
+// returns an error when a version is not positive or is repeated.
+func (a *Adapter) sortedMigrations() ([]Migration, error) {
+	migrations := append([]Migration{}, a.Migrations...)
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, m := range migrations {
+		if m.Version <= 0 {
+			return nil, errors.New(fmt.Sprint("Invalid migration version: ", m.Version, " it must be greater than 0."))
+		}
+		if i > 0 && migrations[i-1].Version == m.Version {
+			return nil, errors.New(fmt.Sprint("Duplicated migration version: ", m.Version))
+		}
+	}
+	return migrations, nil
+}
+
+// runStep runs the SQL and then the function of one direction of a migration.
+func runStep(conn *sqlite.Conn, script string, fn func(conn *sqlite.Conn) error) error {
+	if script != "" {
+		if err := sqlitex.ExecScript(conn, script); err != nil {
+			return err
+		}
+	}
+	if fn != nil {
+		return fn(conn)
+	}
+	return nil
+}
+
+// migrate adds the missing columns, applies the pending migrations and syncs the indexes and the search table.
+func (a *Adapter) migrate(conn *sqlite.Conn) error {
+	if err := a.addColumns(conn); err != nil {
+		return err
+	}
+	if err := a.applyMigrations(conn); err != nil {
+		return err
+	}
+	if err := a.syncIndexes(conn); err != nil {
+		return err
+	}
+	return a.syncSearch(conn)
+}
+
+// applyMigrations applies the migrations that are not recorded in the migrations table.
+func (a *Adapter) applyMigrations(conn *sqlite.Conn) error {
+	migrations, err := a.sortedMigrations()
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return nil
+	}
+	applied, err := a.appliedMigrations(conn)
+	if err != nil {
+		return err
+	}
+	insert := "INSERT INTO " + a.migrationsTable() + " (tableName, version, description, appliedAt) VALUES (?, ?, ?, ?);"
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		if m.UpSQL == "" && m.Up == nil {
+			return errors.New(fmt.Sprint("Migration ", m.Version, " has no UpSQL or Up function."))
+		}
+		a.log.Info("SQLite adapter - applying migration ", m.Version, " ", m.Description, " to table ", a.Table)
+		err := inTransaction(conn, func() error {
+			if err := runStep(conn, m.UpSQL, m.Up); err != nil {
+				return err
+			}
+			return sqlitex.Exec(conn, insert, nil, a.Table, m.Version, m.Description, time.Now().UTC().Format(ISO8601))
+		})
+		if err != nil {
+			return errors.New(fmt.Sprint("Error on migration ", m.Version, " - error: ", err))
+		}
+	}
+	return nil
+}
+
+// MigrateDown reverts the applied migrations with a version greater than the version param,
+// from the last to the first. MigrateDown(0) reverts all of them. The adapter must be connected.
+func (a *Adapter) MigrateDown(version int) error {
+	conn := a.getConn()
+	if conn == nil {
+		return noConnectionError().Error()
+	}
+	defer a.returnConn(conn)
+	migrations, err := a.sortedMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := a.appliedMigrations(conn)
+	if err != nil {
+		return err
+	}
+	delete := "DELETE FROM " + a.migrationsTable() + " WHERE tableName = ? AND version = ?;"
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= version || !applied[m.Version] {
+			continue
+		}
+		if m.DownSQL == "" && m.Down == nil {
+			return errors.New(fmt.Sprint("Migration ", m.Version, " has no DownSQL or Down function."))
+		}
+		a.log.Info("SQLite adapter - reverting migration ", m.Version, " ", m.Description, " of table ", a.Table)
+		err := inTransaction(conn, func() error {
+			if err := runStep(conn, m.DownSQL, m.Down); err != nil {
+				return err
+			}
+			return sqlitex.Exec(conn, delete, nil, a.Table, m.Version)
+		})
+		if err != nil {
+			return errors.New(fmt.Sprint("Error reverting migration ", m.Version, " - error: ", err))
+		}
+	}
+	return nil
+}
+
+// MigrationVersion return the version of the last migration applied to the table, 0 when there is none.
+func (a *Adapter) MigrationVersion() (int, error) {
+	conn := a.getConn()
+	if conn == nil {
+		return 0, noConnectionError().Error()
+	}
+	defer a.returnConn(conn)
+	applied, err := a.appliedMigrations(conn)
+	if err != nil {
+		return 0, err
+	}
+	last := 0
+	for version := range applied {
+		if version > last {
+			last = version
+		}
+	}
+	return last, nil
+}
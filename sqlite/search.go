@@ -0,0 +1,199 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"crawshaw.io/sqlite"
+	"crawshaw.io/sqlite/sqlitex"
+	"github.com/moleculer-go/moleculer"
+)
+
+// searchTable name of the FTS5 table of the Search columns.
+func (a *Adapter) searchTable() string {
+	return a.Table + "_fts"
+}
+
+// searchColumns return the names of the Columns with Search.
+func (a *Adapter) searchColumns() []string {
+	columns := []string{}
+	for _, c := range a.Columns {
+		if c.Search {
+			columns = append(columns, c.Name)
+		}
+	}
+	return columns
+}
+
+// searchTriggers return the names and the statements of the triggers that keep the FTS5 table in sync with the table.
+func (a *Adapter) searchTriggers() (names []string, statements []string) {
+	columns := strings.Join(a.searchColumns(), ", ")
+	values := func(prefix string) string {
+		list := []string{prefix + a.idField}
+		for _, column := range a.searchColumns() {
+			list = append(list, prefix+column)
+		}
+		return strings.Join(list, ", ")
+	}
+	fts := a.searchTable()
+	insert := "INSERT INTO " + fts + " (rowid, " + columns + ") VALUES (" + values("new.") + ");"
+	delete := "INSERT INTO " + fts + " (" + fts + ", rowid, " + columns + ") VALUES ('delete', " + values("old.") + ");"
+	names = []string{fts + "_insert", fts + "_delete", fts + "_update"}
+	statements = []string{
+		"CREATE TRIGGER " + names[0] + " AFTER INSERT ON " + a.Table + " BEGIN " + insert + " END;",
+		"CREATE TRIGGER " + names[1] + " AFTER DELETE ON " + a.Table + " BEGIN " + delete + " END;",
+		"CREATE TRIGGER " + names[2] + " AFTER UPDATE ON " + a.Table + " BEGIN " + delete + " " + insert + " END;",
+	}
+	return names, statements
+}
+
+// searchTableStatement return the CREATE VIRTUAL TABLE statement of the FTS5 table, in the form SQLite keeps it in sqlite_master.
+// The table is an external content table: it indexes the Search columns without storing them again.
+func (a *Adapter) searchTableStatement() string {
+	return "CREATE VIRTUAL TABLE " + a.searchTable() + " USING fts5(" + strings.Join(a.searchColumns(), ", ") +
+		", content='" + a.Table + "', content_rowid='" + a.idField + "')"
+}
+
+// syncSearch creates the FTS5 table and its triggers when there are Search columns and indexes the existing records.
+// When the Search columns change the FTS5 table is created again, without Search columns it is dropped.
+func (a *Adapter) syncSearch(conn *sqlite.Conn) error {
+	current := ""
+	err := sqlitex.Exec(conn, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?;", func(stmt *sqlite.Stmt) error {
+		current = stmt.GetText("sql")
+		return nil
+	}, a.searchTable())
+	if err != nil {
+		return err
+	}
+	create := a.searchTableStatement()
+	hasSearch := len(a.searchColumns()) > 0
+	if (hasSearch && current == create) || (!hasSearch && current == "") {
+		return nil
+	}
+	names, triggers := a.searchTriggers()
+	statements := []string{}
+	if current != "" {
+		for _, name := range names {
+			statements = append(statements, "DROP TRIGGER IF EXISTS "+name+";")
+		}
+		statements = append(statements, "DROP TABLE "+a.searchTable()+";")
+	}
+	if hasSearch {
+		statements = append(statements, create+";")
+		statements = append(statements, triggers...)
+		statements = append(statements, "INSERT INTO "+a.searchTable()+" ("+a.searchTable()+") VALUES ('rebuild');")
+	}
+	return inTransaction(conn, func() error {
+		for _, statement := range statements {
+			a.log.Info("SQLite adapter - ", statement)
+			if err := sqlitex.ExecTransient(conn, statement, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// matchQuery converts the search text to a FTS5 query. All the words must match, in any order.
+// "quoted words" match the phrase and word* matches the words with the prefix.
+// The FTS5 operators are not accepted, so any text is a valid query. returns "" when there are no words.
+func matchQuery(text string) string {
+	terms := []string{}
+	addTerm := func(term string, prefix bool) {
+		term = strings.TrimSpace(strings.Replace(term, `"`, "", -1))
+		if term == "" {
+			return
+		}
+		term = `"` + term + `"`
+		if prefix {
+			term = term + "*"
+		}
+		terms = append(terms, term)
+	}
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case runes[i] == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			phrase := string(runes[i+1 : end])
+			i = end + 1
+			prefix := i < len(runes) && runes[i] == '*'
+			if prefix {
+				i++
+			}
+			addTerm(phrase, prefix)
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			word := string(runes[i:end])
+			i = end
+			addTerm(strings.TrimRight(word, "*"), strings.HasSuffix(word, "*"))
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// searchMatch return the FTS5 query of the search and searchFields params. Without searchFields all the
+// Search columns are searched. returns "" when there is no search.
+func (a *Adapter) searchMatch(params moleculer.Payload) (string, error) {
+	query := matchQuery(params.Get("search").String())
+	if !params.Get("search").Exists() || query == "" {
+		return "", nil
+	}
+	searchFields := params.Get("searchFields")
+	if !searchFields.Exists() {
+		return query, nil
+	}
+	fields := []string{searchFields.String()}
+	if searchFields.IsArray() {
+		fields = searchFields.StringArray()
+	}
+	searchColumns := a.searchColumns()
+	for _, field := range fields {
+		valid := false
+		for _, column := range searchColumns {
+			valid = valid || column == field
+		}
+		if !valid {
+			return "", errors.New(fmt.Sprint("Invalid search field: ", field, " it is not a Search column."))
+		}
+	}
+	return "{" + strings.Join(fields, " ") + "} : (" + query + ")", nil
+}
+
+// searchWhere return the condition of the records matching the FTS5 query.
+func (a *Adapter) searchWhere(params moleculer.Payload) (pairs []string, args []interface{}, err error) {
+	match, err := a.searchMatch(params)
+	if err != nil || match == "" {
+		return nil, nil, err
+	}
+	pairs = []string{a.idField + " IN (SELECT rowid FROM " + a.searchTable() + " WHERE " + a.searchTable() + " MATCH ?)"}
+	return pairs, []interface{}{match}, nil
+}
+
+// searchJoin return the JOIN of the FTS5 matches and the ORDER BY of the records by the FTS5 rank (the best matches first),
+// so the FTS5 table is queried once instead of once per record. returns "" when there is no search.
+func (a *Adapter) searchJoin(params moleculer.Payload) (join string, rank string, args []interface{}, err error) {
+	if len(a.searchColumns()) == 0 {
+		return "", "", nil, nil
+	}
+	match, err := a.searchMatch(params)
+	if err != nil || match == "" {
+		return "", "", nil, err
+	}
+	fts := a.searchTable()
+	matches := fts + "_match"
+	//the subquery exposes only the rowid and the rank, so the Search columns of the FTS5 table are not ambiguous
+	join = " JOIN (SELECT rowid AS fts_rowid, rank AS fts_rank FROM " + fts + " WHERE " + fts + " MATCH ?) AS " + matches +
+		" ON " + matches + ".fts_rowid = " + a.Table + "." + a.idField
+	return join, matches + ".fts_rank", []interface{}{match}, nil
+}
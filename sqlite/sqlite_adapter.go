@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime/debug"
@@ -14,6 +15,7 @@ import (
 	"github.com/moleculer-go/moleculer/serializer"
 
 	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/store"
 
 	"crawshaw.io/sqlite"
 	"crawshaw.io/sqlite/sqlitex"
@@ -28,6 +30,15 @@ const (
 type Column struct {
 	Name string
 	Type string
+	// Index creates an index of the column, Unique a unique index.
+	Index  bool
+	Unique bool
+	// NotNull adds the NOT NULL constraint. Columns added to an existing table also need a Default.
+	NotNull bool
+	// Default value of the column, used when the insert does not have the field.
+	Default interface{}
+	// Search adds the column to the full-text search (FTS5) table used by the search param.
+	Search bool
 }
 
 type Adapter struct {
@@ -40,6 +51,12 @@ type Adapter struct {
 	// ColName can be used to modify/translate column names
 	// from what is passed in the params
 	ColName func(string) string
+	// Migrations are applied on Connect, after the missing Columns are added to the table.
+	Migrations []Migration
+	// MigrationsTable is where the applied Migrations are recorded. Default: store_migrations
+	MigrationsTable string
+	// Indexes of multiple columns. Indexes of a single column can be declared in the Column.
+	Indexes []Index
 
 	pool                 *sqlitex.Pool
 	waitForPoolLimit     time.Duration
@@ -47,8 +64,10 @@ type Adapter struct {
 	waitConnectionsLimit time.Duration
 
 	connected bool
-	log       *log.Entry
-	settings  map[string]interface{}
+	// txConn is the connection used by all operations when the adapter is scoped to a transaction
+	txConn   *sqlite.Conn
+	log      *log.Entry
+	settings map[string]interface{}
 
 	fields     []string
 	idField    string
@@ -114,8 +133,8 @@ func (a *Adapter) Connect() error {
 	a.pool = pool
 	err = a.createTable()
 	if err != nil {
-		a.log.Error("Could not create table - error: ", err)
-		return errors.New(fmt.Sprint("Could not create table - error: ", err))
+		a.log.Error("Could not create or migrate table - error: ", err)
+		return errors.New(fmt.Sprint("Could not create or migrate table - error: ", err))
 	}
 	a.log.Info("SQLite adapter " + a.Table + " connected!")
 	a.connected = true
@@ -174,6 +193,9 @@ func (a *Adapter) catchConnError(msg string, resChan chan moleculer.Payload) {
 }
 
 func (a *Adapter) returnConn(conn *sqlite.Conn) {
+	if conn == a.txConn {
+		return
+	}
 	a.pool.Put(conn)
 	a.connInUse = a.connInUse - 1
 }
@@ -182,6 +204,9 @@ func (a *Adapter) returnConn(conn *sqlite.Conn) {
 // if pool is not available and setting waitForPoolLimit is set
 // it will wait for that period for the pool to be available
 func (a *Adapter) getConn() *sqlite.Conn {
+	if a.txConn != nil {
+		return a.txConn
+	}
 	if a.pool == nil {
 		if a.waitForPoolLimit == 0 {
 			panic("Adapter not connected!")
@@ -201,21 +226,69 @@ func (a *Adapter) getConn() *sqlite.Conn {
 	return a.pool.Get(nil)
 }
 
+// transaction is an Adapter scoped to a connection with an open transaction.
+type transaction struct {
+	*Adapter
+	parent *Adapter
+}
+
+// end execute the statement that finishes the transaction and return the connection to the pool.
+func (t *transaction) end(statement string) error {
+	conn := t.txConn
+	if conn == nil {
+		return errors.New("Transaction already finished!")
+	}
+	t.txConn = nil
+	defer t.parent.returnConn(conn)
+	return sqlitex.ExecTransient(conn, statement, nil)
+}
+
+func (t *transaction) Commit() error {
+	return t.end("COMMIT;")
+}
+
+func (t *transaction) Rollback() error {
+	return t.end("ROLLBACK;")
+}
+
+// Begin starts a transaction on a connection of the pool.
+// The connection is kept by the transaction until Commit or Rollback is called.
+func (a *Adapter) Begin() (store.Transaction, error) {
+	conn := a.getConn()
+	if conn == nil {
+		return nil, errors.New("No connection availble!. Did you call a.Connect() ?")
+	}
+	if err := sqlitex.ExecTransient(conn, "BEGIN;", nil); err != nil {
+		a.returnConn(conn)
+		return nil, err
+	}
+	scoped := *a
+	scoped.txConn = conn
+	return &transaction{&scoped, a}, nil
+}
+
 // updatePairs generate the update pairs (one list of columns and one of values) used for update statement.
-func (a *Adapter) updatePairs(param moleculer.Payload) ([]string, []interface{}) {
-	columns := []string{}
-	values := []interface{}{}
+// returns an error when a field is not a column.
+func (a *Adapter) updatePairs(param moleculer.Payload) (columns []string, values []interface{}, err error) {
 	param.ForEach(func(key interface{}, value moleculer.Payload) bool {
 		col, ok := key.(string)
 		if !ok {
 			a.log.Error("extractFields() key must be string! - key: ", key)
 			return false
 		}
+		if !a.validField(col) {
+			err = errors.New(fmt.Sprint("Invalid update field: ", col))
+			return false
+		}
+		v := value.Value()
+		if findColumn(col, a.Columns) != nil {
+			v = a.transformIn(col, v)
+		}
 		columns = append(columns, a.ColName(col)+" = ?")
-		values = append(values, value.Value())
+		values = append(values, v)
 		return true
 	})
-	return columns, values
+	return columns, values, err
 }
 
 // insertFields will parse the payload and extract the column names with
@@ -280,19 +353,32 @@ func (a *Adapter) loadSettings(settings map[string]interface{}) {
 	}
 }
 
+// columnDefinition return the definition of the column for CREATE TABLE and ALTER TABLE ADD COLUMN
+func columnDefinition(c Column) string {
+	def := c.Name
+	if c.Type != "" {
+		def = def + " " + dbType(c.Type)
+	}
+	if c.NotNull {
+		def = def + " NOT NULL"
+	}
+	if c.Default != nil {
+		def = def + " DEFAULT " + sqlLiteral(c.Default)
+	}
+	return def
+}
+
 // columnsDefinition return the column definitions for CREATE TABLE
 func (a *Adapter) columnsDefinition() []string {
 	columns := []string{a.idField + " INTEGER PRIMARY KEY AUTOINCREMENT"}
 	for _, c := range a.Columns {
-		def := c.Name
-		if c.Type != "" {
-			def = def + " " + dbType(c.Type)
-		}
-		columns = append(columns, def)
+		columns = append(columns, columnDefinition(c))
 	}
 	return columns
 }
 
+// createTable creates the table when it does not exist, adds the missing columns, applies the migrations and syncs the indexes.
+
 func (a *Adapter) createTable() error {
 	resChan := make(chan moleculer.Payload, 1)
 	go func() {
@@ -300,6 +386,7 @@ func (a *Adapter) createTable() error {
 		conn := a.getConn()
 		if conn == nil {
 			resChan <- noConnectionError()
+			return
 		}
 		defer a.returnConn(conn)
 
@@ -309,8 +396,13 @@ func (a *Adapter) createTable() error {
 		err := sqlitex.ExecTransient(conn, create, nil)
 		if err != nil {
 			resChan <- payload.New(err)
+			return
 		}
 		a.log.Debug("table " + a.Table + " created !!!")
+		if err := a.migrate(conn); err != nil {
+			resChan <- payload.New(err)
+			return
+		}
 		resChan <- payload.Empty()
 	}()
 	p := <-resChan
@@ -346,37 +438,52 @@ func (a *Adapter) FindAndUpdate(param moleculer.Payload) moleculer.Payload {
 		}
 		defer a.returnConn(conn)
 		update := param.Get("update")
-		param = param.Remove("update")
-
-		originals := a.query(conn, a.findFields(param), param, a.rowToPayload)
-		if originals.IsError() {
-			results <- originals
-			return
-		}
-		result := []moleculer.Payload{}
-		for _, item := range originals.Array() {
-			id := item.Get(a.idField)
-			if err := a.updateById(conn, id, update); err != nil {
-				result = append(result, payload.New(err))
-			} else {
-				filter := payload.New(map[string]interface{}{
-					"query": map[string]interface{}{a.idField: id.Value()},
-				})
-				updated := a.query(conn, a.findFields(filter), filter, a.rowToPayload).First()
-				result = append(result, updated)
-			}
+		if !update.Exists() || !update.IsMap() {
+			results <- payload.Error("FindAndUpdate() requires the update param!")
+			return
+		}
+		result, err := a.findAndUpdate(conn, param.Remove("update"), update)
+		if err != nil {
+			results <- payload.New(err)
+			return
 		}
 		results <- payload.New(result)
 	}()
 	return <-results
 }
 
+// findAndUpdate updates the records matching the params inside a savepoint, so the select and the updates
+// see the same rows and the first error rolls back all of them. returns the updated records.
+func (a *Adapter) findAndUpdate(conn *sqlite.Conn, param, update moleculer.Payload) (result []moleculer.Payload, err error) {
+	defer sqlitex.Save(conn)(&err)
+	originals := a.query(conn, a.findFields(param), param, a.rowToPayload)
+	if originals.IsError() {
+		return nil, originals.Error()
+	}
+	result = []moleculer.Payload{}
+	for _, item := range originals.Array() {
+		id := item.Get(a.idField)
+		if err = a.updateById(conn, id, update); err != nil {
+			return nil, err
+		}
+		filter := payload.New(map[string]interface{}{
+			"query": map[string]interface{}{a.idField: id.Value()},
+		})
+		updated := a.query(conn, a.findFields(filter), filter, a.rowToPayload)
+		if updated.IsError() {
+			return nil, updated.Error()
+		}
+		result = append(result, updated.First())
+	}
+	return result, nil
+}
+
 func (a *Adapter) Update(params moleculer.Payload) moleculer.Payload {
-	id := params.Get("id")
+	id := params.Get(a.idField)
 	if !id.Exists() {
 		return payload.Error("Cannot update record without id")
 	}
-	return a.UpdateById(id, params.Remove("id"))
+	return a.UpdateById(id, params.Remove(a.idField))
 }
 
 func (a *Adapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
@@ -398,6 +505,44 @@ func (a *Adapter) UpdateById(id, update moleculer.Payload) moleculer.Payload {
 	return <-results
 }
 
+// UpdateByIdVersion applies the update when the versionField column is equal to version (WHERE id = ? AND version = ?).
+// returns payload.New(nil) when no record matched.
+func (a *Adapter) UpdateByIdVersion(id moleculer.Payload, versionField string, version int64, update moleculer.Payload) moleculer.Payload {
+	results := make(chan moleculer.Payload, 1)
+	go func() {
+		defer a.catchConnError("Error on update by id and version: "+id.String(), results)
+		conn := a.getConn()
+		if conn == nil {
+			results <- noConnectionError()
+			return
+		}
+		defer a.returnConn(conn)
+		changes, values, err := a.updatePairs(update)
+		if err != nil {
+			results <- payload.New(err)
+			return
+		}
+		if !a.validField(versionField) {
+			results <- payload.Error("Invalid version field: ", versionField)
+			return
+		}
+		updtStmt := "UPDATE " + a.Table + " SET " + strings.Join(changes, ", ") + " WHERE " + a.idField + " = ? AND " + a.ColName(versionField) + " = ?;"
+		values = append(values, id.Value(), version)
+		a.log.Debug(updtStmt, " - values: ", values)
+		if err := sqlitex.Exec(conn, updtStmt, nil, values...); err != nil {
+			a.log.Error("Error on update by id and version: ", err)
+			results <- payload.New(err)
+			return
+		}
+		if conn.Changes() == 0 {
+			results <- payload.New(nil)
+			return
+		}
+		results <- a.findById(conn, id)
+	}()
+	return <-results
+}
+
 func (a *Adapter) Insert(param moleculer.Payload) moleculer.Payload {
 	resChan := make(chan moleculer.Payload, 1)
 	go func() {
@@ -423,6 +568,157 @@ func (a *Adapter) Insert(param moleculer.Payload) moleculer.Payload {
 	return <-resChan
 }
 
+// maxVariables max number of host parameters in a single statement.
+var maxVariables = 999
+
+// insertMany inserts the records using multi-row INSERT statements inside a savepoint.
+// Consecutive records with the same columns are inserted by the same statement.
+func (a *Adapter) insertMany(conn *sqlite.Conn, records []moleculer.Payload) (result []moleculer.Payload, err error) {
+	defer sqlitex.Save(conn)(&err)
+	result = []moleculer.Payload{}
+	var columns []string
+	rows := [][]interface{}{}
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		groups := make([]string, len(rows))
+		values := []interface{}{}
+		for i, row := range rows {
+			groups[i] = "(" + strings.Join(placeholders(columns), ", ") + ")"
+			values = append(values, row...)
+		}
+		insert := "INSERT INTO " + a.Table + " (" + strings.Join(columns, ", ") + ") VALUES " + strings.Join(groups, ", ") + " ;"
+		a.log.Debug(insert)
+		a.log.Debug("values: ", values)
+		if err := sqlitex.Exec(conn, insert, nil, values...); err != nil {
+			a.log.Error("Error on insert many: ", err, " - values: ", values)
+			return err
+		}
+		//rows inserted by a single statement receive sequential ids
+		id := conn.LastInsertRowID() - int64(len(rows)) + 1
+		for range rows {
+			result = append(result, records[len(result)].Add(a.idField, id))
+			id++
+		}
+		rows = [][]interface{}{}
+		return nil
+	}
+	for _, record := range records {
+		cols, values := a.insertFields(record)
+		if strings.Join(cols, ",") != strings.Join(columns, ",") || (len(rows)+1)*len(cols) > maxVariables {
+			if err = flush(); err != nil {
+				return nil, err
+			}
+			columns = cols
+		}
+		rows = append(rows, values)
+	}
+	if err = flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// InsertMany inserts all records of the list in a single transaction.
+func (a *Adapter) InsertMany(params moleculer.Payload) moleculer.Payload {
+	if !params.IsArray() {
+		return payload.Error("InsertMany() only support lists!")
+	}
+	resChan := make(chan moleculer.Payload, 1)
+	go func() {
+		defer a.catchConnError("Error on insert many", resChan)
+		conn := a.getConn()
+		if conn == nil {
+			resChan <- noConnectionError()
+			return
+		}
+		defer a.returnConn(conn)
+		result, err := a.insertMany(conn, params.Array())
+		if err != nil {
+			resChan <- payload.New(err)
+			return
+		}
+		resChan <- payload.New(result)
+	}()
+	return <-resChan
+}
+
+// UpdateMany applies params.update to all records matching the params.
+func (a *Adapter) UpdateMany(params moleculer.Payload) moleculer.Payload {
+	update := params.Get("update")
+	if !update.Exists() || !update.IsMap() {
+		return payload.Error("UpdateMany() requires the update param!")
+	}
+	resChan := make(chan moleculer.Payload, 1)
+	go func() {
+		defer a.catchConnError("Error on update many", resChan)
+		conn := a.getConn()
+		if conn == nil {
+			resChan <- noConnectionError()
+			return
+		}
+		defer a.returnConn(conn)
+
+		changes, values, err := a.updatePairs(update)
+		if err != nil {
+			resChan <- payload.New(err)
+			return
+		}
+		where, args, err := a.bulkWhere(params.Remove("update"))
+		if err != nil {
+			resChan <- payload.New(err)
+			return
+		}
+		updtStmt := "UPDATE " + a.Table + " SET " + strings.Join(changes, ", ") + " WHERE " + where + " ;"
+		values = append(values, args...)
+		a.log.Debug(updtStmt, " - values: ", values)
+		if err := sqlitex.Exec(conn, updtStmt, nil, values...); err != nil {
+			a.log.Error("Error on update many: ", err)
+			resChan <- payload.New(err)
+			return
+		}
+		resChan <- payload.Empty().Add("modifiedCount", conn.Changes())
+	}()
+	return <-resChan
+}
+
+// RemoveMany removes the records with params.ids or matching the params.
+func (a *Adapter) RemoveMany(params moleculer.Payload) moleculer.Payload {
+	if params.Get("ids").Exists() {
+		params = payload.New(map[string]interface{}{
+			"query": map[string]interface{}{
+				a.idField: map[string]interface{}{"$in": params.Get("ids").Value()},
+			},
+		})
+	}
+	resChan := make(chan moleculer.Payload, 1)
+	go func() {
+		defer a.catchConnError("Error on remove many", resChan)
+		conn := a.getConn()
+		if conn == nil {
+			resChan <- noConnectionError()
+			return
+		}
+		defer a.returnConn(conn)
+
+		where, args, err := a.bulkWhere(params)
+		if err != nil {
+			resChan <- payload.New(err)
+			return
+		}
+		delete := "DELETE FROM " + a.Table + " WHERE " + where + " ;"
+		a.log.Debug(delete, " - values: ", args)
+		if err := sqlitex.Exec(conn, delete, nil, args...); err != nil {
+			a.log.Error("Error on delete many: ", err)
+			resChan <- payload.New(err)
+			return
+		}
+		resChan <- payload.Empty().Add("deletedCount", conn.Changes())
+	}()
+	return <-resChan
+}
+
 func (a *Adapter) RemoveAll() moleculer.Payload {
 	resChan := make(chan moleculer.Payload, 1)
 	go func() {
@@ -458,9 +754,9 @@ func (a *Adapter) RemoveById(id moleculer.Payload) moleculer.Payload {
 		}
 		defer a.returnConn(conn)
 
-		delete := "DELETE FROM " + a.Table + " WHERE id = " + id.String() + " ;"
-		a.log.Debug(delete)
-		if err := sqlitex.Exec(conn, delete, nil); err != nil {
+		delete := "DELETE FROM " + a.Table + " WHERE " + a.idField + " = ? ;"
+		a.log.Debug(delete, " - id: ", id.Value())
+		if err := sqlitex.Exec(conn, delete, nil, id.Value()); err != nil {
 			a.log.Error("Error on delete: ", err)
 			resChan <- payload.New(err)
 			return
@@ -511,6 +807,38 @@ func (a *Adapter) FindByIds(ids moleculer.Payload) moleculer.Payload {
 	return <-resChan
 }
 
+// FindStream implements store.StreamingAdapter. Each row is sent to the channel while the statement is stepped,
+// so the rows are not loaded in memory. The connection is in use until the channel is closed or ctx is done.
+func (a *Adapter) FindStream(ctx context.Context, param moleculer.Payload) (<-chan moleculer.Payload, error) {
+	fields := a.findFields(param)
+	selec, args, err := a.selectStatement(fields, param)
+	if err != nil {
+		return nil, err
+	}
+	conn := a.getConn()
+	if conn == nil {
+		return nil, noConnectionError().Error()
+	}
+	stream := make(chan moleculer.Payload)
+	go func() {
+		defer close(stream)
+		defer a.returnConn(conn)
+		defer a.catchConnError("Error on find stream", stream)
+		a.log.Trace("stream: ", selec, " - values: ", args)
+		if err := sqlitex.Exec(conn, selec, func(stmt *sqlite.Stmt) error {
+			if !store.SendRecord(ctx, stream, a.rowToPayload(fields, stmt)) {
+				//stops stepping the statement, so the connection is returned
+				return ctx.Err()
+			}
+			return nil
+		}, args...); err != nil && ctx.Err() == nil {
+			a.log.Error("Error on select: ", err)
+			stream <- payload.New(err)
+		}
+	}()
+	return stream, nil
+}
+
 func (a *Adapter) FindOne(params moleculer.Payload) moleculer.Payload {
 	return a.Find(params.Add("limit", 1)).First()
 }
@@ -525,16 +853,158 @@ func (a *Adapter) Count(param moleculer.Payload) moleculer.Payload {
 			return
 		}
 		defer a.returnConn(conn)
-		resChan <- a.query(conn, []string{"COUNT(*) as count"}, param, func(fields []string, stmt *sqlite.Stmt) moleculer.Payload {
-			return payload.New(stmt.GetInt64("count"))
-		}).First()
+		resChan <- a.count(conn, param)
+	}()
+	return <-resChan
+}
+
+//aggregateFunctions maps the aggregate functions to SQL. TOTAL is the SUM that returns 0.0 when there are no values.
+var aggregateFunctions = map[string]string{
+	store.AggCount: "COUNT",
+	store.AggSum:   "TOTAL",
+	store.AggAvg:   "AVG",
+	store.AggMin:   "MIN",
+	store.AggMax:   "MAX",
+}
+
+//aggregateStatement create the SELECT ... GROUP BY statement of the aggregation.
+func (a *Adapter) aggregateStatement(param moleculer.Payload, aggregation store.Aggregation) (string, []interface{}, error) {
+	columns := []string{}
+	for _, field := range aggregation.GroupBy {
+		if !a.validField(field) {
+			return "", nil, errors.New(fmt.Sprint("Invalid groupBy field: ", field))
+		}
+		columns = append(columns, field)
+	}
+	for _, metric := range aggregation.Metrics {
+		expression := "*"
+		if metric.Field != "" {
+			if !a.validField(metric.Field) {
+				return "", nil, errors.New(fmt.Sprint("Invalid metric field: ", metric.Field))
+			}
+			expression = metric.Field
+		}
+		columns = append(columns, aggregateFunctions[metric.Func]+"("+expression+") AS "+metric.Name)
+	}
+	where, args, err := a.findWhere(param)
+	if err != nil {
+		return "", nil, err
+	}
+	selec := "SELECT " + strings.Join(columns, ", ") + " FROM " + a.Table
+	if where != "" {
+		selec = selec + " WHERE " + where
+	}
+	if len(aggregation.GroupBy) > 0 {
+		groups := strings.Join(aggregation.GroupBy, ", ")
+		selec = selec + " GROUP BY " + groups + " ORDER BY " + groups
+	}
+	return selec + " ;", args, nil
+}
+
+//aggregateRow maps the row of the aggregate statement. The columns are the group by fields followed by the metrics.
+func (a *Adapter) aggregateRow(aggregation store.Aggregation, stmt *sqlite.Stmt) moleculer.Payload {
+	data := map[string]interface{}{}
+	for i, field := range aggregation.GroupBy {
+		data[field] = nil
+		if stmt.ColumnType(i) != sqlite.SQLITE_NULL {
+			data[field] = a.transformOut(field, a.columnValue(field, stmt))
+		}
+	}
+	for j, metric := range aggregation.Metrics {
+		i := len(aggregation.GroupBy) + j
+		switch {
+		case stmt.ColumnType(i) == sqlite.SQLITE_NULL:
+			data[metric.Name] = nil
+		case metric.Func == store.AggCount:
+			data[metric.Name] = stmt.ColumnInt64(i)
+		case metric.Func == store.AggSum || metric.Func == store.AggAvg:
+			data[metric.Name] = stmt.ColumnFloat(i)
+		case a.columnType(metric.Field) == "INTEGER":
+			data[metric.Name] = stmt.ColumnInt64(i)
+		case a.columnType(metric.Field) == "NUMBER" || a.columnType(metric.Field) == "REAL":
+			data[metric.Name] = stmt.ColumnFloat(i)
+		default:
+			data[metric.Name] = a.transformOut(metric.Field, stmt.ColumnText(i))
+		}
+	}
+	return payload.New(data)
+}
+
+// Aggregate implements store.AggregateAdapter with a GROUP BY statement.
+func (a *Adapter) Aggregate(param moleculer.Payload, aggregation store.Aggregation) moleculer.Payload {
+	selec, args, err := a.aggregateStatement(param, aggregation)
+	if err != nil {
+		return payload.New(err)
+	}
+	resChan := make(chan moleculer.Payload, 1)
+	go func() {
+		defer a.catchConnError("Error on aggregate ", resChan)
+		conn := a.getConn()
+		if conn == nil {
+			resChan <- noConnectionError()
+			return
+		}
+		defer a.returnConn(conn)
+		rows := []moleculer.Payload{}
+		a.log.Trace(selec, " - values: ", args)
+		if err := sqlitex.Exec(conn, selec, func(stmt *sqlite.Stmt) error {
+			rows = append(rows, a.aggregateRow(aggregation, stmt))
+			return nil
+		}, args...); err != nil {
+			a.log.Error("Error on aggregate: ", err)
+			resChan <- payload.New(err)
+			return
+		}
+		resChan <- payload.New(rows)
+	}()
+	return <-resChan
+}
+
+// Distinct implements store.DistinctAdapter with a SELECT DISTINCT statement. Null values are not returned.
+func (a *Adapter) Distinct(param moleculer.Payload, field string) moleculer.Payload {
+	if !a.validField(field) {
+		return payload.New(errors.New(fmt.Sprint("Invalid distinct field: ", field)))
+	}
+	where, args, err := a.findWhere(param)
+	if err != nil {
+		return payload.New(err)
+	}
+	selec := "SELECT DISTINCT " + field + " FROM " + a.Table + " WHERE " + field + " IS NOT NULL"
+	if where != "" {
+		selec = selec + " AND (" + where + ")"
+	}
+	selec = selec + " ORDER BY " + field + " ;"
+	resChan := make(chan moleculer.Payload, 1)
+	go func() {
+		defer a.catchConnError("Error on distinct ", resChan)
+		conn := a.getConn()
+		if conn == nil {
+			resChan <- noConnectionError()
+			return
+		}
+		defer a.returnConn(conn)
+		values := []interface{}{}
+		a.log.Trace(selec, " - values: ", args)
+		if err := sqlitex.Exec(conn, selec, func(stmt *sqlite.Stmt) error {
+			values = append(values, a.transformOut(field, a.columnValue(field, stmt)))
+			return nil
+		}, args...); err != nil {
+			a.log.Error("Error on distinct: ", err)
+			resChan <- payload.New(err)
+			return
+		}
+		resChan <- payload.New(values)
 	}()
 	return <-resChan
 }
 
 func (a *Adapter) updateById(conn *sqlite.Conn, id, update moleculer.Payload) error {
-	changes, values := a.updatePairs(update)
-	updtStmt := "UPDATE " + a.Table + " SET " + strings.Join(changes, ", ") + " WHERE id=" + id.String() + ";"
+	changes, values, err := a.updatePairs(update)
+	if err != nil {
+		return err
+	}
+	updtStmt := "UPDATE " + a.Table + " SET " + strings.Join(changes, ", ") + " WHERE " + a.idField + " = ?;"
+	values = append(values, id.Value())
 	a.log.Debug(updtStmt, " - values: ", values)
 	if err := sqlitex.Exec(conn, updtStmt, nil, values...); err != nil {
 		a.log.Error("Error on update: ", err)
@@ -552,54 +1022,38 @@ func (a *Adapter) findById(conn *sqlite.Conn, id moleculer.Payload) moleculer.Pa
 	return a.query(conn, a.findFields(filter), filter, a.rowToPayload).First()
 }
 
-func resolveFindOptions(param moleculer.Payload) (limit, offset string, sort []string) {
-	if param.Get("limit").Exists() {
-		limit = param.Get("limit").String()
+//resolveSort return the ORDER BY entries of the sort param. Only columns and the idField are accepted.
+func (a *Adapter) resolveSort(param moleculer.Payload) ([]string, error) {
+	entries := []string{}
+	sort := param.Get("sort")
+	if !sort.Exists() {
+		return entries, nil
 	}
-	if param.Get("offset").Exists() {
-		offset = param.Get("offset").String()
+	if sort.IsArray() {
+		entries = sort.StringArray()
+	} else {
+		entries = strings.Split(strings.TrimSpace(sort.String()), " ")
 	}
-	if param.Get("sort").Exists() {
-		if param.Get("sort").IsArray() {
-			sort = sortsFromStringArray(param.Get("sort"))
-		} else {
-			sort = sortsFromString(param.Get("sort"))
+	sorts := []string{}
+	for _, entry := range entries {
+		if entry == "" {
+			continue
 		}
-	}
-	return limit, offset, sort
-}
-
-func sortsFromString(sort moleculer.Payload) []string {
-	parts := strings.Split(strings.Trim(sort.String(), " "), " ")
-	if len(parts) > 1 {
-		sorts := []string{}
-		for _, value := range parts {
-			sorts = append(sorts, sortEntry(value))
+		field, direction := sortEntry(entry)
+		if !a.validField(field) {
+			return nil, errors.New(fmt.Sprint("Invalid sort field: ", field))
 		}
-		return sorts
-	} else if len(parts) == 1 && parts[0] != "" {
-		return []string{sortEntry(parts[0])}
+		sorts = append(sorts, field+" "+direction)
 	}
-	fmt.Println("**** invalid Sort Entry **** ")
-	return []string{}
-}
-
-func sortsFromStringArray(sort moleculer.Payload) []string {
-	sorts := []string{}
-	sort.ForEach(func(index interface{}, value moleculer.Payload) bool {
-		sorts = append(sorts, sortEntry(value.String()))
-		return true
-	})
-	return sorts
+	return sorts, nil
 }
 
-func sortEntry(entry string) string {
+//sortEntry return the field and the direction of the sort entry: -field -> field DESC
+func sortEntry(entry string) (field, direction string) {
 	if strings.Index(entry, "-") == 0 {
-		entry = strings.Replace(entry, "-", "", 1) + " DESC"
-	} else {
-		entry = strings.Replace(entry, "-", "", 1) + " ASC"
+		return strings.Replace(entry, "-", "", 1), "DESC"
 	}
-	return entry
+	return entry, "ASC"
 }
 
 //findFields take the default fields from service settings.
@@ -656,31 +1110,89 @@ func (a *Adapter) cleanFields(fields []string) []string {
 
 type rowFactory func([]string, *sqlite.Stmt) moleculer.Payload
 
-func (a *Adapter) query(conn *sqlite.Conn, fields []string, param moleculer.Payload, mapRow rowFactory) moleculer.Payload {
-	limit, offset, sort := resolveFindOptions(param)
+//selectStatement create the SELECT statement of the fields with the where, sort, limit and offset of the params.
+//Without sort the full-text search results are sorted by rank.
+func (a *Adapter) selectStatement(fields []string, param moleculer.Payload) (string, []interface{}, error) {
+	sort, err := a.resolveSort(param)
+	if err != nil {
+		return "", nil, err
+	}
+	join, rank, args := "", "", []interface{}{}
+	whereParams := param
+	if len(sort) == 0 {
+		join, rank, args, err = a.searchJoin(param)
+		if err != nil {
+			return "", nil, err
+		}
+		if join != "" {
+			//the join already matches the search
+			whereParams = param.Remove("search", "searchFields")
+			sort = append(sort, rank)
+		}
+	}
+	where, whereArgs, err := a.findWhere(whereParams)
+	if err != nil {
+		return "", nil, err
+	}
+	args = append(args, whereArgs...)
 
-	rows := []moleculer.Payload{}
-	where := a.findWhere(param)
-	selec := "SELECT " + strings.Join(fields, ", ") + " FROM " + a.Table
+	selec := "SELECT " + strings.Join(fields, ", ") + " FROM " + a.Table + join
 	if where != "" {
 		selec = selec + " WHERE " + where
 	}
 	if len(sort) > 0 {
 		selec = selec + " ORDER BY " + strings.Join(sort, ", ")
 	}
-	if limit != "" {
-		selec = selec + " LIMIT " + limit
+	limit, offset := param.Get("limit"), param.Get("offset")
+	if limit.Exists() {
+		selec = selec + " LIMIT ?"
+		args = append(args, limit.Int64())
+	} else if offset.Exists() {
+		//SQLite only accepts OFFSET after a LIMIT clause
+		selec = selec + " LIMIT -1"
 	}
-	if offset != "" {
-		selec = selec + " OFFSET " + offset
+	if offset.Exists() {
+		selec = selec + " OFFSET ?"
+		args = append(args, offset.Int64())
 	}
 	selec = selec + " ;"
+	return selec, args, nil
+}
+
+//count return the number of records matching the where of the params, without sort and rank.
+func (a *Adapter) count(conn *sqlite.Conn, param moleculer.Payload) moleculer.Payload {
+	where, args, err := a.findWhere(param)
+	if err != nil {
+		return payload.New(err)
+	}
+	selec := "SELECT COUNT(*) as count FROM " + a.Table
+	if where != "" {
+		selec = selec + " WHERE " + where
+	}
+	var count int64
+	a.log.Trace(selec, " - values: ", args)
+	if err := sqlitex.Exec(conn, selec+" ;", func(stmt *sqlite.Stmt) error {
+		count = stmt.GetInt64("count")
+		return nil
+	}, args...); err != nil {
+		a.log.Error("Error on count: ", err)
+		return payload.New(err)
+	}
+	return payload.New(count)
+}
+
+func (a *Adapter) query(conn *sqlite.Conn, fields []string, param moleculer.Payload, mapRow rowFactory) moleculer.Payload {
+	selec, args, err := a.selectStatement(fields, param)
+	if err != nil {
+		return payload.New(err)
+	}
 
-	a.log.Trace(selec)
+	rows := []moleculer.Payload{}
+	a.log.Trace(selec, " - values: ", args)
 	if err := sqlitex.Exec(conn, selec, func(stmt *sqlite.Stmt) error {
 		rows = append(rows, mapRow(fields, stmt))
 		return nil
-	}); err != nil {
+	}, args...); err != nil {
 		a.log.Error("Error on select: ", err)
 		return payload.New(err)
 	}
@@ -690,6 +1202,10 @@ func (a *Adapter) query(conn *sqlite.Conn, fields []string, param moleculer.Payl
 
 func (a *Adapter) columnValue(column string, stmt *sqlite.Stmt) interface{} {
 	t := a.columnType(column)
+	if column == a.idField {
+		//the id is an INTEGER PRIMARY KEY, read as the int64 returned by Insert so encodeID gives the same hash
+		t = "INTEGER"
+	}
 	if t == "NUMBER" {
 		return stmt.GetFloat(column)
 	}
@@ -774,7 +1290,7 @@ func (a *Adapter) transformOut(field string, value interface{}) interface{} {
 			return nil
 		}
 		if a.idColumn == nil {
-			a.idColumn = &Column{a.idField, "string"}
+			a.idColumn = &Column{Name: a.idField, Type: "string"}
 		}
 		c = a.idColumn
 	}
@@ -847,96 +1363,106 @@ func (a *Adapter) columnType(field string) (r string) {
 	return r
 }
 
-//betweenValues prepare the values for the operator "between" sql stmt -> between  A and B
-func (a *Adapter) betweenValues(field string, values moleculer.Payload) (r string) {
-	pair := values.Array()
-	return a.wrapValue(field, pair[0]) + " AND " + a.wrapValue(field, pair[1])
-}
-
-//inValues prepare the values for the operator "in" sql stmt -> in (A, B, C)
-func (a *Adapter) inValues(field string, values moleculer.Payload) (r string) {
-	items := []string{}
-	values.ForEach(func(key interface{}, item moleculer.Payload) bool {
-		items = append(items, a.wrapValue(field, item))
-		return true
-	})
-	return "(" + strings.Join(items, ",") + ")"
+//comparisons maps the filter operators to the SQL ones.
+var comparisons = map[string]string{
+	store.OpEq:  "=",
+	store.OpGt:  ">",
+	store.OpGte: ">=",
+	store.OpLt:  "<",
+	store.OpLte: "<=",
 }
 
-func (a *Adapter) orValues(values moleculer.Payload) (r string) {
-	pairs := []string{}
-	values.ForEach(func(idx interface{}, query moleculer.Payload) bool {
-		for _, pair := range a.filterPairs(query) {
-			pairs = append(pairs, pair)
+//filterWhere translate the query filter to a where clause with placeholders and the values to bind.
+func (a *Adapter) filterWhere(filter store.Filter) (string, []interface{}, error) {
+	switch filter.Op {
+	case store.OpAnd, store.OpOr:
+		return a.groupWhere(filter)
+	case store.OpNot:
+		where, args, err := a.groupWhere(store.Filter{Op: store.OpAnd, Filters: filter.Filters})
+		if err != nil || where == "" {
+			return where, args, err
 		}
-		return true
-	})
-	return strings.Join(pairs, " OR ")
-}
-
-//expressionValue when the filter clause is an expression, this function will
-//return the operator and the values formated for SQLStmts
-func (a *Adapter) expressionValue(field string, expression moleculer.Payload) (rField, value, operation string) {
-	rField = field
-	if strings.ToLower(field) == "or" {
-		rField = ""
-		value = a.orValues(expression)
-	} else {
-		expression.ForEach(func(key interface{}, item moleculer.Payload) bool {
-			operation = strings.ToLower(key.(string))
-			if operation == "between" || operation == "not between" {
-				value = a.betweenValues(field, item)
-			} else if operation == "in" || operation == "not in" {
-				value = a.inValues(field, item)
-			} else {
-				value = a.wrapValue(field, item)
-			}
-			return false
-		})
+		return "NOT " + where, args, nil
+	}
+	field := filter.Field
+	if !a.validField(field) {
+		return "", nil, errors.New(fmt.Sprint("Invalid field: ", field))
 	}
-	return rField, value, operation
+	switch filter.Op {
+	case store.OpExists:
+		if filter.Value.(bool) {
+			return field + " IS NOT NULL", nil, nil
+		}
+		return field + " IS NULL", nil, nil
+	case store.OpNe:
+		return field + " <> ?", []interface{}{a.bindValue(field, payload.New(filter.Value))}, nil
+	case store.OpIn, store.OpNin:
+		items := []string{}
+		args := []interface{}{}
+		for _, value := range filter.Values() {
+			items = append(items, "?")
+			args = append(args, a.bindValue(field, payload.New(value)))
+		}
+		if filter.Op == store.OpNin {
+			return field + " NOT IN (" + strings.Join(items, ",") + ")", args, nil
+		}
+		return field + " IN (" + strings.Join(items, ",") + ")", args, nil
+	case store.OpBetween:
+		values := filter.Values()
+		return field + " BETWEEN ? AND ?", []interface{}{
+			a.bindValue(field, payload.New(values[0])), a.bindValue(field, payload.New(values[1])),
+		}, nil
+	case store.OpLike:
+		return field + " LIKE ?", []interface{}{filter.Value}, nil
+	}
+	operator, valid := comparisons[filter.Op]
+	if !valid {
+		return "", nil, errors.New(fmt.Sprint("Invalid operator: ", filter.Op))
+	}
+	return field + " " + operator + " ?", []interface{}{a.bindValue(field, payload.New(filter.Value))}, nil
 }
 
-//valueAndOperator return the value properly formated for SQL Stmt and the operator to be used in the where clause.
-func (a *Adapter) valueAndOperator(field string, expression moleculer.Payload) (pair string) {
-	operation := "="
-	value := ""
-	if expression.IsMap() || expression.IsArray() {
-		field, value, operation = a.expressionValue(field, expression)
-	} else {
-		value = a.wrapValue(field, expression)
+//groupWhere translate the children of and/or filters: (A AND B) or (A OR B)
+func (a *Adapter) groupWhere(filter store.Filter) (string, []interface{}, error) {
+	separator := " AND "
+	if filter.Op == store.OpOr {
+		separator = " OR "
+	}
+	pairs := []string{}
+	args := []interface{}{}
+	for _, child := range filter.Filters {
+		where, childArgs, err := a.filterWhere(child)
+		if err != nil {
+			return "", nil, err
+		}
+		if where != "" {
+			pairs = append(pairs, where)
+			args = append(args, childArgs...)
+		}
 	}
-	if a.isExpression(expression.String()) {
-		operation = " "
+	if len(pairs) == 0 {
+		return "", nil, nil
 	}
-	return strings.TrimSpace(field + " " + operation + " " + value)
+	return "(" + strings.Join(pairs, separator) + ")", args, nil
 }
 
-func (a *Adapter) isExpression(value string) bool {
-	value = strings.ToUpper(value)
-	return value == "IS NOT NULL" || value == "IS NULL"
-}
-
-func (a *Adapter) wrapValue(field string, value moleculer.Payload) (r string) {
-	if a.isExpression(value.String()) {
-		return strings.ToUpper(value.String())
-	}
-
+//bindValue convert the value to the type of the column.
+func (a *Adapter) bindValue(field string, value moleculer.Payload) interface{} {
 	cType := a.columnType(field)
 	if cType == "TEXT" && isTime(value) {
 		t, _ := value.Value().(time.Time)
-		return "'" + t.UTC().Format(ISO8601) + "'"
+		return t.UTC().Format(ISO8601)
 	}
 	if cType == "TEXT" || cType == "" {
-		return "'" + value.String() + "'"
+		return value.String()
 	}
-	if cType == "NUMBER" {
-		return "'" + strconv.FormatFloat(value.Float(), 'f', 6, 64) + "'"
+	if cType == "NUMBER" || cType == "REAL" {
+		return value.Float()
 	}
 	if cType == "INTEGER" {
-		return fmt.Sprint(value.Int64())
+		return value.Int64()
 	}
-	return r
+	return value.Value()
 }
 
 func isTime(p moleculer.Payload) bool {
@@ -944,70 +1470,78 @@ func isTime(p moleculer.Payload) bool {
 	return valid
 }
 
-//filterPairs create the where clause filter pairs: example. userName = 'John'
-//uses a mongo-esq style for advanced filters, examples:
-// "query": M{
-// 	"age": M{
-// 		">": 60,
-// 	},
-// },
-//will result in:
-// where age > 60
-func (a *Adapter) filterPairs(query moleculer.Payload) (pairs []string) {
-	query.ForEach(func(key interface{}, item moleculer.Payload) bool {
-		field := key.(string)
-		pair := a.valueAndOperator(field, item)
-		pairs = append(pairs, pair)
-		return true
-	})
-	return pairs
-}
-
-func (a *Adapter) updateWhere(params moleculer.Payload) string {
-	where := ""
-	queryPairs := a.filterPairs(params)
-	if len(queryPairs) > 0 {
-		where = strings.Join(queryPairs, " AND ")
-	}
-	return where
-}
-
-func (a *Adapter) findWhere(params moleculer.Payload) string {
+//findWhere return the where clause for the query and search params and the values to bind.
+func (a *Adapter) findWhere(params moleculer.Payload) (string, []interface{}, error) {
 	query := payload.Empty()
 	if params.Get("query").Exists() {
 		query = params.Get("query")
 	}
-	where := ""
-	queryPairs := a.filterPairs(query)
-	if len(queryPairs) > 0 {
-		where = strings.Join(queryPairs, " AND ")
+	filter, err := store.ParseQuery(query)
+	if err != nil {
+		return "", nil, err
+	}
+	where, args, err := a.filterWhere(filter)
+	if err != nil {
+		return "", nil, err
+	}
+	searchPairs, searchArgs, err := a.parseSearchFields(params)
+	if err != nil {
+		return "", nil, err
 	}
-	searchPairs := a.parseSearchFields(params)
 	if len(searchPairs) > 0 {
 		if where != "" {
 			where = where + " AND "
 		}
 		where = where + "(" + strings.Join(searchPairs, " OR ") + ")"
+		args = append(args, searchArgs...)
+	}
+	return where, args, nil
+}
+
+// bulkWhere return the condition of UpdateMany and RemoveMany. A filter that matches all the records, like an
+// empty query or a search without search fields, is an error, so a bad request can not change the whole table.
+func (a *Adapter) bulkWhere(params moleculer.Payload) (string, []interface{}, error) {
+	if params.Get("search").Exists() {
+		pairs, _, err := a.parseSearchFields(params)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(pairs) == 0 {
+			return "", nil, errors.New("The search requires searchFields or Search columns to change many records.")
+		}
 	}
-	return where
+	where, args, err := a.findWhere(params)
+	if err == nil && where == "" {
+		err = errors.New("A query or search filter is required to change many records.")
+	}
+	return where, args, err
 }
 
-func (a *Adapter) parseSearchFields(params moleculer.Payload) (pairs []string) {
+// parseSearchFields return the condition of the search param. With Search columns it is a full-text search,
+// otherwise the searchFields are compared with the search value.
+func (a *Adapter) parseSearchFields(params moleculer.Payload) (pairs []string, args []interface{}, err error) {
+	if len(a.searchColumns()) > 0 {
+		return a.searchWhere(params)
+	}
 	searchFields := params.Get("searchFields")
 	search := params.Get("search")
 	searchValue := ""
 	if search.Exists() {
 		searchValue = search.String()
 	}
-	if searchFields.Exists() {
-		if searchFields.IsArray() {
-			fields := searchFields.StringArray()
-			for _, field := range fields {
-				pairs = append(pairs, field+" = "+"'"+searchValue+"'")
-			}
-		} else {
-			pairs = []string{searchFields.String() + " = " + "'" + searchValue + "'"}
+	if !searchFields.Exists() {
+		return pairs, args, nil
+	}
+	fields := []string{searchFields.String()}
+	if searchFields.IsArray() {
+		fields = searchFields.StringArray()
+	}
+	for _, field := range fields {
+		if !a.validField(field) {
+			return nil, nil, errors.New(fmt.Sprint("Invalid search field: ", field))
 		}
+		pairs = append(pairs, field+" = ?")
+		args = append(args, searchValue)
 	}
-	return pairs
+	return pairs, args, nil
 }
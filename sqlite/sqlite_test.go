@@ -1,7 +1,12 @@
 package sqlite
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"crawshaw.io/sqlite"
@@ -10,6 +15,8 @@ import (
 	"github.com/moleculer-go/moleculer"
 
 	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/storetest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
@@ -36,6 +43,10 @@ func countTable(adapter *Adapter, table string) int {
 	return count
 }
 
+var _ store.StreamingAdapter = &Adapter{}
+var _ store.AggregateAdapter = &Adapter{}
+var _ store.DistinctAdapter = &Adapter{}
+
 var _ = Describe("Sqlite", func() {
 
 	logLevel := log.ErrorLevel
@@ -194,6 +205,8 @@ var _ = Describe("Sqlite", func() {
 			r := adapter.FindById(payload.New(1))
 			Expect(r).ShouldNot(BeNil())
 			Expect(r.Get("id").Int()).Should(Equal(1))
+			//the same type returned by Insert, so encodeID gives the same hash
+			Expect(r.Get("id").Value()).Should(Equal(int64(1)))
 			Expect(r.Get("name").String()).Should(Equal("Marie"))
 			Expect(r.Get("email").String()).Should(Equal("marie@jane.com"))
 			Expect(r.Get("number").Float()).Should(Equal(float64(5.44444)))
@@ -682,6 +695,65 @@ var _ = Describe("Sqlite", func() {
 			Expect(r.Len()).Should(Equal(1))
 		})
 
+		It("should bind the values instead of concatenating them", func() {
+			r := adapter.Find(payload.New(M{"query": M{"name": "O'Connor"}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(0))
+
+			r = adapter.Find(payload.New(M{"query": M{"name": "x' OR '1'='1"}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(0))
+
+			r = adapter.Find(payload.New(M{"search": "x' OR '1'='1", "searchFields": []string{"name"}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(0))
+
+			r = adapter.Find(payload.New(M{"query": M{"name": M{"in": []string{"Mario", "x') OR ('1'='1"}}}}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(1))
+
+			r = adapter.Find(payload.New(M{"limit": 2, "offset": 1, "sort": "name"}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(2))
+			Expect(r.First().Get("name").String()).Should(Equal("Connor"))
+
+			r = adapter.RemoveById(payload.New("1 OR 1=1"))
+			Expect(r.Get("deletedCount").Int()).Should(Equal(0))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+		})
+
+		It("should reject invalid field names and operators", func() {
+			r := adapter.Find(payload.New(M{"sort": "name;DROP TABLE advancedFilters"}))
+			Expect(r.IsError()).Should(BeTrue())
+
+			r = adapter.Find(payload.New(M{"sort": []string{"name DESC, 1"}}))
+			Expect(r.IsError()).Should(BeTrue())
+
+			r = adapter.Find(payload.New(M{"query": M{"1=1 OR name": "x"}}))
+			Expect(r.IsError()).Should(BeTrue())
+
+			r = adapter.Find(payload.New(M{"search": "x", "searchFields": []string{"name = name OR 1"}}))
+			Expect(r.IsError()).Should(BeTrue())
+
+			r = adapter.Find(payload.New(M{"query": M{"age": M{"> 0 OR age <": 10}}}))
+			Expect(r.IsError()).Should(BeTrue())
+
+			r = adapter.UpdateMany(payload.New(M{"query": M{"name": "Mario"}, "update": M{"age = 0, name": "x"}}))
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+		})
+
+		It("should not update or remove all the records with a search without searchFields", func() {
+			r := adapter.RemoveMany(payload.New(M{"search": "Mario"}))
+			Expect(r.IsError()).Should(BeTrue())
+			r = adapter.UpdateMany(payload.New(M{"search": "Mario", "update": M{"age": 0}}))
+			Expect(r.IsError()).Should(BeTrue())
+			r = adapter.RemoveMany(payload.New(M{"query": M{}}))
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(6))
+			Expect(adapter.Count(payload.New(M{"query": M{"age": 0}})).Int()).Should(Equal(0))
+		})
+
 	})
 
 	Describe("Date and Datetime", func() {
@@ -775,5 +847,531 @@ var _ = Describe("Sqlite", func() {
 			Expect(r.Array()[0].Get("title").String()).Should(Equal("day after tomorrow"))
 
 		})
+
+		It("should update a datetime to null and filter by null", func() {
+			today := adapter.Find(payload.New(M{"query": M{"title": "today"}})).First()
+			r := adapter.UpdateById(today.Get("id"), payload.New(M{"updated": nil}))
+			Expect(r.Error()).Should(Succeed())
+
+			r = adapter.Find(payload.New(M{"query": M{"updated": nil}}))
+			Expect(r.Error()).Should(Succeed())
+			Expect(r.Len()).Should(Equal(1))
+			Expect(r.First().Get("title").String()).Should(Equal("today"))
+
+			r = adapter.Find(payload.New(M{"query": M{"updated": M{"$ne": nil}}}))
+			Expect(r.Error()).Should(Succeed())
+			Expect(r.Len()).Should(Equal(2))
+
+			r = adapter.UpdateById(today.Get("id"), payload.New(M{"updated": time.Now()}))
+			Expect(r.Error()).Should(Succeed())
+			r = adapter.Find(payload.New(M{"query": M{"updated": nil}}))
+			Expect(r.Len()).Should(Equal(0))
+		})
+	})
+
+	Describe("Versioned update", func() {
+		var adapter Adapter
+		BeforeEach(func() {
+			adapter = Adapter{
+				URI:      "file:memory:?mode=memory",
+				Flags:    0,
+				PoolSize: 1,
+				Table:    "versioned",
+				Columns: []Column{
+					{
+						Name: "title",
+						Type: "string",
+					},
+					{
+						Name: "version",
+						Type: "integer",
+					},
+				},
+			}
+			log.SetLevel(logLevel)
+			adapter.Init(log.WithField("", ""), M{})
+			adapter.Connect()
+		})
+
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should update only when the version matches", func() {
+			r := adapter.Insert(payload.New(M{"title": "draft", "version": 1}))
+			Expect(r.Error()).Should(Succeed())
+			id := r.Get("id")
+
+			r = adapter.UpdateByIdVersion(id, "version", 1, payload.New(M{"title": "final", "version": 2}))
+			Expect(r.Error()).Should(Succeed())
+			Expect(r.Get("title").String()).Should(Equal("final"))
+			Expect(r.Get("version").Int()).Should(Equal(2))
+
+			r = adapter.UpdateByIdVersion(id, "version", 1, payload.New(M{"title": "stale", "version": 2}))
+			Expect(r.Error()).Should(Succeed())
+			Expect(r.Exists()).Should(BeFalse())
+			Expect(adapter.FindById(id).Get("title").String()).Should(Equal("final"))
+		})
+	})
+
+	Describe("Find stream", func() {
+		var adapter Adapter
+		BeforeEach(func() {
+			adapter = Adapter{
+				URI:      "file:memory:?mode=memory",
+				Flags:    0,
+				PoolSize: 1,
+				Table:    "streamed",
+				Columns: []Column{
+					{
+						Name: "name",
+						Type: "string",
+					},
+					{
+						Name: "position",
+						Type: "integer",
+					},
+				},
+			}
+			log.SetLevel(logLevel)
+			adapter.Init(log.WithField("", ""), M{})
+			adapter.Connect()
+			for i := 1; i <= 5; i++ {
+				adapter.Insert(payload.New(M{"name": fmt.Sprint("item ", i), "position": i}))
+			}
+		})
+
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("should send the matching rows to the channel and return the connection", func() {
+			stream, err := adapter.FindStream(context.Background(), payload.New(M{
+				"query": M{"position": M{"$gt": 2}},
+				"sort":  "-position",
+			}))
+			Expect(err).Should(Succeed())
+			positions := []int{}
+			for row := range stream {
+				Expect(row.Error()).Should(Succeed())
+				positions = append(positions, row.Get("position").Int())
+			}
+			Expect(positions).Should(Equal([]int{5, 4, 3}))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(5))
+		})
+
+		It("should stop stepping the statement and return the connection when the context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			stream, err := adapter.FindStream(ctx, payload.New(M{"sort": "position"}))
+			Expect(err).Should(Succeed())
+			Expect((<-stream).Get("position").Int()).Should(Equal(1))
+			cancel()
+			//the pool has one connection, count waits until the stream returns it
+			count := make(chan int)
+			go func() {
+				count <- adapter.Count(payload.Empty()).Int()
+			}()
+			Eventually(count, 2*time.Second).Should(Receive(Equal(5)))
+			for range stream {
+			}
+		})
+
+		It("should return an error for an invalid query", func() {
+			_, err := adapter.FindStream(context.Background(), payload.New(M{"sort": "invalid"}))
+			Expect(err).ShouldNot(Succeed())
+		})
 	})
+
+	Describe("Migrations", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "sqlite-migrations")
+			must(err)
+		})
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		createAdapter := func(columns []Column, migrations []Migration) *Adapter {
+			adapter := &Adapter{
+				URI:        "file:" + filepath.Join(dir, "store.db"),
+				Table:      "orders",
+				Columns:    columns,
+				Migrations: migrations,
+			}
+			adapter.Init(log.WithField("", ""), M{})
+			return adapter
+		}
+
+		It("should add the new columns to an existing table", func() {
+			adapter := createAdapter([]Column{{Name: "code", Type: "string"}}, nil)
+			Expect(adapter.Connect()).Should(Succeed())
+			Expect(adapter.Insert(payload.New(M{"code": "A1"})).Error()).Should(BeNil())
+			Expect(adapter.Disconnect()).Should(Succeed())
+
+			adapter = createAdapter([]Column{{Name: "code", Type: "string"}, {Name: "amount", Type: "integer"}}, nil)
+			Expect(adapter.Connect()).Should(Succeed())
+			defer adapter.Disconnect()
+			Expect(adapter.Insert(payload.New(M{"code": "B2", "amount": 10})).Error()).Should(BeNil())
+			r := adapter.Find(payload.New(M{"sort": "code"}))
+			Expect(r.Error()).Should(BeNil())
+			Expect(r.Len()).Should(Equal(2))
+			Expect(r.First().Get("code").String()).Should(Equal("A1"))
+			Expect(r.Array()[1].Get("amount").Int()).Should(Equal(10))
+		})
+
+		It("should apply the pending migrations once and record them", func() {
+			columns := []Column{{Name: "code", Type: "string"}, {Name: "status", Type: "string"}}
+			calls := 0
+			migrations := []Migration{
+				{
+					Version: 2,
+					Up: func(conn *sqlite.Conn) error {
+						calls++
+						return sqlitex.Exec(conn, "UPDATE orders SET status = 'open' WHERE status IS NULL;", nil)
+					},
+				},
+				{
+					Version:     1,
+					Description: "first order",
+					UpSQL:       "INSERT INTO orders (code) VALUES ('A1'); INSERT INTO orders (code) VALUES ('A2');",
+					DownSQL:     "DELETE FROM orders WHERE code IN ('A1', 'A2');",
+				},
+			}
+			adapter := createAdapter(columns, migrations)
+			Expect(adapter.Connect()).Should(Succeed())
+			Expect(adapter.Count(payload.New(M{"query": M{"status": "open"}})).Int()).Should(Equal(2))
+			Expect(adapter.MigrationVersion()).Should(Equal(2))
+			Expect(adapter.Disconnect()).Should(Succeed())
+
+			adapter = createAdapter(columns, migrations)
+			Expect(adapter.Connect()).Should(Succeed())
+			defer adapter.Disconnect()
+			Expect(calls).Should(Equal(1))
+			Expect(countTable(adapter, "orders")).Should(Equal(2))
+			Expect(countTable(adapter, MigrationsTable)).Should(Equal(2))
+		})
+
+		It("should roll back a failed migration and not record it", func() {
+			columns := []Column{{Name: "code", Type: "string"}}
+			adapter := createAdapter(columns, []Migration{
+				{Version: 1, UpSQL: "INSERT INTO orders (code) VALUES ('A1'); INSERT INTO missing (code) VALUES ('A2');"},
+			})
+			Expect(adapter.Connect()).ShouldNot(Succeed())
+			adapter.pool.Close()
+
+			adapter = createAdapter(columns, nil)
+			Expect(adapter.Connect()).Should(Succeed())
+			defer adapter.Disconnect()
+			Expect(countTable(adapter, "orders")).Should(Equal(0))
+			Expect(adapter.MigrationVersion()).Should(Equal(0))
+		})
+
+		It("MigrateDown should revert the migrations after the version", func() {
+			adapter := createAdapter([]Column{{Name: "code", Type: "string"}}, []Migration{
+				{Version: 1, UpSQL: "INSERT INTO orders (code) VALUES ('A1');", DownSQL: "DELETE FROM orders WHERE code = 'A1';"},
+				{Version: 2, UpSQL: "INSERT INTO orders (code) VALUES ('A2');", DownSQL: "DELETE FROM orders WHERE code = 'A2';"},
+				{Version: 3, UpSQL: "INSERT INTO orders (code) VALUES ('A3');"},
+			})
+			Expect(adapter.Connect()).Should(Succeed())
+			defer adapter.Disconnect()
+			Expect(countTable(adapter, "orders")).Should(Equal(3))
+
+			Expect(adapter.MigrateDown(1)).ShouldNot(Succeed())
+			adapter.Migrations[2].DownSQL = "DELETE FROM orders WHERE code = 'A3';"
+			Expect(adapter.MigrateDown(1)).Should(Succeed())
+			Expect(adapter.Find(payload.Empty()).First().Get("code").String()).Should(Equal("A1"))
+			Expect(adapter.MigrationVersion()).Should(Equal(1))
+		})
+
+		It("should return an error for duplicated versions", func() {
+			adapter := createAdapter([]Column{{Name: "code", Type: "string"}}, []Migration{
+				{Version: 1, UpSQL: "SELECT 1;"},
+				{Version: 1, UpSQL: "SELECT 2;"},
+			})
+			Expect(adapter.Connect()).ShouldNot(Succeed())
+			adapter.pool.Close()
+		})
+	})
+
+	Describe("Indexes and constraints", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "sqlite-indexes")
+			must(err)
+		})
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		createAdapter := func(columns []Column, indexes []Index) *Adapter {
+			adapter := &Adapter{
+				URI:     "file:" + filepath.Join(dir, "store.db"),
+				Table:   "orders",
+				Columns: columns,
+				Indexes: indexes,
+			}
+			adapter.Init(log.WithField("", ""), M{})
+			return adapter
+		}
+
+		tableIndexes := func(adapter *Adapter) map[string]string {
+			conn := adapter.getConn()
+			defer adapter.returnConn(conn)
+			indexes, err := adapter.tableIndexes(conn)
+			must(err)
+			return indexes
+		}
+
+		queryPlan := func(adapter *Adapter, selec string) string {
+			conn := adapter.getConn()
+			defer adapter.returnConn(conn)
+			plan := ""
+			must(sqlitex.Exec(conn, "EXPLAIN QUERY PLAN "+selec, func(stmt *sqlite.Stmt) error {
+				plan = plan + stmt.GetText("detail")
+				return nil
+			}))
+			return plan
+		}
+
+		It("should apply the NOT NULL, DEFAULT and UNIQUE declarations", func() {
+			adapter := createAdapter([]Column{
+				{Name: "code", Type: "string", Unique: true, NotNull: true},
+				{Name: "status", Type: "string", Default: "open"},
+				{Name: "paid", Type: "bool", Default: false},
+				{Name: "amount", Type: "integer", NotNull: true, Default: 0},
+			}, nil)
+			Expect(adapter.Connect()).Should(Succeed())
+			defer adapter.Disconnect()
+
+			r := adapter.Insert(payload.New(M{"code": "A1"}))
+			Expect(r.Error()).Should(BeNil())
+			r = adapter.FindById(r.Get("id"))
+			Expect(r.Get("status").String()).Should(Equal("open"))
+			Expect(r.Get("paid").Bool()).Should(BeFalse())
+			Expect(r.Get("amount").Int()).Should(Equal(0))
+
+			Expect(adapter.Insert(payload.New(M{"code": "A1"})).IsError()).Should(BeTrue())
+			Expect(adapter.Insert(payload.New(M{"status": "paid"})).IsError()).Should(BeTrue())
+			Expect(queryPlan(adapter, "SELECT * FROM orders WHERE code = 'A1'")).Should(ContainSubstring("idx_orders_code"))
+		})
+
+		It("should create the composite indexes", func() {
+			adapter := createAdapter([]Column{
+				{Name: "status", Type: "string", Index: true},
+				{Name: "createdAt", Type: "datetime"},
+			}, []Index{{Columns: []string{"status", "-createdAt"}}})
+			Expect(adapter.Connect()).Should(Succeed())
+			defer adapter.Disconnect()
+
+			Expect(tableIndexes(adapter)).Should(Equal(map[string]string{
+				"idx_orders_status":                "CREATE INDEX idx_orders_status ON orders (status)",
+				"idx_orders_status_createdAt_desc": "CREATE INDEX idx_orders_status_createdAt_desc ON orders (status, createdAt DESC)",
+			}))
+			Expect(queryPlan(adapter, "SELECT * FROM orders WHERE status = 'open' ORDER BY createdAt DESC")).Should(
+				ContainSubstring("idx_orders_status_createdAt_desc"))
+		})
+
+		It("should keep the indexes in sync with the declarations on Connect", func() {
+			adapter := createAdapter([]Column{
+				{Name: "code", Type: "string", Index: true},
+				{Name: "status", Type: "string", Index: true},
+			}, nil)
+			Expect(adapter.Connect()).Should(Succeed())
+			conn := adapter.getConn()
+			must(sqlitex.ExecTransient(conn, "CREATE INDEX custom_status ON orders (status);", nil))
+			adapter.returnConn(conn)
+			Expect(adapter.Disconnect()).Should(Succeed())
+
+			adapter = createAdapter([]Column{
+				{Name: "code", Type: "string", Unique: true},
+				{Name: "status", Type: "string"},
+				{Name: "amount", Type: "integer", NotNull: true, Default: 0},
+			}, []Index{{Name: "orders_by_amount", Columns: []string{"amount"}}})
+			Expect(adapter.Connect()).Should(Succeed())
+			defer adapter.Disconnect()
+			Expect(tableIndexes(adapter)).Should(Equal(map[string]string{
+				"idx_orders_code":  "CREATE UNIQUE INDEX idx_orders_code ON orders (code)",
+				"custom_status":    "CREATE INDEX custom_status ON orders (status)",
+				"orders_by_amount": "CREATE INDEX orders_by_amount ON orders (amount)",
+			}))
+		})
+
+		It("FindAndUpdate should roll back all the updates when one of them fails", func() {
+			adapter := createAdapter([]Column{
+				{Name: "code", Type: "string", Unique: true},
+				{Name: "status", Type: "string"},
+			}, nil)
+			Expect(adapter.Connect()).Should(Succeed())
+			defer adapter.Disconnect()
+			adapter.Insert(payload.New(M{"code": "A1", "status": "open"}))
+			adapter.Insert(payload.New(M{"code": "A2", "status": "open"}))
+
+			r := adapter.FindAndUpdate(payload.New(M{
+				"query":  M{"status": "open"},
+				"sort":   "code",
+				"update": M{"code": "B1", "status": "closed"},
+			}))
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(adapter.Count(payload.New(M{"query": M{"status": "open"}})).Int()).Should(Equal(2))
+			Expect(adapter.Count(payload.New(M{"query": M{"code": "B1"}})).Int()).Should(Equal(0))
+
+			Expect(adapter.FindAndUpdate(payload.New(M{"query": M{"status": "open"}})).IsError()).Should(BeTrue())
+		})
+
+		It("should return an error for an index of an unknown column", func() {
+			adapter := createAdapter([]Column{{Name: "code", Type: "string"}}, []Index{{Columns: []string{"missing"}}})
+			Expect(adapter.Connect()).ShouldNot(Succeed())
+			adapter.pool.Close()
+		})
+	})
+
+	Describe("Full-text search", func() {
+		var adapter *Adapter
+		columns := []Column{
+			{Name: "title", Type: "string", Search: true},
+			{Name: "body", Type: "string", Search: true},
+			{Name: "status", Type: "string"},
+		}
+
+		BeforeEach(func() {
+			adapter = &Adapter{
+				URI:     "file:memory:?mode=memory",
+				Table:   "posts",
+				Columns: columns,
+			}
+			adapter.Init(log.WithField("", ""), M{})
+			must(adapter.Connect())
+			adapter.InsertMany(payload.New([]M{
+				{"title": "Moleculer services", "body": "Services talk with each other using the broker.", "status": "published"},
+				{"title": "Database adapters", "body": "The SQLite adapter supports full-text search.", "status": "published"},
+				{"title": "Search everything", "body": "Search search search: the most relevant post.", "status": "draft"},
+			}))
+		})
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		titles := func(r moleculer.Payload) []string {
+			Expect(r.Error()).Should(BeNil())
+			list := []string{}
+			r.ForEach(func(idx interface{}, item moleculer.Payload) bool {
+				list = append(list, item.Get("title").String())
+				return true
+			})
+			return list
+		}
+
+		It("matchQuery should quote the words, phrases and prefixes", func() {
+			Expect(matchQuery(`sqlite  adapt* "full-text search" NOT`)).Should(Equal(`"sqlite" "adapt"* "full-text search" "NOT"`))
+			Expect(matchQuery(`"unclosed phrase`)).Should(Equal(`"unclosed phrase"`))
+			Expect(matchQuery(`"" * `)).Should(Equal(""))
+		})
+
+		It("should find the records with all the words, in any case, sorted by rank", func() {
+			Expect(titles(adapter.Find(payload.New(M{"search": "SEARCH"})))).Should(Equal([]string{"Search everything", "Database adapters"}))
+			Expect(titles(adapter.Find(payload.New(M{"search": "search sqlite"})))).Should(Equal([]string{"Database adapters"}))
+			Expect(adapter.Count(payload.New(M{"search": "search"})).Int()).Should(Equal(2))
+		})
+
+		It("should join the FTS5 table once to sort by rank", func() {
+			statement, args, err := adapter.selectStatement([]string{"title"}, payload.New(M{
+				"search": "search",
+				"query":  M{"status": "published"},
+				"limit":  5,
+			}))
+			Expect(err).Should(BeNil())
+			Expect(strings.Count(statement, "MATCH ?")).Should(Equal(1))
+			Expect(statement).Should(ContainSubstring(" JOIN (SELECT rowid AS fts_rowid, rank AS fts_rank FROM posts_fts"))
+			Expect(statement).Should(ContainSubstring(" ORDER BY posts_fts_match.fts_rank"))
+			Expect(args).Should(Equal([]interface{}{`"search"`, "published", int64(5)}))
+			Expect(titles(adapter.Find(payload.New(M{"search": "search", "query": M{"status": "published"}, "limit": 5})))).Should(Equal([]string{"Database adapters"}))
+
+			statement, _, err = adapter.selectStatement([]string{"title"}, payload.New(M{"search": "search", "sort": "title"}))
+			Expect(err).Should(BeNil())
+			Expect(statement).ShouldNot(ContainSubstring("JOIN"))
+
+			Expect(adapter.Count(payload.New(M{"search": "search", "query": M{"status": "published"}})).Int()).Should(Equal(1))
+		})
+
+		It("should match prefixes and phrases", func() {
+			Expect(titles(adapter.Find(payload.New(M{"search": "adapt*", "sort": "title"})))).Should(Equal([]string{"Database adapters"}))
+			Expect(titles(adapter.Find(payload.New(M{"search": "serv*", "sort": "title"})))).Should(Equal([]string{"Moleculer services"}))
+			Expect(titles(adapter.Find(payload.New(M{"search": `"full-text search"`})))).Should(Equal([]string{"Database adapters"}))
+			Expect(titles(adapter.Find(payload.New(M{"search": `"search full-text"`})))).Should(BeEmpty())
+		})
+
+		It("should search only the searchFields and combine with the query", func() {
+			Expect(titles(adapter.Find(payload.New(M{"search": "search", "searchFields": []string{"title"}})))).Should(Equal([]string{"Search everything"}))
+			Expect(titles(adapter.Find(payload.New(M{"search": "search", "query": M{"status": "published"}})))).Should(Equal([]string{"Database adapters"}))
+			Expect(adapter.Find(payload.New(M{"search": "search", "searchFields": "status"})).IsError()).Should(BeTrue())
+		})
+
+		It("should keep the index in sync on update and remove", func() {
+			r := adapter.FindOne(payload.New(M{"query": M{"title": "Moleculer services"}}))
+			adapter.UpdateById(r.Get("id"), payload.New(M{"body": "Now with search."}))
+			Expect(adapter.Count(payload.New(M{"search": "broker"})).Int()).Should(Equal(0))
+			Expect(adapter.Count(payload.New(M{"search": "search"})).Int()).Should(Equal(3))
+
+			adapter.RemoveById(r.Get("id"))
+			Expect(adapter.Count(payload.New(M{"search": "search"})).Int()).Should(Equal(2))
+		})
+	})
+
+	It("should index the existing records when the Search columns change", func() {
+		dir, err := ioutil.TempDir("", "sqlite-search")
+		must(err)
+		defer os.RemoveAll(dir)
+		createAdapter := func(columns []Column) *Adapter {
+			adapter := &Adapter{URI: "file:" + filepath.Join(dir, "store.db"), Table: "posts", Columns: columns}
+			adapter.Init(log.WithField("", ""), M{})
+			must(adapter.Connect())
+			return adapter
+		}
+		adapter := createAdapter([]Column{{Name: "title", Type: "string"}, {Name: "body", Type: "string"}})
+		adapter.Insert(payload.New(M{"title": "Moleculer", "body": "Search me"}))
+		Expect(adapter.Disconnect()).Should(Succeed())
+
+		adapter = createAdapter([]Column{{Name: "title", Type: "string", Search: true}, {Name: "body", Type: "string"}})
+		Expect(adapter.Count(payload.New(M{"search": "moleculer"})).Int()).Should(Equal(1))
+		Expect(adapter.Count(payload.New(M{"search": "search"})).Int()).Should(Equal(0))
+		Expect(adapter.Disconnect()).Should(Succeed())
+
+		adapter = createAdapter([]Column{{Name: "title", Type: "string", Search: true}, {Name: "body", Type: "string", Search: true}})
+		defer adapter.Disconnect()
+		Expect(adapter.Count(payload.New(M{"search": "search"})).Int()).Should(Equal(1))
+	})
+})
+
+var _ = storetest.Suite("SQLite Adapter", func() store.Adapter {
+	return &Adapter{
+		URI:   "file:memory:?mode=memory",
+		Table: "store_suite",
+		Columns: []Column{
+			{
+				Name: "name",
+				Type: "string",
+			},
+			{
+				Name: "lastname",
+				Type: "string",
+			},
+			{
+				Name: "age",
+				Type: "integer",
+			},
+			{
+				Name: "master",
+				Type: "string",
+			},
+			{
+				Name: "friends",
+				Type: "[]string",
+			},
+		},
+	}
 })
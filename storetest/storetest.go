@@ -0,0 +1,566 @@
+// Package storetest contains the canonical specs every store.Adapter must pass.
+//
+// Use it from a Ginkgo suite of your adapter:
+//
+//	var _ = storetest.Suite("My Adapter", func() store.Adapter {
+//		return &MyAdapter{...}
+//	})
+//
+// The specs load the users from the mocks package before each spec and cover
+// insert, find, sort, limit, offset, query operators, count, update, remove,
+// bulk operations, FindAndUpdate, FindByIds ordering and missing ids, the not found behaviour,
+// aggregations, distinct values and transactions (skipped when the adapter does not
+// implement store.AggregateAdapter, store.DistinctAdapter or store.TransactionalAdapter).
+package storetest
+
+import (
+	"context"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	"github.com/moleculer-go/store"
+	"github.com/moleculer-go/store/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type M map[string]interface{}
+
+// totalUsers number of records loaded by mocks.LoadUsers
+var totalUsers = 6
+
+// names return the list of names of the records in the result.
+func names(result moleculer.Payload) []string {
+	list := []string{}
+	result.ForEach(func(idx interface{}, item moleculer.Payload) bool {
+		list = append(list, item.Get("name").String())
+		return true
+	})
+	return list
+}
+
+// Suite register the adapter specs. createAdapter must return a new adapter (not connected) on each call.
+func Suite(label string, createAdapter func() store.Adapter) bool {
+	return Describe(label+" - store conformance", func() {
+		var adapter store.Adapter
+		var johnSnow, marie, johnTravolta moleculer.Payload
+
+		BeforeEach(func() {
+			adapter = createAdapter()
+			johnSnow, marie, johnTravolta = mocks.ConnectAndLoadUsers(adapter)
+		})
+
+		AfterEach(func() {
+			adapter.RemoveAll()
+			adapter.Disconnect()
+		})
+
+		Describe("Insert", func() {
+			It("should return the inserted record with an id", func() {
+				r := adapter.Insert(payload.New(M{
+					"name":     "Julio",
+					"lastname": "Cesar",
+					"age":      56,
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Get("id").Exists()).Should(BeTrue())
+				Expect(r.Get("name").String()).Should(Equal("Julio"))
+				Expect(r.Get("lastname").String()).Should(Equal("Cesar"))
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(totalUsers + 1))
+
+				fr := adapter.FindById(r.Get("id"))
+				Expect(fr.Error()).Should(BeNil())
+				Expect(fr.Get("name").String()).Should(Equal("Julio"))
+				Expect(fr.Get("age").Int()).Should(Equal(56))
+			})
+		})
+
+		Describe("Find", func() {
+			It("should return all records with empty params", func() {
+				r := adapter.Find(payload.Empty())
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(totalUsers))
+			})
+
+			It("should find using search and searchFields", func() {
+				r := adapter.Find(payload.New(M{
+					"search":       "John",
+					"searchFields": []string{"name"},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(2))
+			})
+
+			It("should find using a query", func() {
+				r := adapter.Find(payload.New(M{
+					"query": M{"name": "John"},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(2))
+
+				r = adapter.Find(payload.New(M{
+					"query": M{"name": "John", "lastname": "Snow"},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(1))
+				Expect(r.First().Get("age").Int()).Should(Equal(25))
+			})
+
+			It("should sort the results", func() {
+				r := adapter.Find(payload.New(M{"sort": "-age"}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(names(r)[:3]).Should(Equal([]string{"Marie", "John", "Julian"}))
+
+				r = adapter.Find(payload.New(M{"sort": "age name"}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(names(r)).Should(Equal([]string{"Peter", "Stone", "John", "Julian", "John", "Marie"}))
+
+				r = adapter.Find(payload.New(M{"sort": []string{"age", "-name"}}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(names(r)[:2]).Should(Equal([]string{"Stone", "Peter"}))
+			})
+
+			It("should limit the results", func() {
+				r := adapter.Find(payload.New(M{"limit": 3}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(3))
+			})
+
+			It("should offset the results", func() {
+				r := adapter.Find(payload.New(M{"sort": "age", "offset": 4}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(names(r)).Should(Equal([]string{"John", "Marie"}))
+
+				r = adapter.Find(payload.New(M{"sort": "age", "offset": 1, "limit": 2}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(names(r)).Should(Equal([]string{"Stone", "John"}))
+			})
+
+			It("FindStream should offset and limit the records", func() {
+				stream, err := store.FindStream(context.Background(), adapter, payload.New(M{"sort": "age name", "offset": 2, "limit": 3}))
+				Expect(err).Should(BeNil())
+				list := []string{}
+				for record := range stream {
+					Expect(record.Error()).Should(BeNil())
+					list = append(list, record.Get("name").String())
+				}
+				Expect(list).Should(Equal([]string{"John", "Julian", "John"}))
+
+				stream, err = store.FindStream(context.Background(), adapter, payload.New(M{"sort": "age name", "offset": 4}))
+				Expect(err).Should(BeNil())
+				list = []string{}
+				for record := range stream {
+					Expect(record.Error()).Should(BeNil())
+					list = append(list, record.Get("name").String())
+				}
+				Expect(list).Should(Equal([]string{"John", "Marie"}))
+			})
+
+			It("FindOne should return the first matching record", func() {
+				r := adapter.FindOne(payload.New(M{
+					"query": M{"age": M{"$gt": 60}},
+					"sort":  "age",
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Get("lastname").String()).Should(Equal("Travolta"))
+			})
+		})
+
+		Describe("Query operators", func() {
+			count := func(query M) int {
+				r := adapter.Find(payload.New(M{"query": query}))
+				Expect(r.Error()).Should(BeNil())
+				return r.Len()
+			}
+
+			It("should support $gt, $gte, $lt and $lte", func() {
+				Expect(count(M{"age": M{"$gt": 60}})).Should(Equal(2))
+				Expect(count(M{"age": M{"$gte": 65}})).Should(Equal(2))
+				Expect(count(M{"age": M{"$lt": 20}})).Should(Equal(2))
+				Expect(count(M{"age": M{"$lte": 25}})).Should(Equal(3))
+			})
+
+			It("should support $ne", func() {
+				Expect(count(M{"name": M{"$ne": "John"}})).Should(Equal(4))
+			})
+
+			It("should support $in and $nin", func() {
+				Expect(count(M{"age": M{"$in": []int{13, 25}}})).Should(Equal(3))
+				Expect(count(M{"age": M{"$nin": []int{13, 25}}})).Should(Equal(3))
+			})
+
+			It("should support $or", func() {
+				Expect(count(M{"$or": []M{
+					M{"name": "John"},
+					M{"lastname": "Claire"},
+				}})).Should(Equal(3))
+			})
+
+			It("should combine $or with other fields", func() {
+				Expect(count(M{
+					"age": M{"$gt": 30},
+					"$or": []M{
+						M{"name": "John"},
+						M{"lastname": "Pan"},
+					},
+				})).Should(Equal(1))
+			})
+		})
+
+		Describe("Count", func() {
+			It("should count all records", func() {
+				r := adapter.Count(payload.Empty())
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Int()).Should(Equal(totalUsers))
+			})
+
+			It("should count records matching the query and search", func() {
+				r := adapter.Count(payload.New(M{"query": M{"age": 13}}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Int()).Should(Equal(2))
+
+				r = adapter.Count(payload.New(M{
+					"search":       "John",
+					"searchFields": []string{"name"},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Int()).Should(Equal(2))
+			})
+		})
+
+		Describe("FindById and FindByIds", func() {
+			It("should find a record by id", func() {
+				r := adapter.FindById(marie.Get("id"))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Get("name").String()).Should(Equal("Marie"))
+				Expect(r.Get("lastname").String()).Should(Equal("Claire"))
+				Expect(r.Get("age").Int()).Should(Equal(75))
+			})
+
+			It("should return the records in the same order of the ids", func() {
+				ids := payload.EmptyList().
+					AddItem(johnTravolta.Get("id")).
+					AddItem(marie.Get("id")).
+					AddItem(johnSnow.Get("id"))
+				r := adapter.FindByIds(ids)
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(3))
+				Expect(r.Array()[0].Get("lastname").String()).Should(Equal("Travolta"))
+				Expect(r.Array()[1].Get("lastname").String()).Should(Equal("Claire"))
+				Expect(r.Array()[2].Get("lastname").String()).Should(Equal("Snow"))
+			})
+
+			It("should return a non existing entry for each id not found", func() {
+				adapter.RemoveById(marie.Get("id"))
+				ids := payload.EmptyList().
+					AddItem(johnTravolta.Get("id")).
+					AddItem(marie.Get("id")).
+					AddItem(johnSnow.Get("id"))
+				r := adapter.FindByIds(ids)
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(3))
+				Expect(r.Array()[0].Get("lastname").String()).Should(Equal("Travolta"))
+				Expect(r.Array()[1].Exists()).Should(BeFalse())
+				Expect(r.Array()[2].Get("lastname").String()).Should(Equal("Snow"))
+			})
+
+			It("should return a non existing payload when the record is not found", func() {
+				adapter.RemoveById(marie.Get("id"))
+				r := adapter.FindById(marie.Get("id"))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Exists()).Should(BeFalse())
+			})
+		})
+
+		Describe("Update", func() {
+			It("UpdateById should update the record", func() {
+				r := adapter.UpdateById(johnSnow.Get("id"), payload.New(M{
+					"lastname": "Stark",
+					"age":      30,
+				}))
+				Expect(r.Error()).Should(BeNil())
+
+				fr := adapter.FindById(johnSnow.Get("id"))
+				Expect(fr.Get("name").String()).Should(Equal("John"))
+				Expect(fr.Get("lastname").String()).Should(Equal("Stark"))
+				Expect(fr.Get("age").Int()).Should(Equal(30))
+			})
+
+			It("Update should update the record with the id in the params", func() {
+				r := adapter.Update(payload.New(M{
+					"id":  marie.Get("id").Value(),
+					"age": 76,
+				}))
+				Expect(r.Error()).Should(BeNil())
+
+				fr := adapter.FindById(marie.Get("id"))
+				Expect(fr.Get("name").String()).Should(Equal("Marie"))
+				Expect(fr.Get("age").Int()).Should(Equal(76))
+			})
+
+			It("FindAndUpdate should update and return all the matching records", func() {
+				r := adapter.FindAndUpdate(payload.New(M{
+					"query":  M{"name": "John"},
+					"update": M{"age": 30},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(2))
+				lastnames := []string{}
+				r.ForEach(func(idx interface{}, item moleculer.Payload) bool {
+					Expect(item.Get("name").String()).Should(Equal("John"))
+					Expect(item.Get("age").Int()).Should(Equal(30))
+					lastnames = append(lastnames, item.Get("lastname").String())
+					return true
+				})
+				Expect(lastnames).Should(ConsistOf("Snow", "Travolta"))
+
+				Expect(adapter.FindById(johnSnow.Get("id")).Get("age").Int()).Should(Equal(30))
+				Expect(adapter.FindById(johnTravolta.Get("id")).Get("age").Int()).Should(Equal(30))
+				Expect(adapter.FindById(marie.Get("id")).Get("age").Int()).Should(Equal(75))
+			})
+
+			It("FindAndUpdate should update only the page of records with limit and offset", func() {
+				r := adapter.FindAndUpdate(payload.New(M{
+					"sort":   "age name",
+					"offset": 4,
+					"limit":  1,
+					"update": M{"age": 66},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(1))
+				Expect(r.First().Get("lastname").String()).Should(Equal("Travolta"))
+				Expect(r.First().Get("age").Int()).Should(Equal(66))
+
+				r = adapter.FindAndUpdate(payload.New(M{
+					"sort":   "age name",
+					"offset": 5,
+					"update": M{"age": 76},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(1))
+				Expect(r.First().Get("lastname").String()).Should(Equal("Claire"))
+
+				Expect(adapter.FindById(johnSnow.Get("id")).Get("age").Int()).Should(Equal(25))
+				Expect(adapter.FindById(johnTravolta.Get("id")).Get("age").Int()).Should(Equal(66))
+				Expect(adapter.FindById(marie.Get("id")).Get("age").Int()).Should(Equal(76))
+			})
+		})
+
+		Describe("Remove", func() {
+			It("RemoveById should remove the record", func() {
+				r := adapter.RemoveById(johnSnow.Get("id"))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Get("deletedCount").Int()).Should(Equal(1))
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(totalUsers - 1))
+				Expect(adapter.FindById(johnSnow.Get("id")).Exists()).Should(BeFalse())
+			})
+
+			It("RemoveById should not fail when the record does not exist", func() {
+				adapter.RemoveById(johnSnow.Get("id"))
+				r := adapter.RemoveById(johnSnow.Get("id"))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Get("deletedCount").Int()).Should(Equal(0))
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(totalUsers - 1))
+			})
+
+			It("RemoveAll should remove all records", func() {
+				r := adapter.RemoveAll()
+				Expect(r.Error()).Should(BeNil())
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(0))
+			})
+		})
+
+		Describe("Bulk operations", func() {
+			It("InsertMany should insert all records and return them with ids", func() {
+				r := adapter.InsertMany(payload.New([]M{
+					M{"name": "Julio", "lastname": "Cesar", "age": 56},
+					M{"name": "Napoleon", "lastname": "Bonaparte", "age": 51},
+					M{"name": "Alexander", "lastname": "Great", "age": 32},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Len()).Should(Equal(3))
+				Expect(names(r)).Should(Equal([]string{"Julio", "Napoleon", "Alexander"}))
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(totalUsers + 3))
+
+				fr := adapter.FindById(r.Array()[1].Get("id"))
+				Expect(fr.Error()).Should(BeNil())
+				Expect(fr.Get("lastname").String()).Should(Equal("Bonaparte"))
+			})
+
+			It("UpdateMany should update all records matching the query", func() {
+				r := adapter.UpdateMany(payload.New(M{
+					"query":  M{"name": "John"},
+					"update": M{"age": 40},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Get("modifiedCount").Int()).Should(Equal(2))
+				Expect(adapter.FindById(johnSnow.Get("id")).Get("age").Int()).Should(Equal(40))
+				Expect(adapter.FindById(johnTravolta.Get("id")).Get("age").Int()).Should(Equal(40))
+				Expect(adapter.FindById(marie.Get("id")).Get("age").Int()).Should(Equal(75))
+			})
+
+			It("RemoveMany should remove the records matching the query", func() {
+				r := adapter.RemoveMany(payload.New(M{
+					"query": M{"name": "John"},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Get("deletedCount").Int()).Should(Equal(2))
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(totalUsers - 2))
+			})
+
+			It("UpdateMany and RemoveMany should reject an empty filter", func() {
+				r := adapter.RemoveMany(payload.New(M{"query": M{}}))
+				Expect(r.IsError()).Should(BeTrue())
+				r = adapter.UpdateMany(payload.New(M{"query": M{}, "update": M{"age": 1}}))
+				Expect(r.IsError()).Should(BeTrue())
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(totalUsers))
+				Expect(adapter.FindById(marie.Get("id")).Get("age").Int()).Should(Equal(75))
+			})
+
+			It("RemoveMany should remove the records by ids", func() {
+				r := adapter.RemoveMany(payload.New(M{
+					"ids": []string{johnSnow.Get("id").String(), marie.Get("id").String()},
+				}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.Get("deletedCount").Int()).Should(Equal(2))
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(totalUsers - 2))
+				Expect(adapter.FindById(marie.Get("id")).Exists()).Should(BeFalse())
+				Expect(adapter.FindById(johnTravolta.Get("id")).Exists()).Should(BeTrue())
+			})
+		})
+
+		Describe("Aggregate", func() {
+			var aadapter store.AggregateAdapter
+
+			BeforeEach(func() {
+				var ok bool
+				aadapter, ok = adapter.(store.AggregateAdapter)
+				if !ok {
+					Skip("adapter does not implement store.AggregateAdapter")
+				}
+			})
+
+			aggregate := func(params M) moleculer.Payload {
+				aggregation, err := store.ParseAggregation(payload.New(params))
+				Expect(err).Should(BeNil())
+				r := aadapter.Aggregate(payload.New(params), aggregation)
+				Expect(r.Error()).Should(BeNil())
+				return r
+			}
+
+			It("should calculate the metrics of each group sorted by the group by fields", func() {
+				r := aggregate(M{
+					"groupBy": []string{"name"},
+					"metrics": M{
+						"users":  M{"count": "*"},
+						"total":  M{"sum": "age"},
+						"avg":    M{"avg": "age"},
+						"oldest": M{"max": "age"},
+						"newest": M{"min": "age"},
+					},
+				})
+				Expect(names(r)).Should(Equal([]string{"John", "Julian", "Marie", "Peter", "Stone"}))
+				john := r.First()
+				Expect(john.Get("users").Int()).Should(Equal(2))
+				Expect(john.Get("total").Float()).Should(Equal(90.0))
+				Expect(john.Get("avg").Float()).Should(Equal(45.0))
+				Expect(john.Get("oldest").Int()).Should(Equal(65))
+				Expect(john.Get("newest").Int()).Should(Equal(25))
+			})
+
+			It("should calculate the metrics of the records matching the query without group by", func() {
+				r := aggregate(M{
+					"query":   M{"age": M{"$gt": 20}},
+					"metrics": M{"users": M{"count": "*"}, "withMaster": M{"count": "master"}, "total": M{"sum": "age"}},
+				})
+				Expect(r.Len()).Should(Equal(1))
+				Expect(r.First().Get("users").Int()).Should(Equal(4))
+				Expect(r.First().Get("withMaster").Int()).Should(Equal(2))
+				Expect(r.First().Get("total").Float()).Should(Equal(211.0))
+
+				r = aggregate(M{"query": M{"age": M{"$gt": 100}}})
+				Expect(r.Len()).Should(Equal(1))
+				Expect(r.First().Get("count").Int()).Should(Equal(0))
+			})
+		})
+
+		Describe("Distinct", func() {
+			var dadapter store.DistinctAdapter
+
+			BeforeEach(func() {
+				var ok bool
+				dadapter, ok = adapter.(store.DistinctAdapter)
+				if !ok {
+					Skip("adapter does not implement store.DistinctAdapter")
+				}
+			})
+
+			It("should return the sorted distinct values of the field", func() {
+				r := dadapter.Distinct(payload.Empty(), "age")
+				Expect(r.Error()).Should(BeNil())
+				ages := []int{}
+				r.ForEach(func(idx interface{}, age moleculer.Payload) bool {
+					ages = append(ages, age.Int())
+					return true
+				})
+				Expect(ages).Should(Equal([]int{13, 25, 46, 65, 75}))
+			})
+
+			It("should return the values of the records matching the query without null", func() {
+				r := dadapter.Distinct(payload.New(M{"query": M{"age": M{"$gt": 20}}}), "name")
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.StringArray()).Should(Equal([]string{"John", "Julian", "Marie"}))
+
+				r = dadapter.Distinct(payload.Empty(), "master")
+				Expect(r.Error()).Should(BeNil())
+				Expect(r.StringArray()).Should(Equal([]string{johnSnow.Get("id").String()}))
+			})
+		})
+
+		Describe("Transactions", func() {
+			var tadapter store.TransactionalAdapter
+
+			BeforeEach(func() {
+				var ok bool
+				tadapter, ok = adapter.(store.TransactionalAdapter)
+				if !ok {
+					Skip("adapter does not implement store.TransactionalAdapter")
+				}
+			})
+
+			It("Commit should persist the changes", func() {
+				tx, err := tadapter.Begin()
+				Expect(err).Should(BeNil())
+				r := tx.Insert(payload.New(M{"name": "Julio", "lastname": "Cesar", "age": 56}))
+				Expect(r.Error()).Should(BeNil())
+				Expect(tx.RemoveById(johnSnow.Get("id")).Error()).Should(BeNil())
+				Expect(tx.Commit()).Should(Succeed())
+
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(totalUsers))
+				Expect(adapter.FindById(r.Get("id")).Get("name").String()).Should(Equal("Julio"))
+				Expect(adapter.FindById(johnSnow.Get("id")).Exists()).Should(BeFalse())
+			})
+
+			It("Rollback should discard the changes", func() {
+				tx, err := tadapter.Begin()
+				Expect(err).Should(BeNil())
+				Expect(tx.Insert(payload.New(M{"name": "Julio", "lastname": "Cesar", "age": 56})).Error()).Should(BeNil())
+				Expect(tx.UpdateById(marie.Get("id"), payload.New(M{"age": 80})).Error()).Should(BeNil())
+				Expect(tx.Count(payload.Empty()).Int()).Should(Equal(totalUsers + 1))
+				Expect(tx.Rollback()).Should(Succeed())
+
+				Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(totalUsers))
+				Expect(adapter.FindById(marie.Get("id")).Get("age").Int()).Should(Equal(75))
+			})
+
+			It("RunInTransaction should rollback when fn returns an error", func() {
+				r := store.RunInTransaction(adapter, func(tx store.Adapter) moleculer.Payload {
+					tx.RemoveById(johnTravolta.Get("id"))
+					return payload.Error("abort!")
+				})
+				Expect(r.IsError()).Should(BeTrue())
+				Expect(adapter.FindById(johnTravolta.Get("id")).Exists()).Should(BeTrue())
+			})
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// StreamingAdapter is implemented by adapters that can read the records one by one from the database
+// (statement stepping, cursors, scroll) instead of loading all of them in memory.
+type StreamingAdapter interface {
+	Adapter
+	// FindStream returns a channel with the records matching the params, the same params of Find.
+	// The channel is closed after the last record. A read error is sent as an error payload and closes the channel.
+	// The stream stops and releases the database resources when ctx is done, the channel is closed without sending
+	// the rest of the records. Otherwise the channel must be consumed until it is closed.
+	FindStream(ctx context.Context, params moleculer.Payload) (<-chan moleculer.Payload, error)
+}
+
+// streamBatchSize number of records of each Find made by FindStream for adapters without StreamingAdapter.
+var streamBatchSize = 100
+
+// FindStream returns a channel with the records matching the params (query, search, sort, limit and offset).
+// It uses the adapter FindStream when available, otherwise reads the records in batches of streamBatchSize,
+// so only one batch is in memory. Cancel ctx to stop reading before the end of the stream.
+func FindStream(ctx context.Context, adapter Adapter, params moleculer.Payload) (<-chan moleculer.Payload, error) {
+	if sadapter, ok := adapter.(StreamingAdapter); ok {
+		return sadapter.FindStream(ctx, params)
+	}
+	if params == nil || !params.IsMap() {
+		params = payload.Empty()
+	}
+	limit, offset := -1, 0
+	if params.Get("limit").Exists() {
+		limit = params.Get("limit").Int()
+	}
+	if params.Get("offset").Exists() {
+		offset = params.Get("offset").Int()
+	}
+	base := payload.Empty().AddMany(params.RawMap()).Remove("limit", "offset")
+	findBatch := func() moleculer.Payload {
+		return adapter.Find(payload.Empty().AddMany(base.RawMap()).Add("limit", streamBatchSize).Add("offset", offset))
+	}
+	rows := findBatch()
+	if rows.IsError() {
+		return nil, rows.Error()
+	}
+	stream := make(chan moleculer.Payload)
+	go func() {
+		defer close(stream)
+		sent := 0
+		for {
+			for _, row := range rows.Array() {
+				if limit >= 0 && sent >= limit {
+					return
+				}
+				if !SendRecord(ctx, stream, row) {
+					return
+				}
+				sent++
+			}
+			if rows.Len() < streamBatchSize {
+				return
+			}
+			offset = offset + rows.Len()
+			rows = findBatch()
+			if rows.IsError() {
+				SendRecord(ctx, stream, rows)
+				return
+			}
+		}
+	}()
+	return stream, nil
+}
+
+// SendRecord sends the record to the stream, unless ctx is done first. returns false when ctx is done,
+// so the StreamingAdapter stops reading and releases the database resources.
+func SendRecord(ctx context.Context, stream chan<- moleculer.Payload, record moleculer.Payload) bool {
+	select {
+	case stream <- record:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// StreamEach calls fn with each record matching the params, read with FindStream. Use it in custom actions
+// to export or process large collections. Stops when fn returns false. returns the read error, if any.
+func StreamEach(adapter Adapter, params moleculer.Payload, fn func(record moleculer.Payload) bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	//stops the stream when fn returns false, so the adapter releases the database resources
+	defer cancel()
+	stream, err := FindStream(ctx, adapter, params)
+	if err != nil {
+		return err
+	}
+	for record := range stream {
+		if record.IsError() {
+			return record.Error()
+		}
+		if !fn(record) {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// Transaction is an adapter scoped to a database transaction.
+// All calls made on it are part of the transaction until Commit or Rollback is called.
+// A transaction is not safe for concurrent use.
+type Transaction interface {
+	Adapter
+	Commit() error
+	Rollback() error
+}
+
+// TransactionalAdapter is implemented by adapters that can group several writes atomically.
+type TransactionalAdapter interface {
+	Adapter
+	Begin() (Transaction, error)
+}
+
+// RunInTransaction calls fn with an adapter scoped to a new transaction.
+// The transaction is committed when fn returns a non error payload, otherwise (error payload or panic) it is rolled back.
+// When the adapter does not support transactions, or is already a transaction, fn is called with the adapter itself.
+func RunInTransaction(adapter Adapter, fn func(tx Adapter) moleculer.Payload) (result moleculer.Payload) {
+	if _, inTx := adapter.(Transaction); inTx {
+		return fn(adapter)
+	}
+	tadapter, ok := adapter.(TransactionalAdapter)
+	if !ok {
+		return fn(adapter)
+	}
+	tx, err := tadapter.Begin()
+	if err != nil {
+		return payload.Error("Could not begin transaction. Error: ", err.Error())
+	}
+	return runTransaction(tx, fn)
+}
+
+// runTransaction calls fn with the transaction, then commits it or rolls it back (error payload or panic).
+func runTransaction(tx Transaction, fn func(tx Adapter) moleculer.Payload) (result moleculer.Payload) {
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			result = payload.Error("Transaction rolled back. Error: ", fmt.Sprint(r))
+		}
+	}()
+	result = fn(tx)
+	if result != nil && result.IsError() {
+		if err := tx.Rollback(); err != nil {
+			return payload.Error("Could not rollback transaction. Error: ", err.Error(), " - cause: ", result.Error().Error())
+		}
+		return result
+	}
+	if err := tx.Commit(); err != nil {
+		return payload.Error("Could not commit transaction. Error: ", err.Error())
+	}
+	return result
+}
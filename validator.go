@@ -0,0 +1,243 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// ValidationError describes one field that failed the entity validation.
+type ValidationError struct {
+	Field    string
+	Type     string
+	Message  string
+	Expected interface{}
+	Actual   interface{}
+}
+
+func (e ValidationError) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"field":    e.Field,
+		"type":     e.Type,
+		"message":  e.Message,
+		"expected": e.Expected,
+		"actual":   e.Actual,
+	}
+}
+
+// validationErrorPayload return the error payload with the list of validation errors.
+func validationErrorPayload(errors []ValidationError) moleculer.Payload {
+	list := []interface{}{}
+	for _, err := range errors {
+		list = append(list, err.toMap())
+	}
+	return payload.PayloadError("Entity validation error!", payload.New(list))
+}
+
+// validateEntity validates the entity using the entityValidator setting.
+// The setting can be a func(moleculer.Payload, bool) []ValidationError, a func(moleculer.Payload) []ValidationError
+// or a schema map (see validateSchema). partial is used on updates, when only the fields being changed are present.
+// returns nil when the entity is valid or there is no validator.
+func validateEntity(settings map[string]interface{}, entity moleculer.Payload, partial bool) moleculer.Payload {
+	errors, err := entityErrors(settings["entityValidator"], entity, partial)
+	if err != nil {
+		return payload.New(err)
+	}
+	if len(errors) > 0 {
+		return validationErrorPayload(errors)
+	}
+	return nil
+}
+
+// validateEntities validates a list of entities, the field of each error is prefixed with the index of the entity.
+func validateEntities(settings map[string]interface{}, entities moleculer.Payload) moleculer.Payload {
+	errors := []ValidationError{}
+	var invalid error
+	entities.ForEach(func(idx interface{}, entity moleculer.Payload) bool {
+		list, err := entityErrors(settings["entityValidator"], entity, false)
+		if err != nil {
+			invalid = err
+			return false
+		}
+		for _, err := range list {
+			err.Field = fmt.Sprint(idx, ".", err.Field)
+			errors = append(errors, err)
+		}
+		return true
+	})
+	if invalid != nil {
+		return payload.New(invalid)
+	}
+	if len(errors) > 0 {
+		return validationErrorPayload(errors)
+	}
+	return nil
+}
+
+// entityErrors return the validation errors of the entity. returns an error when the validator type is not supported,
+// so a wrong entityValidator setting does not disable the validation.
+// Validators without the partial flag are called with the changed fields only on updates.
+func entityErrors(validator interface{}, entity moleculer.Payload, partial bool) ([]ValidationError, error) {
+	switch v := validator.(type) {
+	case nil:
+		return nil, nil
+	case func(moleculer.Payload, bool) []ValidationError:
+		return v(entity, partial), nil
+	case func(moleculer.Payload) []ValidationError:
+		return v(entity), nil
+	case map[string]interface{}:
+		return validateSchema(v, entity, partial), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Invalid entityValidator setting, type not supported: %T", validator))
+}
+
+// validateSchema validates the entity against a declarative schema. Example:
+//
+//	map[string]interface{}{
+//		"name":  map[string]interface{}{"type": "string", "required": true, "min": 3, "max": 50},
+//		"email": map[string]interface{}{"type": "string", "pattern": "^\\S+@\\S+$"},
+//		"age":   map[string]interface{}{"type": "integer", "min": 0},
+//		"role":  map[string]interface{}{"enum": []string{"admin", "user"}},
+//		"tags":  "array",
+//	}
+//
+// types: string, number, integer, boolean, array, object and any.
+// min and max are the length for strings and arrays and the value for numbers.
+// when partial is true missing required fields are ignored.
+func validateSchema(schema map[string]interface{}, entity moleculer.Payload, partial bool) []ValidationError {
+	errors := []ValidationError{}
+	for field, config := range schema {
+		rules := payload.New(config)
+		if !rules.IsMap() {
+			rules = payload.Empty().Add("type", rules.String())
+		}
+		value := entity.Get(field)
+		if !value.Exists() {
+			if rules.Get("required").Bool() && !partial {
+				errors = append(errors, ValidationError{
+					Field:    field,
+					Type:     "required",
+					Message:  "The '" + field + "' field is required.",
+					Expected: true,
+				})
+			}
+			continue
+		}
+		errors = append(errors, validateField(field, value, rules)...)
+	}
+	return errors
+}
+
+func validateField(field string, value, rules moleculer.Payload) []ValidationError {
+	fieldType := "any"
+	if rules.Get("type").Exists() {
+		fieldType = rules.Get("type").String()
+	}
+	if !checkType(fieldType, value) {
+		return []ValidationError{{
+			Field:    field,
+			Type:     "type",
+			Message:  "The '" + field + "' field must be of type " + fieldType + ".",
+			Expected: fieldType,
+			Actual:   value.Value(),
+		}}
+	}
+	errors := []ValidationError{}
+	size, hasSize := valueSize(value)
+	if rules.Get("min").Exists() && hasSize && size < rules.Get("min").Float() {
+		errors = append(errors, ValidationError{
+			Field:    field,
+			Type:     "min",
+			Message:  fmt.Sprint("The '", field, "' field must be greater than or equal to ", rules.Get("min").Value(), "."),
+			Expected: rules.Get("min").Value(),
+			Actual:   value.Value(),
+		})
+	}
+	if rules.Get("max").Exists() && hasSize && size > rules.Get("max").Float() {
+		errors = append(errors, ValidationError{
+			Field:    field,
+			Type:     "max",
+			Message:  fmt.Sprint("The '", field, "' field must be less than or equal to ", rules.Get("max").Value(), "."),
+			Expected: rules.Get("max").Value(),
+			Actual:   value.Value(),
+		})
+	}
+	if rules.Get("pattern").Exists() {
+		pattern := rules.Get("pattern").String()
+		re, err := regexp.Compile(pattern)
+		if err != nil || !re.MatchString(value.String()) {
+			errors = append(errors, ValidationError{
+				Field:    field,
+				Type:     "pattern",
+				Message:  "The '" + field + "' field fails to match the pattern " + pattern + ".",
+				Expected: pattern,
+				Actual:   value.Value(),
+			})
+		}
+	}
+	if rules.Get("enum").Exists() && !inEnum(value, rules.Get("enum")) {
+		errors = append(errors, ValidationError{
+			Field:    field,
+			Type:     "enum",
+			Message:  "The '" + field + "' field does not match any of the allowed values.",
+			Expected: rules.Get("enum").Value(),
+			Actual:   value.Value(),
+		})
+	}
+	return errors
+}
+
+func checkType(fieldType string, value moleculer.Payload) bool {
+	switch strings.ToLower(fieldType) {
+	case "string":
+		_, ok := value.Value().(string)
+		return ok
+	case "number":
+		return isNumber(value.Value())
+	case "integer":
+		return isNumber(value.Value()) && value.Float() == float64(value.Int64())
+	case "boolean":
+		_, ok := value.Value().(bool)
+		return ok
+	case "array":
+		return value.IsArray()
+	case "object":
+		return value.IsMap()
+	}
+	return true
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
+
+// valueSize return the size used by min and max: length of strings and arrays and the value of numbers.
+func valueSize(value moleculer.Payload) (float64, bool) {
+	if s, ok := value.Value().(string); ok {
+		return float64(len(s)), true
+	}
+	if value.IsArray() {
+		return float64(value.Len()), true
+	}
+	if isNumber(value.Value()) {
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+func inEnum(value, enum moleculer.Payload) bool {
+	found := false
+	enum.ForEach(func(idx interface{}, item moleculer.Payload) bool {
+		found = fmt.Sprint(item.Value()) == fmt.Sprint(value.Value())
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Entity validator", func() {
+
+	schema := map[string]interface{}{
+		"name":     map[string]interface{}{"type": "string", "required": true, "min": 3, "max": 10},
+		"email":    map[string]interface{}{"type": "string", "pattern": "^\\S+@\\S+$"},
+		"age":      map[string]interface{}{"type": "integer", "min": 0, "max": 150},
+		"role":     map[string]interface{}{"enum": []string{"admin", "user"}},
+		"tags":     "array",
+		"verified": map[string]interface{}{"type": "boolean"},
+	}
+
+	fields := func(errors []ValidationError) map[string]string {
+		r := map[string]string{}
+		for _, err := range errors {
+			r[err.Field] = err.Type
+		}
+		return r
+	}
+
+	It("should accept a valid entity", func() {
+		errors := validateSchema(schema, payload.New(map[string]interface{}{
+			"name":     "John",
+			"email":    "john@snow.com",
+			"age":      25,
+			"role":     "admin",
+			"tags":     []string{"north"},
+			"verified": true,
+		}), false)
+		Expect(errors).Should(BeEmpty())
+	})
+
+	It("should list each failing field", func() {
+		errors := validateSchema(schema, payload.New(map[string]interface{}{
+			"email":    "john.snow.com",
+			"age":      200,
+			"role":     "king",
+			"tags":     "north",
+			"verified": "yes",
+		}), false)
+		Expect(fields(errors)).Should(Equal(map[string]string{
+			"name":     "required",
+			"email":    "pattern",
+			"age":      "max",
+			"role":     "enum",
+			"tags":     "type",
+			"verified": "type",
+		}))
+	})
+
+	It("should check min and max of strings and numbers", func() {
+		errors := validateSchema(schema, payload.New(map[string]interface{}{
+			"name": "Jo",
+			"age":  -1,
+		}), false)
+		Expect(fields(errors)).Should(Equal(map[string]string{
+			"name": "min",
+			"age":  "min",
+		}))
+
+		errors = validateSchema(schema, payload.New(map[string]interface{}{
+			"name": "Johnny Be Good",
+			"age":  25.5,
+		}), false)
+		Expect(fields(errors)).Should(Equal(map[string]string{
+			"name": "max",
+			"age":  "type",
+		}))
+	})
+
+	It("should ignore missing required fields when partial", func() {
+		errors := validateSchema(schema, payload.New(map[string]interface{}{
+			"age": 30,
+		}), true)
+		Expect(errors).Should(BeEmpty())
+	})
+
+	It("validateEntity should return an error payload with the list of errors", func() {
+		settings := map[string]interface{}{"entityValidator": schema}
+		r := validateEntity(settings, payload.New(map[string]interface{}{"age": 30}), false)
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(Equal("Entity validation error!"))
+		Expect(r.ErrorPayload().Len()).Should(Equal(1))
+		Expect(r.ErrorPayload().First().Get("field").String()).Should(Equal("name"))
+		Expect(r.ErrorPayload().First().Get("type").String()).Should(Equal("required"))
+
+		Expect(validateEntity(map[string]interface{}{}, payload.Empty(), false)).Should(BeNil())
+	})
+
+	It("validateEntity should use a validator function", func() {
+		settings := map[string]interface{}{
+			"entityValidator": func(entity moleculer.Payload) []ValidationError {
+				if entity.Get("name").String() == "Ze" {
+					return []ValidationError{{Field: "name", Type: "custom", Message: "Ze is not allowed!"}}
+				}
+				return nil
+			},
+		}
+		r := validateEntity(settings, payload.New(map[string]interface{}{"name": "Ze"}), false)
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.ErrorPayload().First().Get("message").String()).Should(Equal("Ze is not allowed!"))
+
+		Expect(validateEntity(settings, payload.New(map[string]interface{}{"name": "John"}), false)).Should(BeNil())
+	})
+
+	It("validateEntity should pass the partial flag to the validator function", func() {
+		settings := map[string]interface{}{
+			"entityValidator": func(entity moleculer.Payload, partial bool) []ValidationError {
+				if !partial && !entity.Get("name").Exists() {
+					return []ValidationError{{Field: "name", Type: "required", Message: "name is required!"}}
+				}
+				return nil
+			},
+		}
+		r := validateEntity(settings, payload.New(map[string]interface{}{"age": 30}), false)
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.ErrorPayload().First().Get("message").String()).Should(Equal("name is required!"))
+
+		Expect(validateEntity(settings, payload.New(map[string]interface{}{"age": 30}), true)).Should(BeNil())
+	})
+
+	It("validateEntity should return an error for an unsupported validator", func() {
+		settings := map[string]interface{}{
+			"entityValidator": func(entity map[string]interface{}) error { return nil },
+		}
+		r := validateEntity(settings, payload.New(map[string]interface{}{"name": "Ze"}), false)
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(HavePrefix("Invalid entityValidator setting"))
+
+		r = validateEntities(settings, payload.New([]map[string]interface{}{{"name": "John"}}))
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.Error().Error()).Should(HavePrefix("Invalid entityValidator setting"))
+	})
+
+	It("validateEntities should prefix the fields with the entity index", func() {
+		settings := map[string]interface{}{"entityValidator": schema}
+		r := validateEntities(settings, payload.New([]map[string]interface{}{
+			{"name": "John"},
+			{"name": "Marie", "age": "old"},
+		}))
+		Expect(r.IsError()).Should(BeTrue())
+		Expect(r.ErrorPayload().First().Get("field").String()).Should(Equal("1.age"))
+	})
+
+	Describe("actions", func() {
+		adapter := &MemoryAdapter{
+			Table:        "user",
+			SearchFields: []string{"name"},
+		}
+		ctx, delegates := contextAndDelegated("validator-test", moleculer.Config{})
+		delegates.BroadcastEvent = func(context moleculer.BrokerContext) {}
+		svc := &moleculer.ServiceSchema{
+			Name:     "user",
+			Settings: map[string]interface{}{"entityValidator": schema},
+		}
+		getInstance := func() *moleculer.ServiceSchema { return svc }
+		BeforeEach(func() {
+			adapter.Connect()
+		})
+
+		AfterEach(func() {
+			adapter.Disconnect()
+		})
+
+		It("create should not insert an invalid entity", func() {
+			create := createAction(adapter, getInstance)
+			r := create(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"name": "Jo",
+			})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(r.ErrorPayload().First().Get("type").String()).Should(Equal("min"))
+			Expect(adapter.Count(payload.Empty()).Int()).Should(Equal(0))
+		})
+
+		It("update should validate only the fields being updated", func() {
+			johnSnow := adapter.Insert(payload.New(map[string]interface{}{"name": "John"}))
+			update := updateAction(adapter, getInstance)
+			r := update(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id":  johnSnow.Get("id").String(),
+				"age": 30,
+			})).(moleculer.Payload)
+			Expect(r.Error()).Should(BeNil())
+
+			r = update(ctx.(moleculer.Context), payload.New(map[string]interface{}{
+				"id":   johnSnow.Get("id").String(),
+				"role": "king",
+			})).(moleculer.Payload)
+			Expect(r.IsError()).Should(BeTrue())
+			Expect(r.ErrorPayload().First().Get("field").String()).Should(Equal("role"))
+		})
+	})
+})
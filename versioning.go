@@ -0,0 +1,165 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/moleculer-go/moleculer"
+	"github.com/moleculer-go/moleculer/payload"
+)
+
+// fields managed by the timestamps and versioning settings.
+const (
+	createdAtField = "createdAt"
+	updatedAtField = "updatedAt"
+	versionField   = "version"
+)
+
+// VersionedAdapter is implemented by adapters that can update a record only when its version matches,
+// using the native filter of the database so concurrent updates can not overwrite each other.
+type VersionedAdapter interface {
+	Adapter
+	// UpdateByIdVersion applies the update to the record with the id only when versionField is equal to version.
+	// returns the updated record or an empty payload (payload.New(nil)) when no record matched.
+	UpdateByIdVersion(id moleculer.Payload, versionField string, version int64, update moleculer.Payload) moleculer.Payload
+}
+
+// VersionConflictError is returned by the update and findAndUpdate actions when the version of the record
+// is not the expected version.
+type VersionConflictError struct {
+	ID       interface{}
+	Expected int64
+	Current  int64
+}
+
+func (e VersionConflictError) Error() string {
+	return fmt.Sprint("Version conflict! id: ", e.ID, " expected version: ", e.Expected, " current version: ", e.Current)
+}
+
+// timestampsEnabled check the timestamps setting.
+func timestampsEnabled(settings map[string]interface{}) bool {
+	enabled, _ := settings["timestamps"].(bool)
+	return enabled
+}
+
+// versioningEnabled check the versioning setting.
+func versioningEnabled(settings map[string]interface{}) bool {
+	enabled, _ := settings["versioning"].(bool)
+	return enabled
+}
+
+// stampCreate return a copy of the entity with createdAt, updatedAt and version set, according to the settings.
+func stampCreate(settings map[string]interface{}, entity moleculer.Payload) moleculer.Payload {
+	if !timestampsEnabled(settings) && !versioningEnabled(settings) {
+		return entity
+	}
+	stamped := payload.Empty().AddMany(entity.RawMap())
+	if timestampsEnabled(settings) {
+		now := time.Now()
+		stamped = stamped.Add(createdAtField, now).Add(updatedAtField, now)
+	}
+	if versioningEnabled(settings) {
+		stamped = stamped.Add(versionField, 1)
+	}
+	return stamped
+}
+
+// stampCreateMany applies stampCreate to a list of entities.
+func stampCreateMany(settings map[string]interface{}, entities moleculer.Payload) moleculer.Payload {
+	if !timestampsEnabled(settings) && !versioningEnabled(settings) {
+		return entities
+	}
+	list := []moleculer.Payload{}
+	for _, entity := range entities.Array() {
+		list = append(list, stampCreate(settings, entity))
+	}
+	return payload.New(list)
+}
+
+// stampUpdate return a copy of the update with updatedAt set and without the fields managed by the mixin.
+func stampUpdate(settings map[string]interface{}, update moleculer.Payload) moleculer.Payload {
+	if !timestampsEnabled(settings) && !versioningEnabled(settings) {
+		return update
+	}
+	stamped := payload.Empty().AddMany(update.RawMap())
+	if timestampsEnabled(settings) {
+		stamped = stamped.Remove(createdAtField).Add(updatedAtField, time.Now())
+	}
+	if versioningEnabled(settings) {
+		stamped = stamped.Remove(versionField)
+	}
+	return stamped
+}
+
+// updateVersioned updates the record and increments its version.
+// When expected exists the update fails with a VersionConflictError if the record version is different.
+// Records created before versioning was enabled (without version) are updated without the version check.
+func updateVersioned(adapter Adapter, id, expected, update moleculer.Payload) moleculer.Payload {
+	current := adapter.FindById(id)
+	if current.IsError() {
+		return current
+	}
+	if !current.Exists() {
+		return payload.Error("Could not update record. Record not found. id: ", id.String())
+	}
+	version := current.Get(versionField).Int64()
+	if expected != nil && expected.Exists() && expected.Int64() != version {
+		return payload.New(VersionConflictError{id.Value(), expected.Int64(), version})
+	}
+	update = payload.Empty().AddMany(update.RawMap()).Add(versionField, version+1)
+	if !current.Get(versionField).Exists() {
+		if r := adapter.UpdateById(id, update); r.IsError() {
+			return r
+		}
+		return adapter.FindById(id)
+	}
+	r := updateByIdVersion(adapter, id, version, update)
+	if r.IsError() || r.Exists() {
+		return r
+	}
+	return payload.New(VersionConflictError{id.Value(), version, adapter.FindById(id).Get(versionField).Int64()})
+}
+
+// updateByIdVersion uses the adapter UpdateByIdVersion when available,
+// otherwise checks the version and updates the record in a transaction.
+func updateByIdVersion(adapter Adapter, id moleculer.Payload, version int64, update moleculer.Payload) moleculer.Payload {
+	if vadapter, ok := adapter.(VersionedAdapter); ok {
+		return vadapter.UpdateByIdVersion(id, versionField, version, update)
+	}
+	return RunInTransaction(adapter, func(tx Adapter) moleculer.Payload {
+		current := tx.FindById(id)
+		if current.IsError() {
+			return current
+		}
+		if !current.Exists() || current.Get(versionField).Int64() != version {
+			return payload.New(nil)
+		}
+		r := tx.UpdateById(id, update)
+		if r.IsError() {
+			return r
+		}
+		return tx.FindById(id)
+	})
+}
+
+// findAndUpdateVersioned updates each record matching the params with updateVersioned in a transaction.
+// params.version is the expected version of all records.
+func findAndUpdateVersioned(settings map[string]interface{}, adapter Adapter, params, update moleculer.Payload) moleculer.Payload {
+	expected := params.Get(versionField)
+	query := params.Remove("update").Remove(versionField)
+	return RunInTransaction(adapter, func(tx Adapter) moleculer.Payload {
+		items := tx.Find(query)
+		if items.IsError() {
+			return items
+		}
+		list := []moleculer.Payload{}
+		for _, item := range items.Array() {
+			r := updateVersioned(tx, item.Get(idFieldName(settings)), expected, update)
+			if r.IsError() {
+				return r
+			}
+			list = append(list, r)
+		}
+		return payload.New(list)
+	})
+}